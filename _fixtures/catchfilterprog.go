@@ -0,0 +1,23 @@
+package main
+
+const N = 500
+
+func noise(done chan<- bool) {
+	for i := 0; i < N; i++ {
+		go func() {
+			done <- true
+		}()
+	}
+}
+
+func main() {
+	done := make(chan bool)
+	noise(done)
+	for i := 0; i < N; i++ {
+		<-done
+	}
+	go func() {
+		done <- true
+	}()
+	<-done
+}
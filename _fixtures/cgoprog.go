@@ -0,0 +1,35 @@
+package main
+
+/*
+int stop;
+
+void spin(void) {
+	while (!*(volatile int *)&stop) {
+	}
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"time"
+)
+
+func spinner(done chan<- bool) {
+	C.spin()
+	done <- true
+}
+
+func stopped() {
+	fmt.Println("stopped")
+}
+
+func main() {
+	done := make(chan bool)
+	go spinner(done)
+	// Give the goroutine time to enter the C function.
+	time.Sleep(100 * time.Millisecond)
+	stopped()
+	C.stop = 1
+	<-done
+}
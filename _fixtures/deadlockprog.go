@@ -0,0 +1,27 @@
+package main
+
+import "time"
+
+func worker(in <-chan int, out chan<- int) {
+	v := <-in
+	out <- v
+}
+
+func waiter(c <-chan int) {
+	<-c
+}
+
+func stopped() {
+	return
+}
+
+func main() {
+	a, b := make(chan int), make(chan int)
+	go worker(a, b)
+	go worker(b, a)
+	c := make(chan int)
+	go waiter(c)
+	go waiter(c)
+	time.Sleep(100 * time.Millisecond)
+	stopped()
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func main() {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	fmt.Println(ms.NumGC)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+func main() {
+	buffered := make(chan int, 4)
+	buffered <- 1
+	buffered <- 2
+	buffered <- 3
+	<-buffered
+	buffered <- 4
+	buffered <- 5
+
+	closed := make(chan string, 2)
+	closed <- "last"
+	close(closed)
+
+	var nilch chan int
+
+	unbuffered := make(chan int)
+	go func() { unbuffered <- 42 }()
+	go func() { unbuffered <- 43 }()
+	recv := make(chan bool)
+	go func() { <-recv }()
+	time.Sleep(100 * time.Millisecond)
+
+	runtime.Breakpoint()
+	fmt.Println(buffered, closed, nilch, unbuffered, recv)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+type T struct{ N int }
+
+func (t T) Get() int { return t.N }
+
+func (t *T) Inc() int {
+	t.N++
+	return t.N
+}
+
+func counter(start int, label string) func() int {
+	n := start
+	return func() int {
+		n++
+		fmt.Println(label)
+		return n
+	}
+}
+
+func main() {
+	f := counter(10, "tick")
+	f()
+	x := 3
+	g := func() int { return x * 2 }
+	t := T{5}
+	get := t.Get
+	inc := (&t).Inc
+	var nilf func()
+	runtime.Breakpoint()
+	fmt.Println(f(), g(), get(), inc(), nilf == nil)
+}
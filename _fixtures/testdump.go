@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+type node struct {
+	Name string
+	Next *node
+	Tags map[string]int
+}
+
+func main() {
+	a := &node{Name: "a", Tags: map[string]int{"x": 1}}
+	b := &node{Name: "b", Next: a}
+	a.Next = b
+	shared := &node{Name: "shared"}
+	pair := [2]*node{shared, shared}
+	byID := map[int]string{1: "one"}
+	// A slice whose length runs past the mapped memory.
+	var x int
+	hdr := [3]uintptr{uintptr(unsafe.Pointer(&x)), 1 << 40, 1 << 40}
+	garbage := *(*[]int)(unsafe.Pointer(&hdr))
+	runtime.Breakpoint()
+	fmt.Println(a, pair, byID, len(garbage))
+}
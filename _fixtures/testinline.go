@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func inlineThis(a int) int {
+	z := a * a
+	return z + a
+}
+
+func main() {
+	var a = len(os.Args)
+	for i := 0; i < 2; i++ {
+		a += inlineThis(a + i)
+	}
+	a -= inlineThis(a)
+	fmt.Println(a)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+type Stringer interface {
+	String() string
+}
+
+type point struct {
+	X, Y int
+}
+
+func (p point) String() string {
+	return fmt.Sprintf("(%d, %d)", p.X, p.Y)
+}
+
+func main() {
+	_, err := os.Open("/nonexistent/file")
+	var nilerr error
+	var s Stringer = point{1, 2}
+	var e interface{} = 42
+	runtime.Breakpoint()
+	fmt.Println(err, nilerr, s, e)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+type point struct {
+	x, y int
+}
+
+func main() {
+	m1 := map[string]int{"one": 1, "two": 2, "three": 3}
+	m2 := map[int]string{}
+	for i := 0; i < 100; i++ {
+		m2[i] = fmt.Sprintf("%d", i*i)
+	}
+	var m3 map[string]int
+	s := []int{1, 2, 3}
+	str := "hello"
+	long := strings.Repeat("a", 100)
+	m4 := map[string]int{long + "1": 1, long + "2": 2}
+	m5 := map[point]string{{1, 2}: "a", {2, 1}: "b"}
+	p1 := point{2, 1}
+	m6 := map[interface{}]int{1: 1, "1": 2, int64(1): 3}
+	runtime.Breakpoint()
+	fmt.Println(m1, m2, m3, m4, m5, m6, p1, s, str, long)
+}
@@ -0,0 +1,15 @@
+package main
+
+import "fmt"
+
+// describe receives its arguments in registers, where its optimized
+// code leaves them.
+//
+//go:noinline
+func describe(name string, n int) string {
+	return fmt.Sprintf("%s=%d", name, n)
+}
+
+func main() {
+	fmt.Println(describe("answer", 42))
+}
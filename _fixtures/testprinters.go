@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+type key string
+
+func main() {
+	t := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	// A zone the debugger's machine doesn't know.
+	tz := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.FixedZone("Mars", 2*3600))
+	n, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+	ip := net.ParseIP("192.168.1.1").To4()
+	mu := new(sync.Mutex)
+	mu.Lock()
+	var sb strings.Builder
+	sb.WriteString("hello")
+	buf := bytes.NewBufferString("unread data")
+	buf.Next(7)
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = context.WithValue(ctx, key("user"), "gopher")
+	runtime.Breakpoint()
+	cancel()
+	mu.Unlock()
+	fmt.Println(t, tz, n, ip, sb.String(), buf, ctx)
+}
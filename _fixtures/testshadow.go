@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func main() {
+	a := 1
+	b := "outer"
+	for i := 0; i < 1; i++ {
+		a := a + 10
+		c := i * 2
+		runtime.Breakpoint()
+		fmt.Println(a, c)
+	}
+	d := 4
+	runtime.Breakpoint()
+	fmt.Println(a, b, d)
+}
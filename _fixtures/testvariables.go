@@ -61,3 +61,5 @@ func foobar(baz string, bar FooBar) {
 func main() {
 	foobar("bazburzum", FooBar{Baz: 10, Bur: "lorem"})
 }
+
+var packageVar = FooBar{Baz: 42, Bur: "package"}
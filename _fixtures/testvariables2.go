@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+type Base struct {
+	ID   int
+	Name string
+}
+
+type Inner struct {
+	Depth int
+}
+
+// Derived embeds a struct and a pointer to a struct.
+type Derived struct {
+	Base
+	*Inner
+	Extra string
+}
+
+func main() {
+	i := 42
+	var (
+		c64  = complex64(1 + 2i)
+		c128 = complex(-1.5, 0.25)
+		r    = 'a'
+		rs   = []rune("héllo")
+		bs   = []byte("hello")
+		bin  = []byte{0xff, 0x00, 0xfe}
+		esc  = "tab\there\nnul\x00bad\xff"
+		up   = uintptr(0x1000)
+		ptr  = unsafe.Pointer(&i)
+		nilp = unsafe.Pointer(nil)
+		anon = struct {
+			A int
+			B struct{ C string }
+		}{1, struct{ C string }{"nested"}}
+		d = Derived{Base{7, "base"}, &Inner{3}, "extra"}
+	)
+	runtime.Breakpoint()
+	fmt.Println(i, c64, c128, r, rs, bs, bin, esc, up, ptr, nilp, anon, d)
+}
@@ -39,11 +39,13 @@ func main() {
 	var addr string
 	var logEnabled bool
 	var headless bool
+	var maxMapValues int
 
 	flag.BoolVar(&printv, "version", false, "Print version number and exit.")
 	flag.StringVar(&addr, "addr", "localhost:0", "Debugging server listen address.")
 	flag.BoolVar(&logEnabled, "log", false, "Enable debugging server logging.")
 	flag.BoolVar(&headless, "headless", false, "Run in headless mode.")
+	flag.IntVar(&maxMapValues, "max-map-values", 64, "Maximum number of entries read when printing a map.")
 	flag.Parse()
 
 	if flag.NFlag() == 0 && len(flag.Args()) == 0 {
@@ -68,12 +70,12 @@ func main() {
 		}
 	}()
 
-	status := run(addr, logEnabled, headless)
+	status := run(addr, logEnabled, headless, maxMapValues)
 	fmt.Println("[Hope I was of service hunting your bug!]")
 	os.Exit(status)
 }
 
-func run(addr string, logEnabled, headless bool) int {
+func run(addr string, logEnabled, headless bool, maxMapValues int) int {
 	// Collect launch arguments
 	var processArgs []string
 	var attachPid int
@@ -133,9 +135,10 @@ func run(addr string, logEnabled, headless bool) int {
 	// Create and start a debugger server
 	var server service.Server
 	server = rpc.NewServer(&service.Config{
-		Listener:    listener,
-		ProcessArgs: processArgs,
-		AttachPid:   attachPid,
+		Listener:     listener,
+		ProcessArgs:  processArgs,
+		AttachPid:    attachPid,
+		MaxMapValues: maxMapValues,
 	}, logEnabled)
 	if err := server.Run(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -0,0 +1,170 @@
+package frame
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	"github.com/derekparker/delve/dwarf/util"
+)
+
+// Pointer encodings used in .eh_frame, see the Linux Standard Base
+// Core Specification, section 10.5.1.
+const (
+	ehPeAbsptr  = 0x00
+	ehPeUleb128 = 0x01
+	ehPeUdata2  = 0x02
+	ehPeUdata4  = 0x03
+	ehPeUdata8  = 0x04
+	ehPeSleb128 = 0x09
+	ehPeSdata2  = 0x0a
+	ehPeSdata4  = 0x0b
+	ehPeSdata8  = 0x0c
+	ehPePcrel   = 0x10
+	ehPeOmit    = 0xff
+)
+
+// ehCIE is a CIE of .eh_frame along with the augmentation data needed
+// to decode the FDEs referring to it.
+type ehCIE struct {
+	*CommonInformationEntry
+	ptrEncoding byte
+	hasAugData  bool
+}
+
+// ParseEhFrame parses the .eh_frame section loaded at addr, which
+// describes how to unwind the frames of code compiled by C compilers.
+// Unlike .debug_frame its addresses can be relative to the section.
+func ParseEhFrame(data []byte, addr uint64) FrameDescriptionEntries {
+	var (
+		entries = NewFrameIndex()
+		cies    = map[int]*ehCIE{}
+	)
+	for off := 0; off+8 <= len(data); {
+		length := int(binary.LittleEndian.Uint32(data[off:]))
+		if length == 0 || length == 0xffffffff || off+4+length > len(data) {
+			// Zero terminator, or 64-bit entries which C toolchains don't emit.
+			break
+		}
+		start := off + 4
+		entry := data[start : start+length]
+		off = start + length
+
+		id := int(binary.LittleEndian.Uint32(entry))
+		if id == 0 {
+			if cie := parseEhCIE(entry[4:]); cie != nil {
+				cies[start-4] = cie
+			}
+			continue
+		}
+		// The CIE pointer is the offset from the pointer itself back to the CIE.
+		cie, ok := cies[start-id]
+		if !ok {
+			continue
+		}
+		buf := bytes.NewBuffer(entry[4:])
+		begin := readEhPointer(buf, cie.ptrEncoding, addr+uint64(start+4))
+		end := readEhPointer(buf, cie.ptrEncoding&0x0f, 0)
+		if cie.hasAugData {
+			n, _ := util.DecodeULEB128(buf)
+			buf.Next(int(n))
+		}
+		entries = append(entries, &FrameDescriptionEntry{
+			Length:       uint32(length),
+			CIE:          cie.CommonInformationEntry,
+			Instructions: buf.Bytes(),
+			begin:        begin,
+			end:          end,
+		})
+	}
+	sort.Sort(entries)
+	return entries
+}
+
+func parseEhCIE(data []byte) *ehCIE {
+	buf := bytes.NewBuffer(data)
+	cie := &ehCIE{CommonInformationEntry: &CommonInformationEntry{}, ptrEncoding: ehPeAbsptr}
+	cie.Version, _ = buf.ReadByte()
+	cie.Augmentation, _ = util.ParseString(buf)
+	cie.CodeAlignmentFactor, _ = util.DecodeULEB128(buf)
+	cie.DataAlignmentFactor, _ = util.DecodeSLEB128(buf)
+	if cie.Version == 1 {
+		ra, _ := buf.ReadByte()
+		cie.ReturnAddressRegister = uint64(ra)
+	} else {
+		cie.ReturnAddressRegister, _ = util.DecodeULEB128(buf)
+	}
+	if len(cie.Augmentation) > 0 && cie.Augmentation[0] == 'z' {
+		cie.hasAugData = true
+		n, _ := util.DecodeULEB128(buf)
+		aug := bytes.NewBuffer(buf.Next(int(n)))
+		for _, c := range cie.Augmentation[1:] {
+			switch c {
+			case 'R':
+				cie.ptrEncoding, _ = aug.ReadByte()
+			case 'P':
+				enc, _ := aug.ReadByte()
+				readEhPointer(aug, enc, 0)
+			case 'L':
+				aug.ReadByte()
+			case 'S', 'B':
+			default:
+				// Unknown augmentation, the rest of the data can't be interpreted.
+				return nil
+			}
+		}
+	} else if cie.Augmentation != "" {
+		return nil
+	}
+	cie.InitialInstructions = buf.Bytes()
+	return cie
+}
+
+// readEhPointer reads a pointer encoded with enc, pc being the address
+// the pointer is read from.
+func readEhPointer(buf *bytes.Buffer, enc byte, pc uint64) uint64 {
+	if enc == ehPeOmit {
+		return 0
+	}
+	var val uint64
+	switch enc & 0x0f {
+	case ehPeAbsptr, ehPeUdata8, ehPeSdata8:
+		val = binary.LittleEndian.Uint64(buf.Next(8))
+	case ehPeUleb128:
+		val, _ = util.DecodeULEB128(buf)
+	case ehPeSleb128:
+		v, _ := util.DecodeSLEB128(buf)
+		val = uint64(v)
+	case ehPeUdata2:
+		val = uint64(binary.LittleEndian.Uint16(buf.Next(2)))
+	case ehPeSdata2:
+		val = uint64(int16(binary.LittleEndian.Uint16(buf.Next(2))))
+	case ehPeUdata4:
+		val = uint64(binary.LittleEndian.Uint32(buf.Next(4)))
+	case ehPeSdata4:
+		val = uint64(int32(binary.LittleEndian.Uint32(buf.Next(4))))
+	}
+	if enc&0x70 == ehPePcrel {
+		val += pc
+	}
+	return val
+}
+
+// Merge returns the entries of fdes and other, sorted by address. Entries
+// of other overlapping the ones of fdes are dropped.
+func (fdes FrameDescriptionEntries) Merge(other FrameDescriptionEntries) FrameDescriptionEntries {
+	merged := make(FrameDescriptionEntries, 0, len(fdes)+len(other))
+	merged = append(merged, fdes...)
+	for _, fde := range other {
+		if _, err := fdes.FDEForPC(fde.Begin()); err == nil {
+			continue
+		}
+		merged = append(merged, fde)
+	}
+	sort.Sort(merged)
+	return merged
+}
+
+func (fdes FrameDescriptionEntries) Len() int           { return len(fdes) }
+func (fdes FrameDescriptionEntries) Less(i, j int) bool { return fdes[i].begin < fdes[j].begin }
+func (fdes FrameDescriptionEntries) Swap(i, j int)      { fdes[i], fdes[j] = fdes[j], fdes[i] }
@@ -0,0 +1,66 @@
+package frame
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseEhFrame(t *testing.T) {
+	const addr = 0x1000
+	cie := []byte{
+		0, 0, 0, 0, // CIE id
+		1,           // version
+		'z', 'R', 0, // augmentation
+		1,    // code alignment factor
+		0x78, // data alignment factor, -8
+		16,   // return address register
+		1,    // augmentation data length
+		ehPePcrel | ehPeSdata4,
+		DW_CFA_def_cfa, 7, 8,
+		DW_CFA_offset | 16, 1,
+		DW_CFA_nop, DW_CFA_nop,
+	}
+	fde := []byte{
+		0, 0, 0, 0, // CIE pointer
+		0, 0, 0, 0, // pc begin
+		0x20, 0, 0, 0, // pc range
+		0, // augmentation data length
+		DW_CFA_advance_loc | 4,
+		DW_CFA_def_cfa_offset, 16,
+		DW_CFA_nop,
+	}
+
+	var data []byte
+	appendEntry := func(entry []byte) int {
+		off := len(data)
+		data = append(data, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(data[off:], uint32(len(entry)))
+		data = append(data, entry...)
+		return off
+	}
+	cieOff := appendEntry(cie)
+	fdeOff := len(data)
+	// The CIE pointer is relative to itself, pc begin to itself as well.
+	binary.LittleEndian.PutUint32(fde[0:], uint32(fdeOff+4-cieOff))
+	binary.LittleEndian.PutUint32(fde[4:], uint32(0x2000-(addr+fdeOff+8)))
+	appendEntry(fde)
+	data = append(data, 0, 0, 0, 0)
+
+	fdes := ParseEhFrame(data, addr)
+	if len(fdes) != 1 {
+		t.Fatalf("expected 1 FDE, got %d", len(fdes))
+	}
+	if fdes[0].Begin() != 0x2000 || fdes[0].End() != 0x2020 {
+		t.Fatalf("FDE covers %#x-%#x, expected 0x2000-0x2020", fdes[0].Begin(), fdes[0].End())
+	}
+	if _, err := fdes.FDEForPC(0x2020); err == nil {
+		t.Fatal("found FDE for PC past the end of the only FDE")
+	}
+	fctx := fdes[0].EstablishFrame(0x2004)
+	if fctx.CFARegister() != 7 || fctx.CFAOffset() != 16 {
+		t.Fatalf("CFA = r%d%+d, expected r7+16", fctx.CFARegister(), fctx.CFAOffset())
+	}
+	if rule := fctx.Regs()[16]; rule.Rule() != RuleOffset || rule.Offset() != -8 {
+		t.Fatalf("return address rule %d at %d, expected %d at -8", rule.Rule(), rule.Offset(), RuleOffset)
+	}
+}
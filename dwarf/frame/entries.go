@@ -74,7 +74,7 @@ func (fdes FrameDescriptionEntries) FDEForPC(pc uint64) (*FrameDescriptionEntry,
 		}
 		return true
 	})
-	if idx == len(fdes) {
+	if idx == len(fdes) || !fdes[idx].Cover(pc) {
 		return nil, fmt.Errorf("could not find FDE for PC %#v", pc)
 	}
 	return fdes[idx], nil
@@ -22,13 +22,19 @@ type DWRule struct {
 	expression []byte
 }
 
+// rowState is the part of a row saved by DW_CFA_remember_state.
+type rowState struct {
+	cfa  CurrentFrameAddress
+	regs map[uint64]DWRule
+}
+
 type FrameContext struct {
 	loc           uint64
 	address       uint64
 	cfa           CurrentFrameAddress
 	regs          map[uint64]DWRule
 	initialRegs   map[uint64]DWRule
-	prevRegs      map[uint64]DWRule
+	stack         []rowState
 	buf           *bytes.Buffer
 	cie           *CommonInformationEntry
 	codeAlignment uint64
@@ -39,6 +45,64 @@ func (fctx *FrameContext) CFAOffset() int64 {
 	return fctx.cfa.offset
 }
 
+// CFARegister returns the register the CFA is an offset from.
+func (fctx *FrameContext) CFARegister() uint64 {
+	return fctx.cfa.register
+}
+
+// CFAExpression returns the DWARF expression computing the CFA, or nil if
+// the CFA is an offset from CFARegister.
+func (fctx *FrameContext) CFAExpression() []byte {
+	if fctx.cfa.rule != rule_expression {
+		return nil
+	}
+	return fctx.cfa.expression
+}
+
+// Regs returns the rules to recover the value registers had in the
+// caller, by register number. Registers without a rule have no
+// specified value.
+func (fctx *FrameContext) Regs() map[uint64]DWRule {
+	return fctx.regs
+}
+
+// RetAddrReg returns the number of the register holding the return address.
+func (fctx *FrameContext) RetAddrReg() uint64 {
+	return fctx.cie.ReturnAddressRegister
+}
+
+// Rule kinds of a DWRule, see section 6.4.1 of the DWARF 4 standard.
+const (
+	RuleUndefined     = rule_undefined
+	RuleSameVal       = rule_sameval
+	RuleOffset        = rule_offset
+	RuleValOffset     = rule_valoffset
+	RuleRegister      = rule_register
+	RuleExpression    = rule_expression
+	RuleValExpression = rule_valexpression
+	RuleArchitectural = rule_architectural
+)
+
+// Rule returns the kind of the rule.
+func (r DWRule) Rule() byte {
+	return r.rule
+}
+
+// Offset returns the offset from the CFA of RuleOffset and RuleValOffset.
+func (r DWRule) Offset() int64 {
+	return r.offset
+}
+
+// Reg returns the register holding the value of RuleRegister.
+func (r DWRule) Reg() uint64 {
+	return r.newreg
+}
+
+// Expression returns the DWARF expression of RuleExpression and RuleValExpression.
+func (r DWRule) Expression() []byte {
+	return r.expression
+}
+
 // Instructions used to recreate the table from the .debug_frame data.
 const (
 	DW_CFA_nop                = 0x0        // No ops
@@ -125,13 +189,15 @@ func executeCIEInstructions(cie *CommonInformationEntry) *FrameContext {
 		cie:           cie,
 		regs:          make(map[uint64]DWRule),
 		initialRegs:   make(map[uint64]DWRule),
-		prevRegs:      make(map[uint64]DWRule),
 		codeAlignment: cie.CodeAlignmentFactor,
 		dataAlignment: cie.DataAlignmentFactor,
 		buf:           bytes.NewBuffer(initialInstructions),
 	}
 
 	frame.ExecuteDwarfProgram()
+	for reg, rule := range frame.regs {
+		frame.initialRegs[reg] = rule
+	}
 	return frame
 }
 
@@ -272,7 +338,7 @@ func restore(frame *FrameContext) {
 	reg := uint64(b & low_6_offset)
 	oldrule, ok := frame.initialRegs[reg]
 	if ok {
-		frame.regs[reg] = DWRule{offset: oldrule.offset, rule: rule_offset}
+		frame.regs[reg] = oldrule
 	} else {
 		frame.regs[reg] = DWRule{rule: rule_undefined}
 	}
@@ -291,7 +357,7 @@ func offsetextended(frame *FrameContext) {
 		offset, _ = util.DecodeULEB128(frame.buf)
 	)
 
-	frame.regs[reg] = DWRule{offset: int64(offset), rule: rule_offset}
+	frame.regs[reg] = DWRule{offset: int64(offset) * frame.dataAlignment, rule: rule_offset}
 }
 
 func undefined(frame *FrameContext) {
@@ -311,11 +377,21 @@ func register(frame *FrameContext) {
 }
 
 func rememberstate(frame *FrameContext) {
-	frame.prevRegs = frame.regs
+	regs := make(map[uint64]DWRule, len(frame.regs))
+	for reg, rule := range frame.regs {
+		regs[reg] = rule
+	}
+	frame.stack = append(frame.stack, rowState{cfa: frame.cfa, regs: regs})
 }
 
 func restorestate(frame *FrameContext) {
-	frame.regs = frame.prevRegs
+	if len(frame.stack) == 0 {
+		return
+	}
+	state := frame.stack[len(frame.stack)-1]
+	frame.stack = frame.stack[:len(frame.stack)-1]
+	frame.cfa = state.cfa
+	frame.regs = state.regs
 }
 
 func restoreextended(frame *FrameContext) {
@@ -323,7 +399,7 @@ func restoreextended(frame *FrameContext) {
 
 	oldrule, ok := frame.initialRegs[reg]
 	if ok {
-		frame.regs[reg] = DWRule{offset: oldrule.offset, rule: rule_offset}
+		frame.regs[reg] = oldrule
 	} else {
 		frame.regs[reg] = DWRule{rule: rule_undefined}
 	}
@@ -0,0 +1,118 @@
+package frame
+
+import "testing"
+
+func TestEstablishFrame(t *testing.T) {
+	const (
+		rbp = 6
+		rsp = 7
+		ra  = 16
+	)
+	cie := &CommonInformationEntry{
+		CodeAlignmentFactor:   1,
+		DataAlignmentFactor:   -8,
+		ReturnAddressRegister: ra,
+		InitialInstructions: []byte{
+			DW_CFA_def_cfa, rsp, 8,
+			DW_CFA_offset | ra, 1,
+		},
+	}
+	fde := &FrameDescriptionEntry{
+		CIE:   cie,
+		begin: 0,
+		end:   10,
+		Instructions: []byte{
+			DW_CFA_advance_loc | 1,
+			DW_CFA_def_cfa_offset, 16,
+			DW_CFA_offset_extended, rbp, 2,
+			DW_CFA_remember_state,
+			DW_CFA_advance_loc | 1,
+			DW_CFA_restore | rbp,
+			DW_CFA_advance_loc | 1,
+			DW_CFA_restore_state,
+		},
+	}
+
+	testcases := []struct {
+		pc        uint64
+		cfaOffset int64
+		rbpRule   byte
+		rbpOffset int64
+	}{
+		{0, 8, RuleUndefined, 0},
+		{1, 16, RuleOffset, -16},
+		{2, 16, RuleUndefined, 0},
+		{3, 16, RuleOffset, -16},
+	}
+	for _, tc := range testcases {
+		fctx := fde.EstablishFrame(tc.pc)
+		if fctx.CFARegister() != rsp || fctx.CFAOffset() != tc.cfaOffset {
+			t.Errorf("%d: CFA = r%d%+d, expected r%d%+d", tc.pc, fctx.CFARegister(), fctx.CFAOffset(), rsp, tc.cfaOffset)
+		}
+		if rule := fctx.Regs()[ra]; rule.Rule() != RuleOffset || rule.Offset() != -8 {
+			t.Errorf("%d: return address rule %d at %d, expected %d at -8", tc.pc, rule.Rule(), rule.Offset(), RuleOffset)
+		}
+		if rule := fctx.Regs()[rbp]; rule.Rule() != tc.rbpRule || rule.Offset() != tc.rbpOffset {
+			t.Errorf("%d: rbp rule %d at %d, expected %d at %d", tc.pc, rule.Rule(), rule.Offset(), tc.rbpRule, tc.rbpOffset)
+		}
+	}
+}
+
+func TestRestoreStateCFA(t *testing.T) {
+	const (
+		rbp = 6
+		rsp = 7
+		ra  = 16
+	)
+	cie := &CommonInformationEntry{
+		CodeAlignmentFactor:   1,
+		DataAlignmentFactor:   -8,
+		ReturnAddressRegister: ra,
+		InitialInstructions: []byte{
+			DW_CFA_def_cfa, rsp, 8,
+			DW_CFA_offset | ra, 1,
+		},
+	}
+	// Shaped like a gcc epilogue returning from the middle of a function:
+	// the frame is torn down before ret and set up again for the code
+	// following it.
+	fde := &FrameDescriptionEntry{
+		CIE:   cie,
+		begin: 0,
+		end:   10,
+		Instructions: []byte{
+			DW_CFA_advance_loc | 1,
+			DW_CFA_def_cfa_offset, 16,
+			DW_CFA_offset_extended, rbp, 2,
+			DW_CFA_advance_loc | 1,
+			DW_CFA_def_cfa_register, rbp,
+			DW_CFA_advance_loc | 1,
+			DW_CFA_remember_state,
+			DW_CFA_def_cfa, rsp, 8,
+			DW_CFA_restore | rbp,
+			DW_CFA_advance_loc | 1,
+			DW_CFA_restore_state,
+		},
+	}
+
+	testcases := []struct {
+		pc        uint64
+		cfaReg    uint64
+		cfaOffset int64
+		rbpRule   byte
+	}{
+		{2, rbp, 16, RuleOffset},
+		{3, rsp, 8, RuleUndefined},
+		{4, rbp, 16, RuleOffset},
+		{9, rbp, 16, RuleOffset},
+	}
+	for _, tc := range testcases {
+		fctx := fde.EstablishFrame(tc.pc)
+		if fctx.CFARegister() != tc.cfaReg || fctx.CFAOffset() != tc.cfaOffset {
+			t.Errorf("%d: CFA = r%d%+d, expected r%d%+d", tc.pc, fctx.CFARegister(), fctx.CFAOffset(), tc.cfaReg, tc.cfaOffset)
+		}
+		if rule := fctx.Regs()[rbp]; rule.Rule() != tc.rbpRule {
+			t.Errorf("%d: rbp rule %d, expected %d", tc.pc, rule.Rule(), tc.rbpRule)
+		}
+	}
+}
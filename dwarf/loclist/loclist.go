@@ -0,0 +1,68 @@
+// Package loclist reads the location lists of the .debug_loc section,
+// which describe where variables are stored as execution moves through
+// a function.
+package loclist
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Reader finds location expressions in the contents of a .debug_loc section.
+type Reader struct {
+	data    []byte
+	ptrSize int
+}
+
+// New returns a reader for the .debug_loc section data of a program
+// whose addresses are ptrSize bytes.
+func New(data []byte, ptrSize int) *Reader {
+	return &Reader{data: data, ptrSize: ptrSize}
+}
+
+// Find returns the location expression of the list at offset off that
+// applies at pc, nil if the list doesn't describe pc. base is the base
+// address of the compile unit of the list, the lowest address of its code.
+func (r *Reader) Find(off int64, base, pc uint64) ([]byte, error) {
+	if off < 0 || off >= int64(len(r.data)) {
+		return nil, fmt.Errorf("location list offset %#x out of .debug_loc", off)
+	}
+	maxAddr := ^uint64(0) >> uint(64-8*r.ptrSize)
+	data := r.data[off:]
+	for {
+		if len(data) < 2*r.ptrSize {
+			return nil, fmt.Errorf("truncated location list at %#x", off)
+		}
+		begin, end := r.addr(data), r.addr(data[r.ptrSize:])
+		data = data[2*r.ptrSize:]
+
+		switch {
+		case begin == 0 && end == 0:
+			return nil, nil
+		case begin == maxAddr:
+			// Base address selection entry.
+			base = end
+			continue
+		}
+
+		if len(data) < 2 {
+			return nil, fmt.Errorf("truncated location list at %#x", off)
+		}
+		n := int(binary.LittleEndian.Uint16(data))
+		data = data[2:]
+		if len(data) < n {
+			return nil, fmt.Errorf("truncated location list at %#x", off)
+		}
+		if pc >= base+begin && pc < base+end {
+			return data[:n], nil
+		}
+		data = data[n:]
+	}
+}
+
+func (r *Reader) addr(data []byte) uint64 {
+	if r.ptrSize == 4 {
+		return uint64(binary.LittleEndian.Uint32(data))
+	}
+	return binary.LittleEndian.Uint64(data)
+}
@@ -0,0 +1,52 @@
+package loclist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	var buf bytes.Buffer
+	entry := func(begin, end uint64, expr ...byte) {
+		binary.Write(&buf, binary.LittleEndian, begin)
+		binary.Write(&buf, binary.LittleEndian, end)
+		if begin == ^uint64(0) || (begin == 0 && end == 0) {
+			return
+		}
+		binary.Write(&buf, binary.LittleEndian, uint16(len(expr)))
+		buf.Write(expr)
+	}
+	entry(0x10, 0x20, 0x50)
+	entry(0x20, 0x30, 0x91, 0x08)
+	entry(^uint64(0), 0x5000)
+	entry(0x0, 0x10, 0x53)
+	entry(0, 0)
+
+	r := New(buf.Bytes(), 8)
+	testcases := []struct {
+		pc       uint64
+		expected []byte
+	}{
+		{0x1000, nil},
+		{0x1010, []byte{0x50}},
+		{0x101f, []byte{0x50}},
+		{0x1020, []byte{0x91, 0x08}},
+		{0x1030, nil},
+		{0x5008, []byte{0x53}},
+		{0x5010, nil},
+	}
+	for _, tc := range testcases {
+		expr, err := r.Find(0, 0x1000, tc.pc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(expr, tc.expected) {
+			t.Fatalf("pc %#x: expected %#v got %#v", tc.pc, tc.expected, expr)
+		}
+	}
+
+	if _, err := New(buf.Bytes()[:20], 8).Find(0, 0x1000, 0x1040); err == nil {
+		t.Fatal("expected an error reading a truncated list")
+	}
+}
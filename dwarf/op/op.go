@@ -10,72 +10,642 @@ import (
 )
 
 const (
-	DW_OP_addr           = 0x3
-	DW_OP_call_frame_cfa = 0x9c
-	DW_OP_plus           = 0x22
-	DW_OP_consts         = 0x11
-	DW_OP_plus_uconsts   = 0x23
+	DW_OP_addr                = 0x03
+	DW_OP_deref               = 0x06
+	DW_OP_const1u             = 0x08
+	DW_OP_const1s             = 0x09
+	DW_OP_const2u             = 0x0a
+	DW_OP_const2s             = 0x0b
+	DW_OP_const4u             = 0x0c
+	DW_OP_const4s             = 0x0d
+	DW_OP_const8u             = 0x0e
+	DW_OP_const8s             = 0x0f
+	DW_OP_constu              = 0x10
+	DW_OP_consts              = 0x11
+	DW_OP_dup                 = 0x12
+	DW_OP_drop                = 0x13
+	DW_OP_over                = 0x14
+	DW_OP_pick                = 0x15
+	DW_OP_swap                = 0x16
+	DW_OP_rot                 = 0x17
+	DW_OP_xderef              = 0x18
+	DW_OP_abs                 = 0x19
+	DW_OP_and                 = 0x1a
+	DW_OP_div                 = 0x1b
+	DW_OP_minus               = 0x1c
+	DW_OP_mod                 = 0x1d
+	DW_OP_mul                 = 0x1e
+	DW_OP_neg                 = 0x1f
+	DW_OP_not                 = 0x20
+	DW_OP_or                  = 0x21
+	DW_OP_plus                = 0x22
+	DW_OP_plus_uconst         = 0x23
+	DW_OP_shl                 = 0x24
+	DW_OP_shr                 = 0x25
+	DW_OP_shra                = 0x26
+	DW_OP_xor                 = 0x27
+	DW_OP_bra                 = 0x28
+	DW_OP_eq                  = 0x29
+	DW_OP_ge                  = 0x2a
+	DW_OP_gt                  = 0x2b
+	DW_OP_le                  = 0x2c
+	DW_OP_lt                  = 0x2d
+	DW_OP_ne                  = 0x2e
+	DW_OP_skip                = 0x2f
+	DW_OP_lit0                = 0x30
+	DW_OP_lit31               = 0x4f
+	DW_OP_reg0                = 0x50
+	DW_OP_reg31               = 0x6f
+	DW_OP_breg0               = 0x70
+	DW_OP_breg31              = 0x8f
+	DW_OP_regx                = 0x90
+	DW_OP_fbreg               = 0x91
+	DW_OP_bregx               = 0x92
+	DW_OP_piece               = 0x93
+	DW_OP_deref_size          = 0x94
+	DW_OP_xderef_size         = 0x95
+	DW_OP_nop                 = 0x96
+	DW_OP_push_object_address = 0x97
+	DW_OP_call2               = 0x98
+	DW_OP_call4               = 0x99
+	DW_OP_call_ref            = 0x9a
+	DW_OP_form_tls_address    = 0x9b
+	DW_OP_call_frame_cfa      = 0x9c
+	DW_OP_bit_piece           = 0x9d
+	DW_OP_implicit_value      = 0x9e
+	DW_OP_stack_value         = 0x9f
+
+	// DW_OP_plus_uconsts is the old, misspelled name of DW_OP_plus_uconst.
+	DW_OP_plus_uconsts = DW_OP_plus_uconst
+)
+
+// Context is the state of the program location expressions are
+// evaluated against.
+type Context struct {
+	// CFA is the canonical frame address, for DW_OP_call_frame_cfa.
+	CFA int64
+	// FrameBase returns the frame base of the function, for DW_OP_fbreg.
+	// It's only called by expressions using it, and they fail if it's nil.
+	FrameBase func() (int64, error)
+	// Reg returns the value of DWARF register n and whether it is known.
+	// Register operations fail if it's nil.
+	Reg func(n uint64) (uint64, bool)
+	// ReadMemory reads size bytes at addr. Dereferences fail if it's nil.
+	ReadMemory func(addr uint64, size int) ([]byte, error)
+	// PtrSize is the size of addresses, 8 if zero.
+	PtrSize int
+	// Stack holds the values pushed on the stack before the expression
+	// runs, such as the CFA for the register rules of call frame
+	// information.
+	Stack []int64
+}
+
+// PieceKind is where a piece of a value is stored.
+type PieceKind uint8
+
+const (
+	AddrPiece PieceKind = iota // In memory, at Addr.
+	RegPiece                   // In register Reg.
+	ImmPiece                   // Nowhere: the value is Bytes, computed by the expression.
 )
 
-type stackfn func(*bytes.Buffer, []int64, int64) ([]int64, error)
+// Piece is the location of a value, or of part of it for composite
+// locations made of DW_OP_piece and DW_OP_bit_piece operations.
+type Piece struct {
+	Kind  PieceKind
+	Addr  int64
+	Reg   uint64
+	Bytes []byte // The value of ImmPiece pieces, nil if it was optimized away.
+
+	// BitSize is the size of the piece in bits, zero if the location is
+	// not composite and describes the whole value. BitOffset is where the
+	// piece starts in the register, memory or bytes it's stored in.
+	BitSize   int
+	BitOffset int
+}
+
+// machine is the state of the evaluation of an expression.
+type machine struct {
+	instructions []byte
+	buf          *bytes.Buffer
+	stack        []int64
+	ctx          *Context
+	pieces       []Piece
+	// loc is set by operations naming the location of the next piece
+	// instead of pushing an address: registers and implicit values.
+	loc *Piece
+}
+
+type stackfn func(*machine, byte) error
 
-var oplut = map[byte]stackfn{
-	DW_OP_call_frame_cfa: callframecfa,
-	DW_OP_plus:           plus,
-	DW_OP_consts:         consts,
-	DW_OP_addr:           addr,
-	DW_OP_plus_uconsts:   plusuconsts,
+var oplut map[byte]stackfn
+
+func init() {
+	oplut = map[byte]stackfn{
+		DW_OP_addr:           addr,
+		DW_OP_deref:          deref,
+		DW_OP_const1u:        constant,
+		DW_OP_const1s:        constant,
+		DW_OP_const2u:        constant,
+		DW_OP_const2s:        constant,
+		DW_OP_const4u:        constant,
+		DW_OP_const4s:        constant,
+		DW_OP_const8u:        constant,
+		DW_OP_const8s:        constant,
+		DW_OP_constu:         constu,
+		DW_OP_consts:         consts,
+		DW_OP_dup:            dup,
+		DW_OP_drop:           drop,
+		DW_OP_over:           pick,
+		DW_OP_pick:           pick,
+		DW_OP_swap:           swap,
+		DW_OP_rot:            rot,
+		DW_OP_abs:            unop,
+		DW_OP_neg:            unop,
+		DW_OP_not:            unop,
+		DW_OP_and:            binop,
+		DW_OP_div:            binop,
+		DW_OP_minus:          binop,
+		DW_OP_mod:            binop,
+		DW_OP_mul:            binop,
+		DW_OP_or:             binop,
+		DW_OP_plus:           binop,
+		DW_OP_shl:            binop,
+		DW_OP_shr:            binop,
+		DW_OP_shra:           binop,
+		DW_OP_xor:            binop,
+		DW_OP_eq:             binop,
+		DW_OP_ge:             binop,
+		DW_OP_gt:             binop,
+		DW_OP_le:             binop,
+		DW_OP_lt:             binop,
+		DW_OP_ne:             binop,
+		DW_OP_plus_uconst:    plusuconst,
+		DW_OP_skip:           skip,
+		DW_OP_bra:            bra,
+		DW_OP_regx:           reg,
+		DW_OP_fbreg:          fbreg,
+		DW_OP_bregx:          breg,
+		DW_OP_piece:          piece,
+		DW_OP_bit_piece:      piece,
+		DW_OP_deref_size:     deref,
+		DW_OP_nop:            nop,
+		DW_OP_call_frame_cfa: callframecfa,
+		DW_OP_implicit_value: implicitvalue,
+		DW_OP_stack_value:    stackvalue,
+	}
+	for op := byte(DW_OP_lit0); op <= DW_OP_lit31; op++ {
+		oplut[op] = lit
+	}
+	for op := byte(DW_OP_reg0); op <= DW_OP_reg31; op++ {
+		oplut[op] = reg
+	}
+	for op := byte(DW_OP_breg0); op <= DW_OP_breg31; op++ {
+		oplut[op] = breg
+	}
 }
 
+// ExecuteStackProgram executes a location expression computing an address,
+// the address of the CFA being cfa, and returns the address.
 func ExecuteStackProgram(cfa int64, instructions []byte) (int64, error) {
-	stack := make([]int64, 0, 3)
-	buf := bytes.NewBuffer(instructions)
+	pieces, err := Execute(instructions, &Context{CFA: cfa})
+	if err != nil {
+		return 0, err
+	}
+	if len(pieces) != 1 || pieces[0].Kind != AddrPiece || pieces[0].BitSize != 0 {
+		return 0, errors.New("location is not an address")
+	}
+	return pieces[0].Addr, nil
+}
 
-	for ocfaode, err := buf.ReadByte(); err == nil; ocfaode, err = buf.ReadByte() {
-		fn, ok := oplut[ocfaode]
+// Execute evaluates the location expression instructions in ctx and
+// returns the location it describes: a single piece with a zero BitSize
+// for values stored in one place, the pieces in order otherwise.
+func Execute(instructions []byte, ctx *Context) ([]Piece, error) {
+	m := &machine{
+		instructions: instructions,
+		buf:          bytes.NewBuffer(instructions),
+		stack:        append(make([]int64, 0, 3), ctx.Stack...),
+		ctx:          ctx,
+	}
+
+	for opcode, err := m.buf.ReadByte(); err == nil; opcode, err = m.buf.ReadByte() {
+		fn, ok := oplut[opcode]
 		if !ok {
-			return 0, fmt.Errorf("invalid instruction %#v", ocfaode)
+			return nil, fmt.Errorf("invalid instruction %#v", opcode)
 		}
 
-		stack, err = fn(buf, stack, cfa)
-		if err != nil {
-			return 0, err
+		if err := fn(m, opcode); err != nil {
+			return nil, err
 		}
 	}
 
-	if len(stack) == 0 {
-		return 0, errors.New("empty OP stack")
+	if m.pieces != nil {
+		return m.pieces, nil
+	}
+	if m.loc != nil {
+		return []Piece{*m.loc}, nil
+	}
+	if len(m.stack) == 0 {
+		return nil, errors.New("empty OP stack")
+	}
+	return []Piece{{Kind: AddrPiece, Addr: m.stack[len(m.stack)-1]}}, nil
+}
+
+func (m *machine) ptrSize() int {
+	if m.ctx.PtrSize == 0 {
+		return 8
+	}
+	return m.ctx.PtrSize
+}
+
+func (m *machine) push(n int64) {
+	m.stack = append(m.stack, n)
+}
+
+func (m *machine) pop() (int64, error) {
+	if len(m.stack) == 0 {
+		return 0, errors.New("OP stack underflow")
+	}
+	n := m.stack[len(m.stack)-1]
+	m.stack = m.stack[:len(m.stack)-1]
+	return n, nil
+}
+
+// next reads the n bytes of an operand.
+func (m *machine) next(n int) ([]byte, error) {
+	b := m.buf.Next(n)
+	if len(b) != n {
+		return nil, errors.New("truncated OP operand")
+	}
+	return b, nil
+}
+
+// jump moves execution off bytes from the current instruction.
+func (m *machine) jump(off int16) error {
+	pos := len(m.instructions) - m.buf.Len() + int(off)
+	if pos < 0 || pos > len(m.instructions) {
+		return fmt.Errorf("branch out of the expression to %d", pos)
+	}
+	m.buf = bytes.NewBuffer(m.instructions[pos:])
+	return nil
+}
+
+func (m *machine) register(n uint64) (int64, error) {
+	if m.ctx.Reg == nil {
+		return 0, fmt.Errorf("register %d not available", n)
+	}
+	val, ok := m.ctx.Reg(n)
+	if !ok {
+		return 0, fmt.Errorf("register %d not available", n)
+	}
+	return int64(val), nil
+}
+
+func (m *machine) readMemory(addr int64, size int) (int64, error) {
+	if m.ctx.ReadMemory == nil {
+		return 0, fmt.Errorf("could not read memory at %#x", addr)
 	}
+	b, err := m.ctx.ReadMemory(uint64(addr), size)
+	if err != nil {
+		return 0, err
+	}
+	var val [8]byte
+	copy(val[:], b)
+	return int64(binary.LittleEndian.Uint64(val[:])), nil
+}
 
-	return stack[len(stack)-1], nil
+func callframecfa(m *machine, opcode byte) error {
+	m.push(m.ctx.CFA)
+	return nil
 }
 
-func callframecfa(buf *bytes.Buffer, stack []int64, cfa int64) ([]int64, error) {
-	return append(stack, int64(cfa)), nil
+func addr(m *machine, opcode byte) error {
+	b, err := m.next(m.ptrSize())
+	if err != nil {
+		return err
+	}
+	var val [8]byte
+	copy(val[:], b)
+	m.push(int64(binary.LittleEndian.Uint64(val[:])))
+	return nil
 }
 
-func addr(buf *bytes.Buffer, stack []int64, cfa int64) ([]int64, error) {
-	return append(stack, int64(binary.LittleEndian.Uint64(buf.Next(8)))), nil
+func deref(m *machine, opcode byte) error {
+	size := m.ptrSize()
+	if opcode == DW_OP_deref_size {
+		b, err := m.next(1)
+		if err != nil {
+			return err
+		}
+		size = int(b[0])
+		if size > 8 {
+			return fmt.Errorf("invalid DW_OP_deref_size size %d", size)
+		}
+	}
+	addr, err := m.pop()
+	if err != nil {
+		return err
+	}
+	val, err := m.readMemory(addr, size)
+	if err != nil {
+		return err
+	}
+	m.push(val)
+	return nil
 }
 
-func plus(buf *bytes.Buffer, stack []int64, cfa int64) ([]int64, error) {
+func constant(m *machine, opcode byte) error {
 	var (
-		slen   = len(stack)
-		digits = stack[slen-2 : slen]
-		st     = stack[:slen-2]
+		size   = 1 << ((opcode - DW_OP_const1u) / 2)
+		signed = (opcode-DW_OP_const1u)%2 == 1
 	)
+	b, err := m.next(size)
+	if err != nil {
+		return err
+	}
+	var n int64
+	switch size {
+	case 1:
+		n = int64(b[0])
+		if signed {
+			n = int64(int8(b[0]))
+		}
+	case 2:
+		n = int64(binary.LittleEndian.Uint16(b))
+		if signed {
+			n = int64(int16(n))
+		}
+	case 4:
+		n = int64(binary.LittleEndian.Uint32(b))
+		if signed {
+			n = int64(int32(n))
+		}
+	case 8:
+		n = int64(binary.LittleEndian.Uint64(b))
+	}
+	m.push(n)
+	return nil
+}
+
+func constu(m *machine, opcode byte) error {
+	num, _ := util.DecodeULEB128(m.buf)
+	m.push(int64(num))
+	return nil
+}
+
+func consts(m *machine, opcode byte) error {
+	num, _ := util.DecodeSLEB128(m.buf)
+	m.push(num)
+	return nil
+}
+
+func lit(m *machine, opcode byte) error {
+	m.push(int64(opcode - DW_OP_lit0))
+	return nil
+}
+
+func dup(m *machine, opcode byte) error {
+	if len(m.stack) == 0 {
+		return errors.New("OP stack underflow")
+	}
+	m.push(m.stack[len(m.stack)-1])
+	return nil
+}
+
+func drop(m *machine, opcode byte) error {
+	_, err := m.pop()
+	return err
+}
+
+func pick(m *machine, opcode byte) error {
+	idx := 1
+	if opcode == DW_OP_pick {
+		b, err := m.next(1)
+		if err != nil {
+			return err
+		}
+		idx = int(b[0])
+	}
+	if idx >= len(m.stack) {
+		return errors.New("OP stack underflow")
+	}
+	m.push(m.stack[len(m.stack)-1-idx])
+	return nil
+}
+
+func swap(m *machine, opcode byte) error {
+	n := len(m.stack)
+	if n < 2 {
+		return errors.New("OP stack underflow")
+	}
+	m.stack[n-1], m.stack[n-2] = m.stack[n-2], m.stack[n-1]
+	return nil
+}
+
+func rot(m *machine, opcode byte) error {
+	n := len(m.stack)
+	if n < 3 {
+		return errors.New("OP stack underflow")
+	}
+	m.stack[n-1], m.stack[n-2], m.stack[n-3] = m.stack[n-2], m.stack[n-3], m.stack[n-1]
+	return nil
+}
+
+func unop(m *machine, opcode byte) error {
+	n, err := m.pop()
+	if err != nil {
+		return err
+	}
+	switch opcode {
+	case DW_OP_abs:
+		if n < 0 {
+			n = -n
+		}
+	case DW_OP_neg:
+		n = -n
+	case DW_OP_not:
+		n = ^n
+	}
+	m.push(n)
+	return nil
+}
+
+func binop(m *machine, opcode byte) error {
+	b, err := m.pop()
+	if err != nil {
+		return err
+	}
+	a, err := m.pop()
+	if err != nil {
+		return err
+	}
+	var r int64
+	switch opcode {
+	case DW_OP_and:
+		r = a & b
+	case DW_OP_or:
+		r = a | b
+	case DW_OP_xor:
+		r = a ^ b
+	case DW_OP_plus:
+		r = a + b
+	case DW_OP_minus:
+		r = a - b
+	case DW_OP_mul:
+		r = a * b
+	case DW_OP_div, DW_OP_mod:
+		if b == 0 {
+			return errors.New("division by zero")
+		}
+		if opcode == DW_OP_div {
+			r = a / b
+		} else {
+			r = int64(uint64(a) % uint64(b))
+		}
+	case DW_OP_shl:
+		r = a << uint64(b)
+	case DW_OP_shr:
+		r = int64(uint64(a) >> uint64(b))
+	case DW_OP_shra:
+		r = a >> uint64(b)
+	case DW_OP_eq:
+		r = boolToInt(a == b)
+	case DW_OP_ne:
+		r = boolToInt(a != b)
+	case DW_OP_ge:
+		r = boolToInt(a >= b)
+	case DW_OP_gt:
+		r = boolToInt(a > b)
+	case DW_OP_le:
+		r = boolToInt(a <= b)
+	case DW_OP_lt:
+		r = boolToInt(a < b)
+	}
+	m.push(r)
+	return nil
+}
 
-	return append(st, digits[0]+digits[1]), nil
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func plusuconst(m *machine, opcode byte) error {
+	n, err := m.pop()
+	if err != nil {
+		return err
+	}
+	num, _ := util.DecodeULEB128(m.buf)
+	m.push(n + int64(num))
+	return nil
+}
+
+func skip(m *machine, opcode byte) error {
+	b, err := m.next(2)
+	if err != nil {
+		return err
+	}
+	return m.jump(int16(binary.LittleEndian.Uint16(b)))
+}
+
+func bra(m *machine, opcode byte) error {
+	b, err := m.next(2)
+	if err != nil {
+		return err
+	}
+	cond, err := m.pop()
+	if err != nil {
+		return err
+	}
+	if cond == 0 {
+		return nil
+	}
+	return m.jump(int16(binary.LittleEndian.Uint16(b)))
+}
+
+func reg(m *machine, opcode byte) error {
+	n := uint64(opcode - DW_OP_reg0)
+	if opcode == DW_OP_regx {
+		n, _ = util.DecodeULEB128(m.buf)
+	}
+	m.loc = &Piece{Kind: RegPiece, Reg: n}
+	return nil
+}
+
+func breg(m *machine, opcode byte) error {
+	n := uint64(opcode - DW_OP_breg0)
+	if opcode == DW_OP_bregx {
+		n, _ = util.DecodeULEB128(m.buf)
+	}
+	off, _ := util.DecodeSLEB128(m.buf)
+	val, err := m.register(n)
+	if err != nil {
+		return err
+	}
+	m.push(val + off)
+	return nil
+}
+
+func fbreg(m *machine, opcode byte) error {
+	off, _ := util.DecodeSLEB128(m.buf)
+	if m.ctx.FrameBase == nil {
+		return errors.New("frame base not available")
+	}
+	base, err := m.ctx.FrameBase()
+	if err != nil {
+		return err
+	}
+	m.push(base + off)
+	return nil
+}
+
+func piece(m *machine, opcode byte) error {
+	size, _ := util.DecodeULEB128(m.buf)
+	p := Piece{BitSize: int(size) * 8}
+	if opcode == DW_OP_bit_piece {
+		off, _ := util.DecodeULEB128(m.buf)
+		p.BitSize, p.BitOffset = int(size), int(off)
+	}
+
+	switch {
+	case m.loc != nil:
+		p.Kind, p.Reg, p.Bytes = m.loc.Kind, m.loc.Reg, m.loc.Bytes
+		m.loc = nil
+	case len(m.stack) == 0:
+		// An empty location: this piece of the value was optimized away.
+		p.Kind = ImmPiece
+	default:
+		p.Kind = AddrPiece
+		p.Addr, _ = m.pop()
+	}
+	m.pieces = append(m.pieces, p)
+	return nil
 }
 
-func plusuconsts(buf *bytes.Buffer, stack []int64, cfa int64) ([]int64, error) {
-	slen := len(stack)
-	num, _ := util.DecodeULEB128(buf)
-	stack[slen-1] = stack[slen-1] + int64(num)
-	return stack, nil
+func nop(m *machine, opcode byte) error {
+	return nil
 }
 
-func consts(buf *bytes.Buffer, stack []int64, cfa int64) ([]int64, error) {
-	num, _ := util.DecodeSLEB128(buf)
-	return append(stack, num), nil
+func implicitvalue(m *machine, opcode byte) error {
+	size, _ := util.DecodeULEB128(m.buf)
+	b, err := m.next(int(size))
+	if err != nil {
+		return err
+	}
+	m.loc = &Piece{Kind: ImmPiece, Bytes: append([]byte(nil), b...)}
+	return nil
+}
+
+func stackvalue(m *machine, opcode byte) error {
+	n, err := m.pop()
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(n))
+	m.loc = &Piece{Kind: ImmPiece, Bytes: b}
+	return nil
 }
@@ -1,6 +1,10 @@
 package op
 
-import "testing"
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
 
 func TestExecuteStackProgram(t *testing.T) {
 	var (
@@ -16,3 +20,83 @@ func TestExecuteStackProgram(t *testing.T) {
 		t.Fatalf("actual %d != expected %d", actual, expected)
 	}
 }
+
+func TestExecuteLocations(t *testing.T) {
+	regs := map[uint64]uint64{0: 42, 7: 0x1000}
+	mem := map[uint64]uint64{0x1010: 0xc0ffee}
+	ctx := &Context{
+		CFA:       0x2000,
+		FrameBase: func() (int64, error) { return 0x2000, nil },
+		Reg: func(n uint64) (uint64, bool) {
+			v, ok := regs[n]
+			return v, ok
+		},
+		ReadMemory: func(addr uint64, size int) ([]byte, error) {
+			b := make([]byte, 8)
+			binary.LittleEndian.PutUint64(b, mem[addr])
+			return b[:size], nil
+		},
+	}
+
+	testcases := []struct {
+		name         string
+		instructions []byte
+		expected     []Piece
+	}{
+		{"fbreg", []byte{DW_OP_fbreg, 0x78}, []Piece{{Kind: AddrPiece, Addr: 0x1ff8}}},
+		{"breg", []byte{DW_OP_breg0 + 7, 0x10}, []Piece{{Kind: AddrPiece, Addr: 0x1010}}},
+		{"deref", []byte{DW_OP_breg0 + 7, 0x10, DW_OP_deref}, []Piece{{Kind: AddrPiece, Addr: 0xc0ffee}}},
+		{"deref_size", []byte{DW_OP_breg0 + 7, 0x10, DW_OP_deref_size, 1}, []Piece{{Kind: AddrPiece, Addr: 0xee}}},
+		{"reg", []byte{DW_OP_reg0}, []Piece{{Kind: RegPiece, Reg: 0}}},
+		{"regx", []byte{DW_OP_regx, 17}, []Piece{{Kind: RegPiece, Reg: 17}}},
+		{"arithmetic", []byte{DW_OP_lit0 + 7, DW_OP_lit0 + 3, DW_OP_minus, DW_OP_const1s, 0xfe, DW_OP_mul, DW_OP_neg, DW_OP_lit0 + 2, DW_OP_shl}, []Piece{{Kind: AddrPiece, Addr: 32}}},
+		{"stack ops", []byte{DW_OP_lit0 + 1, DW_OP_lit0 + 2, DW_OP_lit0 + 3, DW_OP_rot, DW_OP_swap, DW_OP_over, DW_OP_minus, DW_OP_pick, 1, DW_OP_plus, DW_OP_dup, DW_OP_drop}, []Piece{{Kind: AddrPiece, Addr: 1}}},
+		{"bra", []byte{DW_OP_lit0 + 1, DW_OP_bra, 4, 0, DW_OP_lit0 + 5, DW_OP_skip, 1, 0, DW_OP_lit0 + 9}, []Piece{{Kind: AddrPiece, Addr: 9}}},
+		{"skip", []byte{DW_OP_lit0, DW_OP_bra, 4, 0, DW_OP_lit0 + 5, DW_OP_skip, 1, 0, DW_OP_lit0 + 9}, []Piece{{Kind: AddrPiece, Addr: 5}}},
+		{"stack_value", []byte{DW_OP_breg0, 1, DW_OP_stack_value}, []Piece{{Kind: ImmPiece, Bytes: []byte{43, 0, 0, 0, 0, 0, 0, 0}}}},
+		{"implicit_value", []byte{DW_OP_implicit_value, 2, 0xab, 0xcd}, []Piece{{Kind: ImmPiece, Bytes: []byte{0xab, 0xcd}}}},
+		{"pieces", []byte{DW_OP_reg0, DW_OP_piece, 8, DW_OP_call_frame_cfa, DW_OP_piece, 8, DW_OP_piece, 4, DW_OP_reg0 + 3, DW_OP_bit_piece, 3, 5},
+			[]Piece{
+				{Kind: RegPiece, Reg: 0, BitSize: 64},
+				{Kind: AddrPiece, Addr: 0x2000, BitSize: 64},
+				{Kind: ImmPiece, BitSize: 32},
+				{Kind: RegPiece, Reg: 3, BitSize: 3, BitOffset: 5}}},
+	}
+
+	for _, tc := range testcases {
+		pieces, err := Execute(tc.instructions, ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(pieces, tc.expected) {
+			t.Fatalf("%s: expected %#v got %#v", tc.name, tc.expected, pieces)
+		}
+	}
+
+	for _, instructions := range [][]byte{
+		{DW_OP_plus},
+		{DW_OP_breg0 + 5, 0},
+		{DW_OP_lit0, DW_OP_lit0, DW_OP_div},
+		{DW_OP_skip, 0x80, 0},
+		{0x01},
+	} {
+		if _, err := Execute(instructions, ctx); err == nil {
+			t.Fatalf("expected an error executing %#v", instructions)
+		}
+	}
+}
+
+func TestExecuteInitialStack(t *testing.T) {
+	// Register rules of call frame information start with the CFA pushed.
+	ctx := &Context{CFA: 0x2000, Stack: []int64{0x2000}}
+	pieces, err := Execute([]byte{DW_OP_lit0 + 8, DW_OP_minus}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pieces) != 1 || pieces[0].Addr != 0x1ff8 {
+		t.Fatalf("wrong location %#v", pieces)
+	}
+	if len(ctx.Stack) != 1 || ctx.Stack[0] != 0x2000 {
+		t.Fatalf("initial stack modified: %#v", ctx.Stack)
+	}
+}
@@ -288,6 +288,28 @@ func (reader *Reader) NextMemberVariable() (*dwarf.Entry, error) {
 	return nil, nil
 }
 
+// NextType moves the reader to the next debug entry that describes a type.
+// Types local to sub programs are skipped.
+func (reader *Reader) NextType() (*dwarf.Entry, error) {
+	for entry, err := reader.Next(); entry != nil; entry, err = reader.Next() {
+		if err != nil {
+			return nil, err
+		}
+
+		switch entry.Tag {
+		case dwarf.TagArrayType, dwarf.TagBaseType, dwarf.TagClassType, dwarf.TagEnumerationType,
+			dwarf.TagPointerType, dwarf.TagStructType, dwarf.TagSubroutineType, dwarf.TagTypedef,
+			dwarf.TagUnionType, dwarf.TagUnspecifiedType:
+			return entry, nil
+		case dwarf.TagSubprogram:
+			reader.SkipChildren()
+		}
+	}
+
+	// No more items
+	return nil, nil
+}
+
 // NextPackageVariable moves the reader to the next debug entry that describes a package variable.
 // Any TagVariable entry that is not inside a sub prgram entry and is marked external is considered a package variable.
 func (reader *Reader) NextPackageVariable() (*dwarf.Entry, error) {
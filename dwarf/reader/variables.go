@@ -0,0 +1,78 @@
+package reader
+
+import (
+	"debug/dwarf"
+)
+
+// Variable is a variable or formal parameter of a function.
+type Variable struct {
+	*dwarf.Entry
+	// Depth is the number of lexical blocks of the function the variable
+	// is declared in, 0 for variables declared at the top of the function.
+	Depth int
+}
+
+// Variables returns the variables and formal parameters of the function
+// containing pc which are in scope at pc, in the order they are declared.
+// Variables of lexical blocks not containing pc are left out, as are the
+// ones declared after line, unless line is zero.
+func Variables(data *dwarf.Data, pc uint64, line int) ([]Variable, error) {
+	reader := New(data)
+	fn, err := reader.SeekToFunction(pc)
+	if err != nil {
+		return nil, err
+	}
+	if !fn.Children {
+		return nil, nil
+	}
+
+	var (
+		vars  []Variable
+		depth int
+	)
+	for entry, err := reader.Next(); entry != nil; entry, err = reader.Next() {
+		if err != nil {
+			return nil, err
+		}
+
+		switch entry.Tag {
+		case 0:
+			// End of a lexical block, or of the function.
+			if depth == 0 {
+				return vars, nil
+			}
+			depth--
+		case dwarf.TagLexDwarfBlock:
+			ranges, err := data.Ranges(entry)
+			if err != nil {
+				return nil, err
+			}
+			if !entry.Children {
+				continue
+			}
+			if !inRanges(ranges, pc) {
+				reader.SkipChildren()
+				continue
+			}
+			depth++
+		case dwarf.TagVariable, dwarf.TagFormalParameter:
+			reader.SkipChildren()
+			if declLine, ok := entry.Val(dwarf.AttrDeclLine).(int64); ok && entry.Tag == dwarf.TagVariable && line > 0 && int64(line) < declLine {
+				continue
+			}
+			vars = append(vars, Variable{Entry: entry, Depth: depth})
+		default:
+			reader.SkipChildren()
+		}
+	}
+	return vars, nil
+}
+
+func inRanges(ranges [][2]uint64, pc uint64) bool {
+	for _, r := range ranges {
+		if pc >= r[0] && pc < r[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package proc
 
 import (
 	"fmt"
+	"regexp"
 	"runtime"
 )
 
@@ -20,6 +21,19 @@ type Breakpoint struct {
 	Temp         bool   // Whether this is a temp breakpoint (for next'ing).
 	hardware     bool   // Breakpoint using CPU debug registers.
 	reg          int    // If hardware breakpoint, what debug register it belongs to.
+
+	// Information to collect every time the breakpoint is hit.
+	Variables  []string // Expressions to evaluate.
+	Stacktrace int      // Number of stack frames to retrieve.
+	Tracepoint bool     // Resume execution after the information has been collected.
+
+	Catch       CatchKind      // Goroutine event caught by this breakpoint, if it is a catchpoint.
+	CatchFilter *regexp.Regexp // Only catch goroutines created by matching functions.
+
+	// Breakpoints on the inlined copies of the function, part of this
+	// breakpoint: they share its ID and are cleared along with it.
+	Copies []*Breakpoint
+	CopyOf *Breakpoint // Breakpoint this one is a copy of, nil if none.
 }
 
 func (bp *Breakpoint) String() string {
@@ -64,6 +78,24 @@ func (iae InvalidAddressError) Error() string {
 }
 
 func (dbp *Process) setBreakpoint(tid int, addr uint64, temp bool) (*Breakpoint, error) {
+	return dbp.setBreakpointWithID(tid, addr, temp, 0)
+}
+
+// SetBreakpointCopy sets a breakpoint at addr, the entry of an inlined
+// copy of the function of bp, which becomes part of bp.
+func (dbp *Process) SetBreakpointCopy(bp *Breakpoint, addr uint64) (*Breakpoint, error) {
+	c, err := dbp.setBreakpointWithID(dbp.CurrentThread.Id, addr, false, bp.ID)
+	if err != nil {
+		return nil, err
+	}
+	c.CopyOf = bp
+	bp.Copies = append(bp.Copies, c)
+	return c, nil
+}
+
+// setBreakpointWithID sets a breakpoint with the given ID, or a new one
+// if id is zero.
+func (dbp *Process) setBreakpointWithID(tid int, addr uint64, temp bool, id int) (*Breakpoint, error) {
 	if bp, ok := dbp.FindBreakpoint(addr); ok {
 		return nil, BreakpointExistsError{bp.File, bp.Line, bp.Addr}
 	}
@@ -73,11 +105,12 @@ func (dbp *Process) setBreakpoint(tid int, addr uint64, temp bool) (*Breakpoint,
 		return nil, InvalidAddressError{address: addr}
 	}
 
-	var id int
-	if temp {
+	switch {
+	case id != 0:
+	case temp:
 		dbp.tempBreakpointIDCounter++
 		id = dbp.tempBreakpointIDCounter
-	} else {
+	default:
 		dbp.breakpointIDCounter++
 		id = dbp.breakpointIDCounter
 	}
@@ -153,17 +186,34 @@ func (nbp NoBreakpointError) Error() string {
 	return fmt.Sprintf("no breakpoint at %#v", nbp.addr)
 }
 
+// clearBreakpoint clears the breakpoint at addr, along with the other
+// breakpoints on the inlined copies of its function.
 func (dbp *Process) clearBreakpoint(tid int, addr uint64) (*Breakpoint, error) {
-	thread := dbp.Threads[tid]
-	if bp, ok := dbp.Breakpoints[addr]; ok {
-		if _, err := bp.Clear(thread); err != nil {
+	bp, ok := dbp.Breakpoints[addr]
+	if !ok {
+		return nil, NoBreakpointError{addr: addr}
+	}
+	if bp.CopyOf != nil {
+		bp = bp.CopyOf
+	}
+	for _, c := range bp.Copies {
+		if err := dbp.removeBreakpoint(tid, c); err != nil {
 			return nil, err
 		}
-		if bp.hardware {
-			dbp.arch.SetHardwareBreakpointUsage(bp.reg, false)
-		}
-		delete(dbp.Breakpoints, addr)
-		return bp, nil
 	}
-	return nil, NoBreakpointError{addr: addr}
+	if err := dbp.removeBreakpoint(tid, bp); err != nil {
+		return nil, err
+	}
+	return bp, nil
+}
+
+func (dbp *Process) removeBreakpoint(tid int, bp *Breakpoint) error {
+	if _, err := bp.Clear(dbp.Threads[tid]); err != nil {
+		return err
+	}
+	if bp.hardware {
+		dbp.arch.SetHardwareBreakpointUsage(bp.reg, false)
+	}
+	delete(dbp.Breakpoints, bp.Addr)
+	return nil
 }
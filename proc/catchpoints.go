@@ -0,0 +1,200 @@
+package proc
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"regexp"
+
+	"github.com/derekparker/delve/dwarf/op"
+)
+
+// CatchKind identifies the goroutine event caught by a catchpoint.
+type CatchKind int
+
+const (
+	NoCatch              CatchKind = iota // Regular breakpoint.
+	CatchGoroutineCreate                  // Stop when a goroutine is created.
+	CatchGoroutineExit                    // Stop when a goroutine exits.
+)
+
+func (k CatchKind) String() string {
+	switch k {
+	case CatchGoroutineCreate:
+		return "goroutine-create"
+	case CatchGoroutineExit:
+		return "goroutine-exit"
+	}
+	return ""
+}
+
+// Runtime functions catchpoints are set on. The entry of runtime.goexit
+// is never executed, goroutines return to the instruction following it,
+// so we stop in runtime.goexit1 instead.
+var catchFunctions = map[CatchKind]string{
+	CatchGoroutineCreate: "runtime.newproc1",
+	CatchGoroutineExit:   "runtime.goexit1",
+}
+
+// SetCatchpoint sets a catchpoint for goroutine events of the given kind.
+// If filter is not nil only goroutines created by a function whose name
+// matches filter will stop the process.
+func (dbp *Process) SetCatchpoint(kind CatchKind, filter *regexp.Regexp) (*Breakpoint, error) {
+	fname, ok := catchFunctions[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown catchpoint kind %d", kind)
+	}
+	fn := dbp.goSymTable.LookupFunc(fname)
+	if fn == nil {
+		return nil, fmt.Errorf("could not find function %s", fname)
+	}
+	bp, err := dbp.SetBreakpoint(fn.Entry)
+	if err != nil {
+		return nil, err
+	}
+	bp.Catch = kind
+	bp.CatchFilter = filter
+	return bp, nil
+}
+
+// handleCatchpoint collects the goroutine that is being created or is
+// exiting at the catchpoint thread is stopped at. Returns false if the
+// goroutine does not match the catchpoint filter, and execution
+// should be resumed.
+func (dbp *Process) handleCatchpoint(thread *Thread, bp *Breakpoint) (bool, error) {
+	var (
+		g   *G
+		err error
+	)
+	switch bp.Catch {
+	case CatchGoroutineCreate:
+		g, err = thread.newprocG(bp.CatchFilter)
+	case CatchGoroutineExit:
+		g, err = thread.getG()
+		if err == nil && !matchGoroutine(bp.CatchFilter, g) {
+			g = nil
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	if g == nil {
+		return false, nil
+	}
+	thread.CaughtGoroutine = g
+	return true, nil
+}
+
+// newprocG returns the goroutine created by the call to runtime.newproc1
+// thread is stopped at the entry of. The call is executed until it returns
+// the new G, unless the location of the go statement does not match filter,
+// in which case nil is returned.
+func (thread *Thread) newprocG(filter *regexp.Regexp) (*G, error) {
+	callerpcAddr, err := thread.formalParameterAddr(func(e *dwarf.Entry) bool {
+		n, _ := e.Val(dwarf.AttrName).(string)
+		return n == "callerpc"
+	})
+	if err != nil {
+		return nil, err
+	}
+	callerpc, err := thread.readUintRaw(uintptr(callerpcAddr), int64(thread.dbp.arch.PtrSize()))
+	if err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		fn := thread.dbp.goSymTable.PCToFunc(callerpc)
+		if fn == nil || !filter.MatchString(fn.Name) {
+			return nil, nil
+		}
+	}
+
+	// The result slot belongs to the caller's frame, so it
+	// remains valid once newproc1 has returned.
+	retAddr, err := thread.formalParameterAddr(func(e *dwarf.Entry) bool {
+		ret, _ := e.Val(dwarf.AttrVarParam).(bool)
+		return ret
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := thread.stepOut(); err != nil {
+		return nil, err
+	}
+	return parseG(thread, uint64(retAddr), true)
+}
+
+func matchGoroutine(filter *regexp.Regexp, g *G) bool {
+	if filter == nil {
+		return true
+	}
+	return g.Func != nil && filter.MatchString(g.Func.Name)
+}
+
+// formalParameterAddr returns the address of the first parameter of the
+// current function for which match returns true.
+func (thread *Thread) formalParameterAddr(match func(*dwarf.Entry) bool) (int64, error) {
+	scope, err := thread.Scope()
+	if err != nil {
+		return 0, err
+	}
+	return thread.dbp.formalParameterAddr(scope.PC, scope.CFA, match)
+}
+
+// formalParameterAddr returns the address of the first parameter of the
+// function containing pc for which match returns true, cfa being the
+// canonical frame address of the function's frame.
+func (dbp *Process) formalParameterAddr(pc uint64, cfa int64, match func(*dwarf.Entry) bool) (int64, error) {
+	rdr := dbp.DwarfReader()
+	if _, err := rdr.SeekToFunction(pc); err != nil {
+		return 0, err
+	}
+
+	for entry, err := rdr.NextScopeVariable(); entry != nil; entry, err = rdr.NextScopeVariable() {
+		if err != nil {
+			return 0, err
+		}
+		if entry.Tag != dwarf.TagFormalParameter || !match(entry) {
+			continue
+		}
+		instructions, err := rdr.InstructionsForEntry(entry)
+		if err != nil {
+			return 0, err
+		}
+		return op.ExecuteStackProgram(cfa, instructions)
+	}
+	return 0, fmt.Errorf("could not find formal parameter")
+}
+
+// Resumes only this thread until the current function returns.
+func (thread *Thread) stepOut() error {
+	ret, err := thread.ReturnAddress()
+	if err != nil {
+		return err
+	}
+	if _, err := thread.dbp.SetTempBreakpoint(ret); err != nil {
+		if _, ok := err.(BreakpointExistsError); !ok {
+			return err
+		}
+	} else {
+		defer thread.dbp.ClearBreakpoint(ret)
+	}
+
+	if err := thread.Continue(); err != nil {
+		return err
+	}
+	for {
+		th, err := thread.dbp.trapWait(thread.Id)
+		if err != nil {
+			return err
+		}
+		pc, err := th.PC()
+		if err != nil {
+			return err
+		}
+		if th == thread && pc == ret {
+			return nil
+		}
+		if err := th.Continue(); err != nil {
+			return err
+		}
+	}
+}
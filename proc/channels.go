@@ -0,0 +1,237 @@
+package proc
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"strings"
+)
+
+// Alignment of the buffer following the hchan struct in runtimes
+// without an hchan.buf field.
+const hchanBufAlign = 8
+
+// Maximum number of goroutines followed in a channel's wait queue, in
+// case it's corrupted.
+const maxWaiters = 1 << 16
+
+// Channel describes the state of a channel.
+type Channel struct {
+	Type   string // Type of the channel, e.g. chan int.
+	Addr   uint64 // Address of the runtime hchan, zero for nil channels.
+	Len    int64
+	Cap    int64
+	Closed bool
+	// Buffered elements, the next to be received first. At most
+	// maxArrayValues of them are read.
+	Elems []string
+	// IDs of the goroutines parked receiving from and sending to the
+	// channel, in queue order.
+	RecvWaiters []int
+	SendWaiters []int
+
+	elemType  dwarf.Type
+	elemAddrs []int64 // Addresses of the buffered elements read.
+}
+
+// isChan returns whether t is a channel type.
+func isChan(t *dwarf.TypedefType) bool {
+	return strings.HasPrefix(t.Name, "chan") || strings.HasPrefix(t.Name, "<-chan")
+}
+
+// ChannelInfo returns the state of the channel expr evaluates to.
+func (thread *Thread) ChannelInfo(expr string) (*Channel, error) {
+	scope, err := thread.Scope()
+	if err != nil {
+		return nil, err
+	}
+	return scope.ChannelInfo(expr)
+}
+
+// ChannelInfo returns the state of the channel expr evaluates to.
+func (scope *EvalScope) ChannelInfo(expr string) (*Channel, error) {
+	v, err := scope.evalExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := v.typ.(*dwarf.TypedefType)
+	if !ok || !isChan(t) || v.addr == 0 {
+		return nil, fmt.Errorf("%s (type %s) is not a channel", expr, valueType(v))
+	}
+	thread := scope.Thread
+	ch, err := thread.readChannel(uintptr(v.addr), t, maxArrayValues)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range ch.elemAddrs {
+		val, err := thread.extractValue(addr, ch.elemType, true)
+		if err != nil {
+			return nil, err
+		}
+		ch.Elems = append(ch.Elems, val)
+	}
+	if int64(len(ch.elemAddrs)) < ch.Len {
+		ch.Elems = append(ch.Elems, fmt.Sprintf("...+%d more", ch.Len-int64(len(ch.elemAddrs))))
+	}
+	return ch, nil
+}
+
+// readChannel reads the channel of type t stored at addr, along with the
+// addresses of at most max of its buffered elements.
+func (thread *Thread) readChannel(addr uintptr, t *dwarf.TypedefType, max int) (*Channel, error) {
+	ptrSize := int64(thread.dbp.arch.PtrSize())
+	ptr, ok := t.Type.(*dwarf.PtrType)
+	if !ok {
+		return nil, fmt.Errorf("unexpected representation of %s", t.Name)
+	}
+	hchan, ok := ptr.Type.(*dwarf.StructType)
+	if !ok {
+		return nil, fmt.Errorf("unexpected representation of %s", t.Name)
+	}
+	ch := &Channel{Type: t.Name}
+	hchanAddr, err := thread.readUintRaw(addr, ptrSize)
+	if err != nil || hchanAddr == 0 {
+		return ch, err
+	}
+	ch.Addr = hchanAddr
+
+	var (
+		elemType           dwarf.Type
+		bufAddr            = (uint64(hchan.ByteSize) + hchanBufAlign - 1) &^ (hchanBufAlign - 1)
+		recvx              uint64
+		recvqOff, sendqOff int64
+	)
+	bufAddr += hchanAddr
+	for _, f := range hchan.Field {
+		fieldAddr := uintptr(hchanAddr) + uintptr(f.ByteOffset)
+		switch f.Name {
+		case "qcount":
+			ch.Len, err = thread.readIntRaw(fieldAddr, f.Type.Size())
+		case "dataqsiz":
+			ch.Cap, err = thread.readIntRaw(fieldAddr, f.Type.Size())
+		case "buf":
+			bufAddr, err = thread.readUintRaw(fieldAddr, ptrSize)
+		case "closed":
+			var closed uint64
+			closed, err = thread.readUintRaw(fieldAddr, f.Type.Size())
+			ch.Closed = closed != 0
+		case "recvx":
+			recvx, err = thread.readUintRaw(fieldAddr, f.Type.Size())
+		case "recvq":
+			recvqOff = f.ByteOffset
+			elemType = waitqElemType(f.Type)
+		case "sendq":
+			sendqOff = f.ByteOffset
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if elemType == nil {
+		// Fall back to the element type named in the channel type.
+		elem := strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(t.Name, "<-"), "chan"), "<-")
+		if elemType, err = thread.dbp.typeNamed(strings.TrimSpace(elem)); err != nil {
+			return nil, err
+		}
+	}
+	ch.elemType = elemType
+	if ch.Len < 0 || ch.Len > ch.Cap {
+		return nil, fmt.Errorf("invalid channel length %d with capacity %d", ch.Len, ch.Cap)
+	}
+	if ch.Cap > 0 {
+		elemSize := thread.stride(elemType)
+		for i := int64(0); i < ch.Len && i < int64(max); i++ {
+			idx := (int64(recvx) + i) % ch.Cap
+			ch.elemAddrs = append(ch.elemAddrs, int64(bufAddr)+idx*elemSize)
+		}
+	}
+
+	if ch.RecvWaiters, err = thread.waitqGoroutines(hchanAddr + uint64(recvqOff)); err != nil {
+		return nil, err
+	}
+	if ch.SendWaiters, err = thread.waitqGoroutines(hchanAddr + uint64(sendqOff)); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// waitqElemType returns the element type of the channel a waitq<T> belongs
+// to, read from the elem field of its sudog<T> entries, nil if the waitq
+// isn't specialized for the element type.
+func waitqElemType(typ dwarf.Type) dwarf.Type {
+	waitq, ok := typ.(*dwarf.StructType)
+	if !ok {
+		return nil
+	}
+	for _, f := range waitq.Field {
+		if f.Name != "first" {
+			continue
+		}
+		ptr, ok := f.Type.(*dwarf.PtrType)
+		if !ok {
+			return nil
+		}
+		sudog, ok := ptr.Type.(*dwarf.StructType)
+		if !ok || sudog.StructName == "runtime.sudog" {
+			return nil
+		}
+		for _, sf := range sudog.Field {
+			if sf.Name == "elem" {
+				if elem, ok := sf.Type.(*dwarf.PtrType); ok {
+					return elem.Type
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// waitqGoroutines returns the IDs of the goroutines queued in the
+// runtime.waitq at addr.
+func (thread *Thread) waitqGoroutines(addr uint64) ([]int, error) {
+	ptrSize := int64(thread.dbp.arch.PtrSize())
+	sudogtyp, err := thread.dbp.structTypeNamed("runtime.sudog")
+	if err != nil {
+		return nil, err
+	}
+	gtyp, err := thread.dbp.structTypeNamed("runtime.g")
+	if err != nil {
+		return nil, err
+	}
+
+	// first is the first member of waitq.
+	sudog, err := thread.readUintRaw(uintptr(addr), ptrSize)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		ids     []int
+		visited = map[uint64]bool{}
+	)
+	for sudog != 0 {
+		if visited[sudog] || len(visited) >= maxWaiters {
+			return nil, fmt.Errorf("wait queue at %#x is corrupted", addr)
+		}
+		visited[sudog] = true
+		g, ok, err := thread.readMember(sudogtyp, sudog, "g")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("runtime.sudog has no member g")
+		}
+		if g != 0 {
+			goid, ok, err := thread.readMember(gtyp, g, "goid")
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("runtime.g has no member goid")
+			}
+			ids = append(ids, int(goid))
+		}
+		if sudog, _, err = thread.readMember(sudogtyp, sudog, "next"); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
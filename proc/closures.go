@@ -0,0 +1,151 @@
+package proc
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"strings"
+)
+
+// DWARF attribute of the variables of closures giving their offset in
+// the closure context, see DW_AT_go_closure_offset in cmd/internal/dwarf.
+const attrGoClosureOffset dwarf.Attr = 0x2907
+
+// Suffix of the wrappers the compiler generates for method values.
+const methodValueSuffix = "-fm"
+
+// loadFunc reads the function value v, along with the variables captured
+// by closures and the receiver bound to method values.
+//
+// A function value points to a funcval, the PC of the function followed
+// by the closure context. The compiler describes where each captured
+// variable lives in the context in the DWARF entries of the variables of
+// the function. Variables captured by reference are named after the
+// variable with a leading &.
+func (l *loader) loadFunc(v *Variable, depth int) error {
+	var (
+		thread  = l.thread
+		dbp     = thread.dbp
+		ptrSize = int64(dbp.arch.PtrSize())
+	)
+	funcval, err := thread.readUintRaw(uintptr(v.Addr), ptrSize)
+	if err != nil {
+		return err
+	}
+	if funcval == 0 {
+		v.isnil = true
+		v.Value = "nil"
+		return nil
+	}
+	pc, err := thread.readUintRaw(uintptr(funcval), ptrSize)
+	if err != nil {
+		return err
+	}
+	fn := dbp.goSymTable.PCToFunc(pc)
+	if fn == nil {
+		return fmt.Errorf("could not find function for %#v", pc)
+	}
+
+	name, entry := fn.Name, fn.Entry
+	if strings.HasSuffix(name, methodValueSuffix) {
+		// Show the method rather than the wrapper, which has no source.
+		name = strings.TrimSuffix(name, methodValueSuffix)
+		if m := dbp.goSymTable.LookupFunc(name); m != nil {
+			entry = m.Entry
+		}
+	}
+	file, line, _ := dbp.goSymTable.PCToLine(entry)
+	v.fn = fmt.Sprintf("%s at %s:%d", name, file, line)
+	v.Value = name
+
+	captured, err := dbp.closureVariables(fn.Entry)
+	if err != nil || len(captured) == 0 {
+		return err
+	}
+	if depth > l.cfg.MaxVariableRecurse {
+		v.Unloaded = true
+		return nil
+	}
+	v.Children = make([]*Variable, 0, len(captured))
+	for _, cv := range captured {
+		child, err := l.loadClosureVariable(cv, int64(funcval), depth+1)
+		if err != nil {
+			return err
+		}
+		v.Children = append(v.Children, child)
+	}
+	return nil
+}
+
+// closureVariable is a variable captured by a closure.
+type closureVariable struct {
+	name   string
+	typ    dwarf.Type
+	offset int64 // Offset in the funcval.
+}
+
+// closureVariables returns the variables captured by the function at
+// entry, in the order they are stored in the closure context.
+func (dbp *Process) closureVariables(entry uint64) ([]closureVariable, error) {
+	rdr := dbp.DwarfReader()
+	if _, err := rdr.SeekToFunction(entry); err != nil {
+		return nil, err
+	}
+	var (
+		vars   []closureVariable
+		byAddr = map[int64]int{}
+	)
+	for e, err := rdr.NextScopeVariable(); e != nil; e, err = rdr.NextScopeVariable() {
+		if err != nil {
+			return nil, err
+		}
+		off, ok := e.Val(attrGoClosureOffset).(int64)
+		if !ok || off == 0 {
+			continue
+		}
+		name, _ := e.Val(dwarf.AttrName).(string)
+		toff, ok := e.Val(dwarf.AttrType).(dwarf.Offset)
+		if !ok {
+			continue
+		}
+		typ, err := dbp.dwarf.Type(toff)
+		if err != nil {
+			return nil, err
+		}
+		cv := closureVariable{name: name, typ: typ, offset: off}
+		if i, ok := byAddr[off]; ok {
+			// A variable captured by reference may be described both by
+			// itself and by its address, the latter is what's stored.
+			if strings.HasPrefix(name, "&") {
+				vars[i] = cv
+			}
+			continue
+		}
+		byAddr[off] = len(vars)
+		vars = append(vars, cv)
+	}
+	return vars, nil
+}
+
+// loadClosureVariable reads the captured variable cv of the funcval at
+// closure. Variables captured by reference are dereferenced.
+func (l *loader) loadClosureVariable(cv closureVariable, closure int64, depth int) (*Variable, error) {
+	addr := closure + cv.offset
+	name := cv.name
+	switch {
+	case name == ".this":
+		name = "receiver"
+	case strings.HasPrefix(name, "&"):
+		ptr, ok := resolveTypedef(cv.typ).(*dwarf.PtrType)
+		if !ok {
+			break
+		}
+		p, err := l.thread.readUintRaw(uintptr(addr), int64(l.thread.dbp.arch.PtrSize()))
+		if err != nil {
+			return nil, err
+		}
+		if p != 0 {
+			return l.load(strings.TrimPrefix(name, "&"), int64(p), ptr.Type, depth)
+		}
+	}
+	return l.load(name, addr, cv.typ, depth)
+}
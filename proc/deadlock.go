@@ -0,0 +1,278 @@
+package proc
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+// How far down the stack of a blocked goroutine we look
+// for the function it is blocked in.
+const maxBlockingDepth = 20
+
+// Runtime functions goroutines block in, mapped to the kind of object
+// they wait on and the name of the parameter holding its address.
+var blockingFunctions = map[string]struct{ kind, param string }{
+	"runtime.chansend":   {"chan", "c"},
+	"runtime.chanrecv":   {"chan", "c"},
+	"runtime.semacquire": {"sema", "addr"},
+	"runtime.selectgo":   {"select", ""},
+}
+
+// BlockedG is a goroutine parked waiting on a channel or
+// synchronization object.
+type BlockedG struct {
+	*G
+
+	ObjectKind string    // Kind of object the goroutine waits on: chan, sema or select. Empty if unknown.
+	ObjectAddr uint64    // Address of the object, zero if unknown.
+	Location   *Location // Innermost frame outside of the runtime.
+
+	// IDs of the other blocked goroutines referencing the object
+	// on their stack, which may be the ones supposed to release it.
+	WaitsOn []int
+
+	// Error looking for the object, or reading the stack, if any.
+	Err error
+}
+
+// WaitGraph describes the goroutines blocked on channels and
+// synchronization objects, and who they are waiting for.
+type WaitGraph struct {
+	Blocked  []*BlockedG
+	Cycles   [][]int // Sets of blocked goroutines waiting on each other.
+	Runnable int     // Number of goroutines running, runnable or in a syscall.
+}
+
+// WaitGraph builds the wait-for graph of the goroutines of the process.
+//
+// The runtime doesn't record who holds a channel or a semaphore, so a
+// goroutine is considered to wait on the other blocked goroutines that
+// have the address of its object on their stack, unless they are blocked
+// on the same object themselves.
+func (dbp *Process) WaitGraph() (*WaitGraph, error) {
+	allg, err := dbp.GoroutinesInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	gtyp, err := dbp.structTypeNamed("runtime.g")
+	if err != nil {
+		return nil, err
+	}
+	sudogtyp, err := dbp.structTypeNamed("runtime.sudog")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		graph  = &WaitGraph{}
+		thread = dbp.CurrentThread
+	)
+	// queuedObject falls back to the sudog the goroutine is queued with:
+	// its c is the channel, only recorded since Go 1.8, its elem the
+	// address of a semaphore but only the data sent or received for a
+	// channel.
+	queuedObject := func(bg *BlockedG) error {
+		sudog, _, err := thread.readMember(gtyp, bg.addr, "waiting")
+		if err != nil || sudog == 0 {
+			return err
+		}
+		if bg.ObjectKind != "sema" {
+			c, _, err := thread.readMember(sudogtyp, sudog, "c")
+			if err != nil {
+				return err
+			}
+			if c != 0 {
+				bg.ObjectKind, bg.ObjectAddr = "chan", c
+			}
+		}
+		if bg.ObjectKind == "sema" {
+			if bg.ObjectAddr, _, err = thread.readMember(sudogtyp, sudog, "elem"); err != nil {
+				bg.ObjectAddr = 0
+				return err
+			}
+		}
+		return nil
+	}
+	for _, g := range allg {
+		switch g.Status &^ Gscan {
+		case Grunnable, Grunning, Gsyscall:
+			graph.Runnable++
+			continue
+		case Gwaiting:
+		default:
+			continue
+		}
+
+		// A stack or goroutine we can't read must not hide the others.
+		bg := &BlockedG{G: g}
+		if err := dbp.findBlockingObject(bg); err != nil {
+			bg.ObjectAddr, bg.Err = 0, err
+		}
+		if bg.ObjectAddr == 0 && bg.ObjectKind != "select" {
+			if err := queuedObject(bg); err != nil && bg.Err == nil {
+				bg.Err = err
+			}
+		}
+		graph.Blocked = append(graph.Blocked, bg)
+	}
+
+	objects := map[uint64]bool{}
+	for _, bg := range graph.Blocked {
+		if bg.ObjectAddr != 0 {
+			objects[bg.ObjectAddr] = true
+		}
+	}
+
+	// Find out which objects each blocked goroutine references.
+	referencedBy := map[uint64][]int{}
+	for _, bg := range graph.Blocked {
+		refs, err := dbp.stackReferences(bg.SP, bg.StackHi, objects)
+		if err != nil {
+			if bg.Err == nil {
+				bg.Err = err
+			}
+			continue
+		}
+		for _, obj := range refs {
+			if obj != bg.ObjectAddr {
+				referencedBy[obj] = append(referencedBy[obj], bg.Id)
+			}
+		}
+	}
+	for _, bg := range graph.Blocked {
+		if bg.ObjectAddr != 0 {
+			bg.WaitsOn = referencedBy[bg.ObjectAddr]
+		}
+	}
+
+	graph.Cycles = waitCycles(graph.Blocked)
+	return graph, nil
+}
+
+// findBlockingObject walks the stack of a blocked goroutine looking for
+// the runtime function it is blocked in and the object it waits on. An
+// object whose address can't be read is recorded in bg.Err.
+func (dbp *Process) findBlockingObject(bg *BlockedG) error {
+	frames, err := dbp.stackframes(nil, goroutineRegisters(bg.G), maxBlockingDepth)
+	if err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		fn := dbp.goSymTable.PCToFunc(frame.pc)
+		if fn == nil {
+			continue
+		}
+		if bf, ok := blockingFunctions[fn.Name]; ok && bg.ObjectKind == "" {
+			bg.ObjectKind = bf.kind
+			if bf.param != "" && frame.cfa != 0 {
+				addr, err := dbp.formalParameterAddr(frame.pc, frame.cfa, func(e *dwarf.Entry) bool {
+					n, _ := e.Val(dwarf.AttrName).(string)
+					return n == bf.param
+				})
+				if err == nil {
+					bg.ObjectAddr, err = dbp.CurrentThread.readUintRaw(uintptr(addr), int64(dbp.arch.PtrSize()))
+				}
+				if err != nil {
+					// Keep looking for the location of the goroutine.
+					bg.ObjectAddr, bg.Err = 0, err
+				}
+			}
+		}
+		if !strings.HasPrefix(fn.Name, "runtime.") {
+			f, l, _ := dbp.goSymTable.PCToLine(frame.pc)
+			bg.Location = &Location{PC: frame.pc, File: f, Line: l, Fn: fn}
+			break
+		}
+	}
+	return nil
+}
+
+// stackReferences returns the objects whose address is stored in the
+// stack between sp and hi.
+func (dbp *Process) stackReferences(sp, hi uint64, objects map[uint64]bool) ([]uint64, error) {
+	if len(objects) == 0 || sp == 0 || hi <= sp {
+		return nil, nil
+	}
+	data, err := dbp.CurrentThread.readMemory(uintptr(sp), int(hi-sp))
+	if err != nil {
+		return nil, err
+	}
+	var (
+		refs []uint64
+		seen = map[uint64]bool{}
+		size = dbp.arch.PtrSize()
+	)
+	for i := 0; i+size <= len(data); i += size {
+		v := binary.LittleEndian.Uint64(data[i : i+size])
+		if objects[v] && !seen[v] {
+			seen[v] = true
+			refs = append(refs, v)
+		}
+	}
+	return refs, nil
+}
+
+// waitCycles returns the strongly connected components of the wait-for
+// graph that contain more than one goroutine.
+func waitCycles(blocked []*BlockedG) [][]int {
+	var (
+		edges   = map[int][]int{}
+		index   = map[int]int{}
+		lowlink = map[int]int{}
+		onStack = map[int]bool{}
+		stack   []int
+		counter int
+		cycles  [][]int
+		ids     []int
+	)
+	for _, bg := range blocked {
+		edges[bg.Id] = bg.WaitsOn
+		ids = append(ids, bg.Id)
+	}
+
+	var strongconnect func(v int)
+	strongconnect = func(v int) {
+		index[v] = counter
+		lowlink[v] = counter
+		counter++
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range edges[v] {
+			if _, visited := index[w]; !visited {
+				strongconnect(w)
+				if lowlink[w] < lowlink[v] {
+					lowlink[v] = lowlink[w]
+				}
+			} else if onStack[w] && index[w] < lowlink[v] {
+				lowlink[v] = index[w]
+			}
+		}
+		if lowlink[v] != index[v] {
+			return
+		}
+		var component []int
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		if len(component) > 1 {
+			sort.Ints(component)
+			cycles = append(cycles, component)
+		}
+	}
+
+	for _, id := range ids {
+		if _, visited := index[id]; !visited {
+			strongconnect(id)
+		}
+	}
+	return cycles
+}
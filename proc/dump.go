@@ -0,0 +1,254 @@
+package proc
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// dumpConfig loads values whole, without the limits that keep them short
+// when they are displayed.
+var dumpConfig = LoadConfig{
+	MaxVariableRecurse: math.MaxInt32,
+	MaxStringLen:       math.MaxInt32,
+	MaxArrayValues:     math.MaxInt32,
+	MaxMapValues:       math.MaxInt32,
+	Raw:                true,
+}
+
+// DumpExpression evaluates expr and writes the whole graph of its value to
+// w as JSON. Pointers are written as references {"$ref": id} to the
+// values they point to, which are written once in the objects of the
+// document, keyed by their type and address, so that values shared by
+// several pointers and cycles are dumped only once. Maps with string keys
+// are written as objects, other maps as arrays of key and value pairs.
+func (scope *EvalScope) DumpExpression(expr string, w io.Writer) error {
+	ev, err := scope.evalExpr(expr)
+	if err != nil {
+		return err
+	}
+	l := scope.Thread.newLoader(dumpConfig)
+	l.pointees, l.noValues = map[loadKey]bool{}, true
+	v, err := scope.newVariable(expr, ev, l)
+	if err != nil {
+		return err
+	}
+
+	d := &dumper{thread: scope.Thread, objects: map[string]*bytes.Buffer{}}
+	var value bytes.Buffer
+	d.value(&value, v)
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"expr":`)
+	writeJSONString(&buf, expr)
+	buf.WriteString(`,"type":`)
+	writeJSONString(&buf, v.Type)
+	buf.WriteString(`,"value":`)
+	buf.Write(value.Bytes())
+	buf.WriteString(`,"objects":{`)
+	for i, id := range d.order {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(&buf, id)
+		buf.WriteByte(':')
+		buf.Write(d.objects[id].Bytes())
+	}
+	buf.WriteString("}}")
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "\t"); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	_, err = out.WriteTo(w)
+	return err
+}
+
+// DumpExpression is like the DumpExpression method of EvalScope, in the
+// current frame of thread.
+func (thread *Thread) DumpExpression(expr string, w io.Writer) error {
+	scope, err := thread.Scope()
+	if err != nil {
+		return err
+	}
+	return scope.DumpExpression(expr, w)
+}
+
+// dumper writes variables as JSON, collecting the values pointers point to.
+type dumper struct {
+	thread  *Thread
+	objects map[string]*bytes.Buffer
+	order   []string // IDs of the objects, in the order they were reached.
+}
+
+// objectID identifies the value v pointers point to.
+func objectID(v *Variable) string {
+	return fmt.Sprintf("%s@%#x", v.Type, v.Addr)
+}
+
+// value writes the JSON value of v to buf.
+func (d *dumper) value(buf *bytes.Buffer, v *Variable) {
+	if v.isnil {
+		buf.WriteString("null")
+		return
+	}
+	if v.Unloaded && v.Kind != reflect.Func {
+		// Values are only cut short where they contain themselves.
+		buf.WriteString(`{"$cycle":`)
+		writeJSONString(buf, objectID(v))
+		buf.WriteByte('}')
+		return
+	}
+	switch v.Kind {
+	case reflect.Ptr:
+		if len(v.Children) == 0 {
+			// unsafe.Pointer
+			writeJSONString(buf, v.Value)
+			return
+		}
+		pointee := v.Children[0]
+		id := objectID(pointee)
+		if !pointee.Unloaded {
+			obj := &bytes.Buffer{}
+			d.objects[id] = obj
+			d.order = append(d.order, id)
+			d.value(obj, pointee)
+		}
+		buf.WriteString(`{"$ref":`)
+		writeJSONString(buf, id)
+		buf.WriteByte('}')
+	case reflect.Bool:
+		buf.WriteString(v.Value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := firstField(v.Value)
+		if _, err := strconv.ParseFloat(n, 64); err != nil {
+			writeJSONString(buf, v.Value)
+			return
+		}
+		buf.WriteString(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(v.Value, 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			// Not representable as a JSON number.
+			writeJSONString(buf, v.Value)
+			return
+		}
+		buf.WriteString(v.Value)
+	case reflect.String:
+		s := v.Value
+		if v.Addr != 0 {
+			if raw, err := d.thread.readString(uintptr(v.Addr)); err == nil {
+				s = raw
+			}
+		}
+		writeJSONString(buf, s)
+	case reflect.Struct:
+		buf.WriteByte('{')
+		for i, field := range v.Children {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, field.Name)
+			buf.WriteByte(':')
+			d.value(buf, field)
+		}
+		buf.WriteByte('}')
+	case reflect.Array, reflect.Slice, reflect.Chan:
+		d.values(buf, v.Children)
+	case reflect.Map:
+		d.mapValue(buf, v)
+	case reflect.Interface:
+		buf.WriteString(`{"type":`)
+		writeJSONString(buf, v.Children[0].Name)
+		buf.WriteString(`,"value":`)
+		d.value(buf, v.Children[0])
+		buf.WriteByte('}')
+	case reflect.Func:
+		if len(v.Children) == 0 {
+			writeJSONString(buf, v.fn)
+			return
+		}
+		buf.WriteString(`{"func":`)
+		writeJSONString(buf, v.fn)
+		buf.WriteString(`,"captured":{`)
+		for i, cv := range v.Children {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, cv.Name)
+			buf.WriteByte(':')
+			d.value(buf, cv)
+		}
+		buf.WriteString("}}")
+	default:
+		writeJSONString(buf, v.Value)
+	}
+}
+
+// values writes vals as a JSON array.
+func (d *dumper) values(buf *bytes.Buffer, vals []*Variable) {
+	buf.WriteByte('[')
+	for i, val := range vals {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		d.value(buf, val)
+	}
+	buf.WriteByte(']')
+}
+
+// mapValue writes the map v as an object if its keys are strings, as an
+// array of {"key": k, "value": v} pairs otherwise.
+func (d *dumper) mapValue(buf *bytes.Buffer, v *Variable) {
+	stringKeys := false
+	if mt, err := newMapType(v.typ.(*dwarf.TypedefType)); err == nil {
+		stringKeys = kindOf(mt.key) == reflect.String
+	}
+	if stringKeys {
+		buf.WriteByte('{')
+	} else {
+		buf.WriteByte('[')
+	}
+	for i := 0; i+1 < len(v.Children); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, val := v.Children[i], v.Children[i+1]
+		if stringKeys {
+			d.value(buf, key)
+			buf.WriteByte(':')
+			d.value(buf, val)
+			continue
+		}
+		buf.WriteString(`{"key":`)
+		d.value(buf, key)
+		buf.WriteString(`,"value":`)
+		d.value(buf, val)
+		buf.WriteByte('}')
+	}
+	if stringKeys {
+		buf.WriteByte('}')
+	} else {
+		buf.WriteByte(']')
+	}
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	b, _ := json.Marshal(s)
+	buf.Write(b)
+}
+
+func firstField(s string) string {
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,1629 @@
+package proc
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// evalValue is the result of evaluating an expression. Values stored in
+// the memory of the process have an address, the others, like constants
+// and the results of arithmetic, are held in val.
+type evalValue struct {
+	typ  dwarf.Type  // Nil for untyped constants and nil.
+	addr int64       // Address of the value, zero if it isn't in memory.
+	val  interface{} // bool, int64, uint64, float64, string, sliceHeader or nil.
+}
+
+// sliceHeader is a slice made by slicing an array or a slice.
+type sliceHeader struct {
+	base     uint64
+	len, cap int64
+	elem     dwarf.Type
+}
+
+// Sizes of the basic types conversions are supported to, zero for the
+// types as large as a pointer.
+var basicTypes = map[string]struct {
+	kind string
+	size int64
+}{
+	"bool":    {"bool", 1},
+	"int":     {"int", 0},
+	"int8":    {"int", 1},
+	"int16":   {"int", 2},
+	"int32":   {"int", 4},
+	"int64":   {"int", 8},
+	"rune":    {"int", 4},
+	"uint":    {"uint", 0},
+	"uint8":   {"uint", 1},
+	"uint16":  {"uint", 2},
+	"uint32":  {"uint", 4},
+	"uint64":  {"uint", 8},
+	"uintptr": {"uint", 0},
+	"byte":    {"uint", 1},
+	"float32": {"float", 4},
+	"float64": {"float", 8},
+}
+
+// basicType returns the DWARF type of the named basic type on an
+// architecture with pointers of ptrSize bytes.
+func basicType(name string, ptrSize int) dwarf.Type {
+	bt, ok := basicTypes[name]
+	if !ok {
+		return nil
+	}
+	size := bt.size
+	if size == 0 {
+		size = int64(ptrSize)
+	}
+	switch name {
+	case "byte":
+		name = "uint8"
+	case "rune":
+		name = "int32"
+	}
+	common := dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: size, Name: name}}
+	switch bt.kind {
+	case "bool":
+		return &dwarf.BoolType{BasicType: common}
+	case "int":
+		return &dwarf.IntType{BasicType: common}
+	case "uint":
+		return &dwarf.UintType{BasicType: common}
+	}
+	return &dwarf.FloatType{BasicType: common}
+}
+
+// resolveTypedef returns the type t is a typedef of, t itself if it
+// isn't one.
+func resolveTypedef(t dwarf.Type) dwarf.Type {
+	for {
+		tt, ok := t.(*dwarf.TypedefType)
+		if !ok {
+			return t
+		}
+		t = tt.Type
+	}
+}
+
+// EvalVariable returns the value of the Go expression expr, which can
+// reference the variables visible in the scope and package variables.
+// Besides names, member selection, pointer dereferences, indexing and
+// slicing, arithmetic, comparisons and boolean operators are supported,
+// along with len, cap, numeric conversions and type assertions.
+func (scope *EvalScope) EvalVariable(expr string) (*Variable, error) {
+	return scope.EvalExpression(expr, scope.Thread.dbp.DefaultLoadConfig())
+}
+
+// EvalExpression is like EvalVariable, loading the value with cfg.
+func (scope *EvalScope) EvalExpression(expr string, cfg LoadConfig) (*Variable, error) {
+	v, err := scope.evalExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	return scope.newVariable(expr, v, scope.Thread.newLoader(cfg))
+}
+
+// evalExpr parses and evaluates expr.
+func (scope *EvalScope) evalExpr(expr string) (*evalValue, error) {
+	t, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	return scope.eval(t)
+}
+
+// exprString renders the expression x as Go source.
+func exprString(x ast.Node) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, token.NewFileSet(), x)
+	return buf.String()
+}
+
+func (scope *EvalScope) eval(x ast.Expr) (*evalValue, error) {
+	switch x := x.(type) {
+	case *ast.ParenExpr:
+		return scope.eval(x.X)
+	case *ast.BasicLit:
+		return evalLiteral(x)
+	case *ast.Ident:
+		return scope.evalIdent(x.Name)
+	case *ast.SelectorExpr:
+		if pkg, ok := x.X.(*ast.Ident); ok {
+			// Either a member of a variable or a package variable.
+			v, err := scope.lookupIdent(pkg.Name)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				return scope.member(v, x.Sel.Name, pkg.Name)
+			}
+			if v, err = scope.packageVariable(pkg.Name + "." + x.Sel.Name); v != nil || err != nil {
+				return v, err
+			}
+			return nil, fmt.Errorf("could not find symbol value for %s", pkg.Name)
+		}
+		v, err := scope.eval(x.X)
+		if err != nil {
+			return nil, err
+		}
+		return scope.member(v, x.Sel.Name, exprString(x.X))
+	case *ast.StarExpr:
+		v, err := scope.eval(x.X)
+		if err != nil {
+			return nil, err
+		}
+		return scope.deref(v, exprString(x.X))
+	case *ast.UnaryExpr:
+		return scope.evalUnary(x)
+	case *ast.BinaryExpr:
+		return scope.evalBinary(x)
+	case *ast.IndexExpr:
+		return scope.evalIndex(x)
+	case *ast.SliceExpr:
+		return scope.evalSlice(x)
+	case *ast.CallExpr:
+		return scope.evalCall(x)
+	case *ast.TypeAssertExpr:
+		return scope.evalTypeAssertion(x)
+	}
+	return nil, fmt.Errorf("expression %s not supported", exprString(x))
+}
+
+func evalLiteral(x *ast.BasicLit) (*evalValue, error) {
+	switch x.Kind {
+	case token.INT:
+		if n, err := strconv.ParseInt(x.Value, 0, 64); err == nil {
+			return &evalValue{val: n}, nil
+		}
+		n, err := strconv.ParseUint(x.Value, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("constant %s overflows uint64", x.Value)
+		}
+		return &evalValue{val: n}, nil
+	case token.FLOAT:
+		f, err := strconv.ParseFloat(x.Value, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &evalValue{val: f}, nil
+	case token.STRING:
+		s, err := strconv.Unquote(x.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &evalValue{val: s}, nil
+	case token.CHAR:
+		s, err := strconv.Unquote(x.Value)
+		if err != nil {
+			return nil, err
+		}
+		return &evalValue{val: int64([]rune(s)[0])}, nil
+	}
+	return nil, fmt.Errorf("literal %s not supported", x.Value)
+}
+
+func (scope *EvalScope) evalIdent(name string) (*evalValue, error) {
+	v, err := scope.lookupIdent(name)
+	if err == nil && v == nil {
+		err = fmt.Errorf("could not find symbol value for %s", name)
+	}
+	return v, err
+}
+
+// lookupIdent looks name up among the variables of the scope, the
+// predeclared constants, then among the package variables of the package
+// of the function of the scope. It returns nil if there's no such name.
+func (scope *EvalScope) lookupIdent(name string) (*evalValue, error) {
+	addr, typ, err := scope.variableAddr(name)
+	if err != nil {
+		return nil, err
+	}
+	if typ != nil {
+		return &evalValue{typ: typ, addr: addr}, nil
+	}
+	switch name {
+	case "true", "false":
+		return &evalValue{val: name == "true"}, nil
+	case "nil":
+		return &evalValue{}, nil
+	}
+	if _, _, fn := scope.Thread.dbp.goSymTable.PCToLine(scope.PC); fn != nil {
+		return scope.packageVariable(fn.PackageName() + "." + name)
+	}
+	return nil, nil
+}
+
+// variableAddr returns the address and type of the named variable of the
+// function of the scope, a nil type if there is no such variable in scope.
+// Variables of inner lexical blocks shadow those of enclosing ones.
+func (scope *EvalScope) variableAddr(name string) (int64, dwarf.Type, error) {
+	vars, err := scope.scopeVariables()
+	if err != nil {
+		return 0, nil, err
+	}
+	if i := innermostVariable(vars, name); i >= 0 {
+		return scope.entryAddr(vars[i].Entry)
+	}
+	return 0, nil, nil
+}
+
+// packageVariable returns the package variable with the given package
+// qualified name, nil if there is none. The package can be given by its
+// full import path or by its last element.
+func (scope *EvalScope) packageVariable(name string) (*evalValue, error) {
+	reader := scope.Thread.dbp.DwarfReader()
+	for entry, err := reader.NextPackageVariable(); entry != nil; entry, err = reader.NextPackageVariable() {
+		if err != nil {
+			return nil, err
+		}
+		n, _ := entry.Val(dwarf.AttrName).(string)
+		if n != name && !strings.HasSuffix(n, "/"+name) {
+			continue
+		}
+		addr, typ, err := scope.entryAddr(entry)
+		if err != nil {
+			return nil, err
+		}
+		return &evalValue{typ: typ, addr: addr}, nil
+	}
+	return nil, nil
+}
+
+// entryAddr returns the address and type of the variable described by entry.
+func (scope *EvalScope) entryAddr(entry *dwarf.Entry) (int64, dwarf.Type, error) {
+	offset, ok := entry.Val(dwarf.AttrType).(dwarf.Offset)
+	if !ok {
+		return 0, nil, fmt.Errorf("type assertion failed")
+	}
+	typ, err := scope.Thread.dbp.dwarf.Type(offset)
+	if err != nil {
+		return 0, nil, err
+	}
+	addr, err := scope.locate(entry, typ)
+	if err != nil {
+		return 0, nil, err
+	}
+	return addr, typ, nil
+}
+
+// pointerValue returns the value of the pointer, map or channel v.
+func (scope *EvalScope) pointerValue(v *evalValue) (uint64, error) {
+	if v.addr == 0 {
+		p, ok := v.val.(uint64)
+		if !ok {
+			return 0, fmt.Errorf("%v is not a pointer", v.val)
+		}
+		return p, nil
+	}
+	return scope.Thread.readUintRaw(uintptr(v.addr), int64(scope.Thread.dbp.arch.PtrSize()))
+}
+
+// member selects the member name of the struct v, or of the struct v
+// points to, through any number of pointers. vname is the expression v
+// was evaluated from.
+func (scope *EvalScope) member(v *evalValue, name, vname string) (*evalValue, error) {
+	// Find the member first, reporting missing members before nil pointers.
+	var (
+		typ  = resolveTypedef(v.typ)
+		ptrs int
+	)
+	for {
+		ptr, ok := typ.(*dwarf.PtrType)
+		if !ok {
+			break
+		}
+		typ = resolveTypedef(ptr.Type)
+		ptrs++
+	}
+	st, ok := typ.(*dwarf.StructType)
+	if !ok || st.StructName == "string" || strings.HasPrefix(st.StructName, "[]") || (v.typ != nil && isInterfaceType(v.typ)) {
+		return nil, fmt.Errorf("%s (type %s) has no member %s", vname, valueType(v), name)
+	}
+	path := fieldPath(st, name)
+	if path == nil {
+		return nil, fmt.Errorf("%s has no member %s", vname, name)
+	}
+
+	addr := v.addr
+	for i := 0; i < ptrs; i++ {
+		p, err := scope.pointerValue(v)
+		if err != nil {
+			return nil, err
+		}
+		if p == 0 {
+			return nil, fmt.Errorf("%s is nil", vname)
+		}
+		addr = int64(p)
+		v = &evalValue{addr: addr}
+	}
+	// Go through the embedded fields a promoted field belongs to.
+	for _, f := range path[:len(path)-1] {
+		v = &evalValue{typ: f.Type, addr: addr + f.ByteOffset}
+		if _, ok := resolveTypedef(f.Type).(*dwarf.PtrType); ok {
+			p, err := scope.pointerValue(v)
+			if err != nil {
+				return nil, err
+			}
+			if p == 0 {
+				return nil, fmt.Errorf("%s.%s is nil", vname, f.Name)
+			}
+			addr = int64(p)
+			continue
+		}
+		addr = v.addr
+	}
+	field := path[len(path)-1]
+	return &evalValue{typ: field.Type, addr: addr + field.ByteOffset}, nil
+}
+
+// fieldPath returns the field name of st, preceded by the embedded fields
+// it's promoted from, if any. Shallower fields take precedence.
+func fieldPath(st *dwarf.StructType, name string) []*dwarf.StructField {
+	type candidate struct {
+		st   *dwarf.StructType
+		path []*dwarf.StructField
+	}
+	var (
+		level = []candidate{{st: st}}
+		seen  = map[*dwarf.StructType]bool{st: true}
+	)
+	for len(level) > 0 {
+		var next []candidate
+		for _, c := range level {
+			for _, f := range c.st.Field {
+				path := append(append([]*dwarf.StructField(nil), c.path...), f)
+				if f.Name == name {
+					return path
+				}
+				if est := embeddedStruct(f); est != nil && !seen[est] {
+					seen[est] = true
+					next = append(next, candidate{est, path})
+				}
+			}
+		}
+		level = next
+	}
+	return nil
+}
+
+// embeddedStruct returns the struct type of f if it's an embedded field,
+// nil otherwise. Embedded fields are named after their type, without its
+// package.
+func embeddedStruct(f *dwarf.StructField) *dwarf.StructType {
+	typ := resolveTypedef(f.Type)
+	if ptr, ok := typ.(*dwarf.PtrType); ok {
+		typ = resolveTypedef(ptr.Type)
+	}
+	st, ok := typ.(*dwarf.StructType)
+	if !ok || st.StructName == "string" || strings.HasPrefix(st.StructName, "[]") {
+		return nil
+	}
+	name := st.StructName
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	if name != f.Name {
+		return nil
+	}
+	return st
+}
+
+// deref dereferences the pointer v. vname is the expression v was
+// evaluated from.
+func (scope *EvalScope) deref(v *evalValue, vname string) (*evalValue, error) {
+	ptr, ok := resolveTypedef(v.typ).(*dwarf.PtrType)
+	if !ok || isUnsafePointer(ptr) {
+		return nil, fmt.Errorf("invalid indirect of %s (type %s)", vname, valueType(v))
+	}
+	p, err := scope.pointerValue(v)
+	if err != nil {
+		return nil, err
+	}
+	if p == 0 {
+		return nil, fmt.Errorf("%s is nil", vname)
+	}
+	return &evalValue{typ: ptr.Type, addr: int64(p)}, nil
+}
+
+func (scope *EvalScope) evalUnary(x *ast.UnaryExpr) (*evalValue, error) {
+	v, err := scope.eval(x.X)
+	if err != nil {
+		return nil, err
+	}
+	if x.Op == token.AND {
+		if v.addr == 0 || isFakeAddress(v.addr) {
+			return nil, fmt.Errorf("cannot take the address of %s", exprString(x.X))
+		}
+		ptrtyp := &dwarf.PtrType{Type: v.typ}
+		ptrtyp.ByteSize = int64(scope.Thread.dbp.arch.PtrSize())
+		ptrtyp.Name = "*" + goTypeName(v.typ)
+		return &evalValue{typ: ptrtyp, val: uint64(v.addr)}, nil
+	}
+
+	val, err := scope.scalar(v)
+	if err != nil {
+		return nil, err
+	}
+	var r interface{}
+	switch n := val.(type) {
+	case int64:
+		switch x.Op {
+		case token.ADD:
+			r = n
+		case token.SUB:
+			r = -n
+		case token.XOR:
+			r = ^n
+		}
+	case uint64:
+		switch x.Op {
+		case token.ADD:
+			r = n
+		case token.SUB:
+			r = -n
+		case token.XOR:
+			r = ^n
+		}
+	case float64:
+		switch x.Op {
+		case token.ADD:
+			r = n
+		case token.SUB:
+			r = -n
+		}
+	case bool:
+		if x.Op == token.NOT {
+			r = !n
+		}
+	}
+	if r == nil {
+		return nil, fmt.Errorf("operator %s not defined on %s (type %s)", x.Op, exprString(x.X), valueType(v))
+	}
+	return typedValue(r, v.typ)
+}
+
+func (scope *EvalScope) evalBinary(x *ast.BinaryExpr) (*evalValue, error) {
+	if x.Op == token.LAND || x.Op == token.LOR {
+		// Short circuit, like Go.
+		l, err := scope.evalBool(x.X)
+		if err != nil {
+			return nil, err
+		}
+		if l == (x.Op == token.LOR) {
+			return &evalValue{val: l}, nil
+		}
+		r, err := scope.evalBool(x.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &evalValue{val: r}, nil
+	}
+
+	l, err := scope.eval(x.X)
+	if err != nil {
+		return nil, err
+	}
+	r, err := scope.eval(x.Y)
+	if err != nil {
+		return nil, err
+	}
+	if isNil(l) || isNil(r) {
+		return scope.compareNil(x, l, r)
+	}
+
+	lval, err := scope.scalar(l)
+	if err != nil {
+		return nil, err
+	}
+	rval, err := scope.scalar(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if x.Op == token.SHL || x.Op == token.SHR {
+		var count uint64
+		switch n := rval.(type) {
+		case int64:
+			if n < 0 {
+				return nil, fmt.Errorf("negative shift count %s", exprString(x.Y))
+			}
+			count = uint64(n)
+		case uint64:
+			count = n
+		default:
+			return nil, fmt.Errorf("invalid shift count %s (type %s)", exprString(x.Y), valueType(r))
+		}
+		switch n := lval.(type) {
+		case int64:
+			if x.Op == token.SHL {
+				return typedValue(n<<count, l.typ)
+			}
+			return typedValue(n>>count, l.typ)
+		case uint64:
+			if x.Op == token.SHL {
+				return typedValue(n<<count, l.typ)
+			}
+			return typedValue(n>>count, l.typ)
+		}
+		return nil, fmt.Errorf("invalid shift of %s (type %s)", exprString(x.X), valueType(l))
+	}
+
+	// Convert the operands to a common type.
+	typ := l.typ
+	switch {
+	case l.typ == nil:
+		typ = r.typ
+	case r.typ != nil && goTypeName(l.typ) != goTypeName(r.typ):
+		return nil, fmt.Errorf("mismatched types %s and %s in %s", goTypeName(l.typ), goTypeName(r.typ), exprString(x))
+	}
+	if typ == nil {
+		// Untyped constants, mix integers and floats like Go does.
+		_, lf := lval.(float64)
+		_, rf := rval.(float64)
+		if lf || rf {
+			typ = basicType("float64", scope.Thread.dbp.arch.PtrSize())
+		}
+	}
+	if typ != nil {
+		if lval, err = convertOperand(lval, l.typ, typ); err != nil {
+			return nil, err
+		}
+		if rval, err = convertOperand(rval, r.typ, typ); err != nil {
+			return nil, err
+		}
+	} else if li, ok := lval.(int64); ok {
+		if _, ok := rval.(uint64); ok {
+			lval = uint64(li)
+		}
+	} else if ri, ok := rval.(int64); ok {
+		if _, ok := lval.(uint64); ok {
+			rval = uint64(ri)
+		}
+	}
+
+	switch x.Op {
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		b, err := compare(x.Op, lval, rval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid operation %s: %s", exprString(x), err)
+		}
+		return &evalValue{val: b}, nil
+	}
+	res, err := arith(x.Op, lval, rval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid operation %s: %s", exprString(x), err)
+	}
+	return typedValue(res, typ)
+}
+
+// evalBool evaluates the boolean expression x.
+func (scope *EvalScope) evalBool(x ast.Expr) (bool, error) {
+	v, err := scope.eval(x)
+	if err != nil {
+		return false, err
+	}
+	val, err := scope.scalar(v)
+	if err != nil {
+		return false, err
+	}
+	b, ok := val.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s (type %s) is not a boolean", exprString(x), valueType(v))
+	}
+	return b, nil
+}
+
+func isNil(v *evalValue) bool {
+	return v.typ == nil && v.addr == 0 && v.val == nil
+}
+
+// compareNil evaluates the comparison x of l and r, one of which is nil.
+func (scope *EvalScope) compareNil(x *ast.BinaryExpr, l, r *evalValue) (*evalValue, error) {
+	if x.Op != token.EQL && x.Op != token.NEQ {
+		return nil, fmt.Errorf("operator %s not defined on nil", x.Op)
+	}
+	v := l
+	if isNil(l) {
+		v = r
+	}
+	var isnil bool
+	switch {
+	case isNil(v):
+		isnil = true
+	case isInterfaceType(v.typ):
+		name, _, _, err := scope.Thread.interfaceValue(uintptr(v.addr), v.typ.(*dwarf.TypedefType))
+		if err != nil {
+			return nil, err
+		}
+		isnil = name == ""
+	default:
+		switch t := resolveTypedef(v.typ).(type) {
+		case *dwarf.PtrType, *dwarf.FuncType:
+			p, err := scope.pointerValue(v)
+			if err != nil {
+				return nil, err
+			}
+			isnil = p == 0
+		case *dwarf.StructType:
+			if !strings.HasPrefix(t.StructName, "[]") {
+				return nil, fmt.Errorf("cannot compare %s (type %s) to nil", exprString(x), valueType(v))
+			}
+			h, err := scope.sliceOf(v)
+			if err != nil {
+				return nil, err
+			}
+			isnil = h.base == 0
+		default:
+			return nil, fmt.Errorf("cannot compare %s (type %s) to nil", exprString(x), valueType(v))
+		}
+	}
+	return &evalValue{val: isnil == (x.Op == token.EQL)}, nil
+}
+
+func compare(op token.Token, l, r interface{}) (bool, error) {
+	var c int // -1, 0 or 1 as l is less than, equal to or greater than r.
+	switch l := l.(type) {
+	case bool:
+		r, ok := r.(bool)
+		if !ok || (op != token.EQL && op != token.NEQ) {
+			return false, fmt.Errorf("operator %s not defined on bool", op)
+		}
+		if l != r {
+			c = 1
+		}
+	case int64:
+		r, ok := r.(int64)
+		if !ok {
+			return false, fmt.Errorf("mismatched operands")
+		}
+		c = cmpOrder(l < r, l > r)
+	case uint64:
+		r, ok := r.(uint64)
+		if !ok {
+			return false, fmt.Errorf("mismatched operands")
+		}
+		c = cmpOrder(l < r, l > r)
+	case float64:
+		r, ok := r.(float64)
+		if !ok {
+			return false, fmt.Errorf("mismatched operands")
+		}
+		c = cmpOrder(l < r, l > r)
+	case string:
+		r, ok := r.(string)
+		if !ok {
+			return false, fmt.Errorf("mismatched operands")
+		}
+		c = cmpOrder(l < r, l > r)
+	default:
+		return false, fmt.Errorf("operands can't be compared")
+	}
+	switch op {
+	case token.EQL:
+		return c == 0, nil
+	case token.NEQ:
+		return c != 0, nil
+	case token.LSS:
+		return c < 0, nil
+	case token.LEQ:
+		return c <= 0, nil
+	case token.GTR:
+		return c > 0, nil
+	}
+	return c >= 0, nil
+}
+
+func cmpOrder(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+func arith(op token.Token, l, r interface{}) (interface{}, error) {
+	switch l := l.(type) {
+	case int64:
+		r, ok := r.(int64)
+		if !ok {
+			return nil, fmt.Errorf("mismatched operands")
+		}
+		switch op {
+		case token.ADD:
+			return l + r, nil
+		case token.SUB:
+			return l - r, nil
+		case token.MUL:
+			return l * r, nil
+		case token.QUO, token.REM:
+			if r == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			if op == token.QUO {
+				return l / r, nil
+			}
+			return l % r, nil
+		case token.AND:
+			return l & r, nil
+		case token.OR:
+			return l | r, nil
+		case token.XOR:
+			return l ^ r, nil
+		case token.AND_NOT:
+			return l &^ r, nil
+		}
+	case uint64:
+		r, ok := r.(uint64)
+		if !ok {
+			return nil, fmt.Errorf("mismatched operands")
+		}
+		switch op {
+		case token.ADD:
+			return l + r, nil
+		case token.SUB:
+			return l - r, nil
+		case token.MUL:
+			return l * r, nil
+		case token.QUO, token.REM:
+			if r == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			if op == token.QUO {
+				return l / r, nil
+			}
+			return l % r, nil
+		case token.AND:
+			return l & r, nil
+		case token.OR:
+			return l | r, nil
+		case token.XOR:
+			return l ^ r, nil
+		case token.AND_NOT:
+			return l &^ r, nil
+		}
+	case float64:
+		r, ok := r.(float64)
+		if !ok {
+			return nil, fmt.Errorf("mismatched operands")
+		}
+		switch op {
+		case token.ADD:
+			return l + r, nil
+		case token.SUB:
+			return l - r, nil
+		case token.MUL:
+			return l * r, nil
+		case token.QUO:
+			return l / r, nil
+		}
+	case string:
+		r, ok := r.(string)
+		if ok && op == token.ADD {
+			return l + r, nil
+		}
+	}
+	return nil, fmt.Errorf("operator %s not defined on %T", op, l)
+}
+
+// scalar returns the value of the number, boolean, string or pointer v:
+// an int64 for signed integers, uint64 for unsigned ones and pointers,
+// float64, bool or string.
+func (scope *EvalScope) scalar(v *evalValue) (interface{}, error) {
+	if v.addr == 0 {
+		if _, ok := v.val.(sliceHeader); ok {
+			return nil, fmt.Errorf("slice can only be compared to nil")
+		}
+		return v.val, nil
+	}
+	thread := scope.Thread
+	addr := uintptr(v.addr)
+	switch t := resolveTypedef(v.typ).(type) {
+	case *dwarf.IntType:
+		return thread.readIntRaw(addr, t.ByteSize)
+	case *dwarf.CharType:
+		return thread.readIntRaw(addr, t.ByteSize)
+	case *dwarf.UintType:
+		return thread.readUintRaw(addr, t.ByteSize)
+	case *dwarf.UcharType:
+		return thread.readUintRaw(addr, t.ByteSize)
+	case *dwarf.FloatType:
+		s, err := thread.readFloat(addr, t.ByteSize)
+		if err != nil {
+			return nil, err
+		}
+		return strconv.ParseFloat(s, 64)
+	case *dwarf.BoolType:
+		b, err := thread.readMemory(addr, 1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case *dwarf.PtrType:
+		return thread.readUintRaw(addr, int64(thread.dbp.arch.PtrSize()))
+	case *dwarf.StructType:
+		if t.StructName == "string" {
+			return thread.readString(addr)
+		}
+	}
+	return nil, fmt.Errorf("value of type %s is not a number, boolean, string or pointer", valueType(v))
+}
+
+// convertScalar converts the scalar val to the representation of the
+// numeric, boolean or string type typ, truncating integers to its size.
+func convertScalar(val interface{}, typ dwarf.Type) (interface{}, error) {
+	switch t := resolveTypedef(typ).(type) {
+	case *dwarf.IntType, *dwarf.CharType:
+		var n int64
+		switch v := val.(type) {
+		case int64:
+			n = v
+		case uint64:
+			n = int64(v)
+		case float64:
+			n = int64(v)
+		default:
+			return nil, fmt.Errorf("cannot convert %v to %s", val, goTypeName(typ))
+		}
+		shift := uint(64 - 8*t.Size())
+		return n << shift >> shift, nil
+	case *dwarf.UintType, *dwarf.UcharType, *dwarf.PtrType:
+		var n uint64
+		switch v := val.(type) {
+		case int64:
+			n = uint64(v)
+		case uint64:
+			n = v
+		case float64:
+			n = uint64(v)
+		default:
+			return nil, fmt.Errorf("cannot convert %v to %s", val, goTypeName(typ))
+		}
+		if t.Size() < 8 {
+			n &= 1<<uint(8*t.Size()) - 1
+		}
+		return n, nil
+	case *dwarf.FloatType:
+		var f float64
+		switch v := val.(type) {
+		case int64:
+			f = float64(v)
+		case uint64:
+			f = float64(v)
+		case float64:
+			f = v
+		default:
+			return nil, fmt.Errorf("cannot convert %v to %s", val, goTypeName(typ))
+		}
+		if t.ByteSize == 4 {
+			f = float64(float32(f))
+		}
+		return f, nil
+	case *dwarf.BoolType:
+		if _, ok := val.(bool); ok {
+			return val, nil
+		}
+	case *dwarf.StructType:
+		if _, ok := val.(string); ok && t.StructName == "string" {
+			return val, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %v to %s", val, goTypeName(typ))
+}
+
+// convertOperand converts the operand val of type vtyp to typ. Untyped
+// constants, with a nil vtyp, must be representable by typ.
+func convertOperand(val interface{}, vtyp, typ dwarf.Type) (interface{}, error) {
+	if vtyp == nil {
+		return convertConstant(val, typ)
+	}
+	return convertScalar(val, typ)
+}
+
+// convertConstant converts the untyped constant val to typ like
+// convertScalar, returning an error instead of truncating it if typ can't
+// represent it, as the Go compiler does.
+func convertConstant(val interface{}, typ dwarf.Type) (interface{}, error) {
+	cval, err := convertScalar(val, typ)
+	if err != nil {
+		return nil, err
+	}
+	f, isFloat := val.(float64)
+	switch c := cval.(type) {
+	case int64:
+		if isFloat && f != math.Trunc(f) {
+			return nil, fmt.Errorf("constant %v truncated to integer", val)
+		}
+		switch v := val.(type) {
+		case int64:
+			err = overflow(c == v, val, typ)
+		case uint64:
+			err = overflow(v <= math.MaxInt64 && c == int64(v), val, typ)
+		case float64:
+			err = overflow(float64(c) == v, val, typ)
+		}
+	case uint64:
+		if isFloat && f != math.Trunc(f) {
+			return nil, fmt.Errorf("constant %v truncated to integer", val)
+		}
+		switch v := val.(type) {
+		case int64:
+			err = overflow(v >= 0 && c == uint64(v), val, typ)
+		case uint64:
+			err = overflow(c == v, val, typ)
+		case float64:
+			err = overflow(v >= 0 && float64(c) == v, val, typ)
+		}
+	case float64:
+		err = overflow(!math.IsInf(c, 0), val, typ)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cval, nil
+}
+
+// overflow returns the error of the constant val overflowing typ unless ok.
+func overflow(ok bool, val interface{}, typ dwarf.Type) error {
+	if ok {
+		return nil
+	}
+	return fmt.Errorf("constant %v overflows %s", val, goTypeName(typ))
+}
+
+// typedValue returns the computed value val of type typ, converting it to
+// the representation of typ.
+func typedValue(val interface{}, typ dwarf.Type) (*evalValue, error) {
+	if typ == nil {
+		return &evalValue{val: val}, nil
+	}
+	val, err := convertScalar(val, typ)
+	if err != nil {
+		return nil, err
+	}
+	return &evalValue{typ: typ, val: val}, nil
+}
+
+// intValue returns the value of the integer v.
+func (scope *EvalScope) intValue(v *evalValue, vname string) (int64, error) {
+	val, err := scope.scalar(v)
+	if err != nil {
+		return 0, err
+	}
+	switch n := val.(type) {
+	case int64:
+		return n, nil
+	case uint64:
+		if n > math.MaxInt64 {
+			return 0, fmt.Errorf("%s out of range", vname)
+		}
+		return int64(n), nil
+	case float64:
+		if n == math.Trunc(n) {
+			return int64(n), nil
+		}
+	}
+	return 0, fmt.Errorf("%s (type %s) is not an integer", vname, valueType(v))
+}
+
+// sliceOf returns the header of the slice v.
+func (scope *EvalScope) sliceOf(v *evalValue) (sliceHeader, error) {
+	if h, ok := v.val.(sliceHeader); ok && v.addr == 0 {
+		return h, nil
+	}
+	var (
+		h       sliceHeader
+		st      = resolveTypedef(v.typ).(*dwarf.StructType)
+		thread  = scope.Thread
+		ptrSize = int64(thread.dbp.arch.PtrSize())
+		err     error
+	)
+	for _, f := range st.Field {
+		addr := uintptr(v.addr + f.ByteOffset)
+		switch f.Name {
+		case "array":
+			if ptr, ok := f.Type.(*dwarf.PtrType); ok {
+				h.elem = ptr.Type
+			}
+			h.base, err = thread.readUintRaw(addr, ptrSize)
+		case "len":
+			h.len, err = thread.readIntRaw(addr, ptrSize)
+		case "cap":
+			h.cap, err = thread.readIntRaw(addr, ptrSize)
+		}
+		if err != nil {
+			return h, err
+		}
+	}
+	if h.elem == nil {
+		return h, fmt.Errorf("Invalid type %s in slice array", v.typ)
+	}
+	return h, nil
+}
+
+// sliceType returns the type of slices of elem on an architecture with
+// pointers of ptrSize bytes.
+func sliceType(elem dwarf.Type, ptrSize int) dwarf.Type {
+	var (
+		ptr = &dwarf.PtrType{Type: elem}
+		i   = basicType("int", ptrSize)
+		n   = int64(ptrSize)
+	)
+	ptr.ByteSize = n
+	ptr.Name = "*" + goTypeName(elem)
+	st := &dwarf.StructType{
+		StructName: "[]" + goTypeName(elem),
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "array", Type: ptr, ByteOffset: 0},
+			{Name: "len", Type: i, ByteOffset: n},
+			{Name: "cap", Type: i, ByteOffset: 2 * n},
+		},
+	}
+	st.ByteSize = 3 * n
+	return st
+}
+
+func (scope *EvalScope) evalIndex(x *ast.IndexExpr) (*evalValue, error) {
+	v, err := scope.eval(x.X)
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := v.typ.(*dwarf.TypedefType); ok && strings.HasPrefix(t.Name, "map[") && v.addr != 0 {
+		return scope.mapIndex(x, v, t)
+	}
+
+	idx, err := scope.eval(x.Index)
+	if err != nil {
+		return nil, err
+	}
+	i, err := scope.intValue(idx, exprString(x.Index))
+	if err != nil {
+		return nil, err
+	}
+	outOfRange := func(n int64) error {
+		if i < 0 || i >= n {
+			return fmt.Errorf("index out of range %d with length %d", i, n)
+		}
+		return nil
+	}
+
+	if ptr, ok := resolveTypedef(v.typ).(*dwarf.PtrType); ok {
+		// Pointers to arrays can be indexed like arrays.
+		if _, ok := resolveTypedef(ptr.Type).(*dwarf.ArrayType); ok {
+			if v, err = scope.deref(v, exprString(x.X)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	switch t := resolveTypedef(v.typ).(type) {
+	case *dwarf.ArrayType:
+		if err := outOfRange(t.Count); err != nil {
+			return nil, err
+		}
+		return &evalValue{typ: t.Type, addr: v.addr + i*scope.Thread.stride(t.Type)}, nil
+	case *dwarf.StructType:
+		switch {
+		case t.StructName == "string":
+			val, err := scope.scalar(v)
+			if err != nil {
+				return nil, err
+			}
+			s := val.(string)
+			if err := outOfRange(int64(len(s))); err != nil {
+				return nil, err
+			}
+			return &evalValue{typ: basicType("byte", scope.Thread.dbp.arch.PtrSize()), val: uint64(s[i])}, nil
+		case strings.HasPrefix(t.StructName, "[]"):
+			h, err := scope.sliceOf(v)
+			if err != nil {
+				return nil, err
+			}
+			if err := outOfRange(h.len); err != nil {
+				return nil, err
+			}
+			return &evalValue{typ: h.elem, addr: int64(h.base) + i*scope.Thread.stride(h.elem)}, nil
+		}
+	case nil:
+		if s, ok := v.val.(string); ok {
+			if err := outOfRange(int64(len(s))); err != nil {
+				return nil, err
+			}
+			return &evalValue{typ: basicType("byte", scope.Thread.dbp.arch.PtrSize()), val: uint64(s[i])}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid operation: %s (type %s does not support indexing)", exprString(x), valueType(v))
+}
+
+// mapIndex looks up the index of x in the map v of type t. Keys are
+// compared by value, as Go compares them.
+func (scope *EvalScope) mapIndex(x *ast.IndexExpr, v *evalValue, t *dwarf.TypedefType) (*evalValue, error) {
+	thread := scope.Thread
+	key, err := scope.eval(x.Index)
+	if err != nil {
+		return nil, err
+	}
+	mt, err := newMapType(t)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case isInterfaceType(mt.key):
+	case key.typ == nil:
+		val, err := convertConstant(key.val, mt.key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot use %s as type %s in map index: %s", exprString(x.Index), goTypeName(mt.key), err)
+		}
+		key = &evalValue{typ: mt.key, val: val}
+	case goTypeName(key.typ) != goTypeName(mt.key):
+		return nil, fmt.Errorf("cannot use %s (type %s) as type %s in map index", exprString(x.Index), goTypeName(key.typ), goTypeName(mt.key))
+	}
+	hmap, err := thread.readUintRaw(uintptr(v.addr), int64(thread.dbp.arch.PtrSize()))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		value   *evalValue
+		readErr error
+	)
+	err = thread.mapEntries(hmap, mt, func(k, val uintptr) bool {
+		var eq bool
+		if eq, readErr = scope.equal(&evalValue{typ: mt.key, addr: int64(k)}, key); readErr != nil || !eq {
+			return readErr == nil
+		}
+		value = &evalValue{typ: mt.value, addr: int64(val)}
+		return false
+	})
+	if err == nil {
+		err = readErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, fmt.Errorf("key %s not found in %s", exprString(x.Index), exprString(x.X))
+	}
+	return value, nil
+}
+
+// equal returns whether a and b, of the same type or one of them an
+// interface, are equal as Go compares them.
+func (scope *EvalScope) equal(a, b *evalValue) (bool, error) {
+	if isInterfaceType(a.typ) || isInterfaceType(b.typ) {
+		aname, adyn, err := scope.dynamicValue(a)
+		if err != nil {
+			return false, err
+		}
+		bname, bdyn, err := scope.dynamicValue(b)
+		if err != nil || aname != bname {
+			return false, err
+		}
+		if aname == "" {
+			// Both nil.
+			return true, nil
+		}
+		return scope.equal(adyn, bdyn)
+	}
+
+	switch t := resolveTypedef(a.typ).(type) {
+	case *dwarf.StructType:
+		if t.StructName == "string" || strings.HasPrefix(t.StructName, "[]") {
+			break
+		}
+		if a.addr == 0 || b.addr == 0 {
+			return false, fmt.Errorf("cannot compare values of type %s", goTypeName(a.typ))
+		}
+		for _, f := range t.Field {
+			eq, err := scope.equal(&evalValue{typ: f.Type, addr: a.addr + f.ByteOffset}, &evalValue{typ: f.Type, addr: b.addr + f.ByteOffset})
+			if err != nil || !eq {
+				return false, err
+			}
+		}
+		return true, nil
+	case *dwarf.ArrayType:
+		if a.addr == 0 || b.addr == 0 {
+			return false, fmt.Errorf("cannot compare values of type %s", goTypeName(a.typ))
+		}
+		stride := scope.Thread.stride(t.Type)
+		for i := int64(0); i < t.Count; i++ {
+			eq, err := scope.equal(&evalValue{typ: t.Type, addr: a.addr + i*stride}, &evalValue{typ: t.Type, addr: b.addr + i*stride})
+			if err != nil || !eq {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	aval, err := scope.scalar(a)
+	if err != nil {
+		return false, err
+	}
+	bval, err := scope.scalar(b)
+	if err != nil {
+		return false, err
+	}
+	if typ := a.typ; typ != nil || b.typ != nil {
+		if typ == nil {
+			typ = b.typ
+		}
+		if aval, err = convertOperand(aval, a.typ, typ); err != nil {
+			return false, err
+		}
+		if bval, err = convertOperand(bval, b.typ, typ); err != nil {
+			return false, err
+		}
+	}
+	return compare(token.EQL, aval, bval)
+}
+
+// dynamicValue returns the name of the dynamic type of v and the value it
+// holds if v is an interface, the name of the type of v and v otherwise.
+// The name is empty for nil interfaces.
+func (scope *EvalScope) dynamicValue(v *evalValue) (string, *evalValue, error) {
+	if !isInterfaceType(v.typ) {
+		if v.typ == nil {
+			return valueType(v), v, nil
+		}
+		return goTypeName(v.typ), v, nil
+	}
+	name, addr, typ, err := scope.Thread.interfaceValue(uintptr(v.addr), v.typ.(*dwarf.TypedefType))
+	if err != nil {
+		return "", nil, err
+	}
+	return name, &evalValue{typ: typ, addr: addr}, nil
+}
+
+func (scope *EvalScope) evalSlice(x *ast.SliceExpr) (*evalValue, error) {
+	v, err := scope.eval(x.X)
+	if err != nil {
+		return nil, err
+	}
+	bound := func(e ast.Expr, def int64) (int64, error) {
+		if e == nil {
+			return def, nil
+		}
+		bv, err := scope.eval(e)
+		if err != nil {
+			return 0, err
+		}
+		return scope.intValue(bv, exprString(e))
+	}
+
+	if ptr, ok := resolveTypedef(v.typ).(*dwarf.PtrType); ok {
+		if _, ok := resolveTypedef(ptr.Type).(*dwarf.ArrayType); ok {
+			if v, err = scope.deref(v, exprString(x.X)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Strings
+	if s, ok := v.val.(string); ok || isStringType(v.typ) {
+		if !ok {
+			val, err := scope.scalar(v)
+			if err != nil {
+				return nil, err
+			}
+			s = val.(string)
+		}
+		if x.Slice3 {
+			return nil, fmt.Errorf("invalid operation %s (3-index slice of string)", exprString(x))
+		}
+		lo, err := bound(x.Low, 0)
+		if err != nil {
+			return nil, err
+		}
+		hi, err := bound(x.High, int64(len(s)))
+		if err != nil {
+			return nil, err
+		}
+		if lo < 0 || hi < lo || hi > int64(len(s)) {
+			return nil, fmt.Errorf("slice bounds out of range [%d:%d] with length %d", lo, hi, len(s))
+		}
+		return &evalValue{typ: v.typ, val: s[lo:hi]}, nil
+	}
+
+	// Arrays and slices
+	var h sliceHeader
+	switch t := resolveTypedef(v.typ).(type) {
+	case *dwarf.ArrayType:
+		if v.addr == 0 {
+			return nil, fmt.Errorf("invalid operation %s (slice of unaddressable value)", exprString(x))
+		}
+		h = sliceHeader{base: uint64(v.addr), len: t.Count, cap: t.Count, elem: t.Type}
+	case *dwarf.StructType:
+		if !strings.HasPrefix(t.StructName, "[]") {
+			return nil, fmt.Errorf("cannot slice %s (type %s)", exprString(x.X), valueType(v))
+		}
+		if h, err = scope.sliceOf(v); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("cannot slice %s (type %s)", exprString(x.X), valueType(v))
+	}
+	lo, err := bound(x.Low, 0)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := bound(x.High, h.len)
+	if err != nil {
+		return nil, err
+	}
+	max, err := bound(x.Max, h.cap)
+	if err != nil {
+		return nil, err
+	}
+	if lo < 0 || hi < lo || max < hi || max > h.cap {
+		return nil, fmt.Errorf("slice bounds out of range [%d:%d:%d] with capacity %d", lo, hi, max, h.cap)
+	}
+	typ := v.typ
+	if _, ok := resolveTypedef(typ).(*dwarf.ArrayType); ok {
+		typ = sliceType(h.elem, scope.Thread.dbp.arch.PtrSize())
+	}
+	return &evalValue{typ: typ, val: sliceHeader{
+		base: h.base + uint64(lo*scope.Thread.stride(h.elem)),
+		len:  hi - lo,
+		cap:  max - lo,
+		elem: h.elem,
+	}}, nil
+}
+
+func isStringType(t dwarf.Type) bool {
+	st, ok := resolveTypedef(t).(*dwarf.StructType)
+	return ok && st.StructName == "string"
+}
+
+func isInterfaceType(t dwarf.Type) bool {
+	tt, ok := t.(*dwarf.TypedefType)
+	return ok && isInterface(tt)
+}
+
+func (scope *EvalScope) evalCall(x *ast.CallExpr) (*evalValue, error) {
+	fn, ok := x.Fun.(*ast.Ident)
+	if !ok || x.Ellipsis.IsValid() {
+		return nil, fmt.Errorf("function calls are not supported: %s", exprString(x))
+	}
+	if len(x.Args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments to %s", fn.Name)
+	}
+	arg, err := scope.eval(x.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	switch fn.Name {
+	case "len", "cap":
+		n, err := scope.lenOrCap(fn.Name, arg, exprString(x.Args[0]))
+		if err != nil {
+			return nil, err
+		}
+		return &evalValue{typ: basicType("int", scope.Thread.dbp.arch.PtrSize()), val: n}, nil
+	}
+	if typ := basicType(fn.Name, scope.Thread.dbp.arch.PtrSize()); typ != nil {
+		val, err := scope.scalar(arg)
+		if err != nil {
+			return nil, err
+		}
+		return typedValue(val, typ)
+	}
+	return nil, fmt.Errorf("function calls are not supported: %s", exprString(x))
+}
+
+// lenOrCap evaluates len or cap, according to fn, of v. vname is the
+// expression v was evaluated from.
+func (scope *EvalScope) lenOrCap(fn string, v *evalValue, vname string) (int64, error) {
+	thread := scope.Thread
+	invalid := fmt.Errorf("invalid argument %s (type %s) for %s", vname, valueType(v), fn)
+	if s, ok := v.val.(string); ok && v.addr == 0 && fn == "len" {
+		return int64(len(s)), nil
+	}
+	if t, ok := v.typ.(*dwarf.TypedefType); ok && v.addr != 0 {
+		switch {
+		case strings.HasPrefix(t.Name, "map["):
+			if fn != "len" {
+				return 0, invalid
+			}
+			mt, err := newMapType(t)
+			if err != nil {
+				return 0, err
+			}
+			hmap, err := thread.readUintRaw(uintptr(v.addr), int64(thread.dbp.arch.PtrSize()))
+			if err != nil {
+				return 0, err
+			}
+			return thread.mapLen(hmap, mt)
+		case isChan(t):
+			ch, err := thread.readChannel(uintptr(v.addr), t, 0)
+			if err != nil {
+				return 0, err
+			}
+			if fn == "len" {
+				return ch.Len, nil
+			}
+			return ch.Cap, nil
+		}
+	}
+	typ := resolveTypedef(v.typ)
+	if ptr, ok := typ.(*dwarf.PtrType); ok {
+		// Pointers to arrays have the length of the array.
+		typ = resolveTypedef(ptr.Type)
+		if _, ok := typ.(*dwarf.ArrayType); !ok {
+			return 0, invalid
+		}
+	}
+	switch t := typ.(type) {
+	case *dwarf.ArrayType:
+		return t.Count, nil
+	case *dwarf.StructType:
+		switch {
+		case t.StructName == "string" && fn == "len":
+			// Read just the length, the string can be large.
+			return thread.readIntRaw(uintptr(v.addr)+uintptr(thread.dbp.arch.PtrSize()), int64(thread.dbp.arch.PtrSize()))
+		case strings.HasPrefix(t.StructName, "[]"):
+			h, err := scope.sliceOf(v)
+			if err != nil {
+				return 0, err
+			}
+			if fn == "len" {
+				return h.len, nil
+			}
+			return h.cap, nil
+		}
+	}
+	return 0, invalid
+}
+
+// evalTypeAssertion evaluates the type assertion x to the dynamic type of
+// an interface, which must match the asserted type exactly.
+func (scope *EvalScope) evalTypeAssertion(x *ast.TypeAssertExpr) (*evalValue, error) {
+	if x.Type == nil {
+		return nil, fmt.Errorf("use of .(type) outside type switch")
+	}
+	v, err := scope.eval(x.X)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := v.typ.(*dwarf.TypedefType)
+	if !ok || !isInterface(t) || v.addr == 0 {
+		return nil, fmt.Errorf("invalid type assertion: %s (non-interface type %s on left)", exprString(x), valueType(v))
+	}
+	asserted := exprString(x.Type)
+	name, valAddr, dyntyp, err := scope.Thread.interfaceValue(uintptr(v.addr), t)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, fmt.Errorf("interface conversion: %s is nil, not %s", t.Name, asserted)
+	}
+	if name != asserted {
+		return nil, fmt.Errorf("interface conversion: %s is %s, not %s", t.Name, name, asserted)
+	}
+	return &evalValue{typ: dyntyp, addr: valAddr}, nil
+}
+
+// goTypeName returns the Go name of t.
+func goTypeName(t dwarf.Type) string {
+	switch t := t.(type) {
+	case *dwarf.StructType:
+		if t.StructName != "" {
+			return t.StructName
+		}
+	case *dwarf.PtrType:
+		if isUnsafePointer(t) {
+			return "unsafe.Pointer"
+		}
+		return "*" + goTypeName(t.Type)
+	case *dwarf.ArrayType:
+		return fmt.Sprintf("[%d]%s", t.Count, goTypeName(t.Type))
+	}
+	return t.String()
+}
+
+// valueType returns the name of the type of v, the default type of
+// untyped constants.
+func valueType(v *evalValue) string {
+	if v.typ != nil {
+		return typeString(v.typ)
+	}
+	switch v.val.(type) {
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case uint64:
+		return "uint64"
+	case float64:
+		return "float64"
+	case string:
+		return "string"
+	}
+	return "nil"
+}
+
+// newVariable returns the variable holding v, loaded by l.
+func (scope *EvalScope) newVariable(name string, v *evalValue, l *loader) (*Variable, error) {
+	if v.addr != 0 {
+		return l.loadVariable(name, v.addr, v.typ)
+	}
+	r := &Variable{Name: name, Type: valueType(v), Kind: kindOf(v.typ), typ: v.typ, numFormat: l.cfg.Format}
+	switch val := v.val.(type) {
+	case nil:
+		r.Value = "nil"
+		if r.Kind == reflect.Ptr {
+			r.isnil = true
+		} else {
+			r.Kind = reflect.Invalid
+		}
+	case bool:
+		r.Value = strconv.FormatBool(val)
+		if r.Kind == reflect.Invalid {
+			r.Kind = reflect.Bool
+		}
+	case int64:
+		r.Value = formatNumber(val, true, v.typ, l.cfg.Format)
+		if r.Kind == reflect.Invalid {
+			r.Kind = reflect.Int
+		}
+	case uint64:
+		ptr, ok := resolveTypedef(v.typ).(*dwarf.PtrType)
+		if !ok {
+			r.Value = formatNumber(int64(val), false, v.typ, l.cfg.Format)
+			if r.Kind == reflect.Invalid {
+				r.Kind = reflect.Uint64
+			}
+			break
+		}
+		if isUnsafePointer(ptr) {
+			r.Value = formatUnsafePointer(val)
+			break
+		}
+		if val == 0 {
+			r.isnil = true
+			break
+		}
+		child, err := l.load("", int64(val), ptr.Type, 0)
+		if err != nil {
+			return nil, err
+		}
+		r.Children = []*Variable{child}
+	case float64:
+		r.Value = strconv.FormatFloat(val, 'f', -1, 64)
+		if ft, ok := resolveTypedef(v.typ).(*dwarf.FloatType); ok && ft.ByteSize == 4 {
+			r.Value = strconv.FormatFloat(val, 'f', -1, 32)
+		}
+		if r.Kind == reflect.Invalid {
+			r.Kind = reflect.Float64
+		}
+	case string:
+		s := val
+		if len(s) > l.cfg.MaxStringLen {
+			s = s[:l.cfg.MaxStringLen]
+		}
+		r.Kind, r.Len, r.Value = reflect.String, int64(len(val)), formatString(s, int64(len(val)))
+	case sliceHeader:
+		r.typ = sliceType(val.elem, scope.Thread.dbp.arch.PtrSize())
+		r.Type, r.Kind, r.Len, r.Cap = r.typ.String(), reflect.Slice, val.len, val.cap
+		var err error
+		r.Children, err = l.loadElements(int64(val.base), val.len, scope.Thread.stride(val.elem), val.elem, 0)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("could not format value %v", v.val)
+	}
+	if !l.noValues {
+		r.setValues()
+	}
+	return r, nil
+}
@@ -0,0 +1,82 @@
+package proc
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+// Format is how the integers of a value are printed, as selected by the
+// /f modifiers of the print command of gdb.
+type Format byte
+
+const (
+	FormatDefault Format = 0
+	FormatHex     Format = 'x' // Hexadecimal.
+	FormatOctal   Format = 'o' // Octal.
+	FormatBinary  Format = 'b' // Binary.
+	FormatDecimal Format = 'd' // Decimal, without the character of runes.
+	FormatChar    Format = 'c' // Decimal followed by the character.
+	FormatString  Format = 's' // Byte slices and arrays as strings.
+)
+
+// ParseFormat returns the format named by the letter s, the default
+// format if s is empty.
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return FormatDefault, nil
+	}
+	if len(s) == 1 {
+		switch f := Format(s[0]); f {
+		case FormatHex, FormatOctal, FormatBinary, FormatDecimal, FormatChar, FormatString:
+			return f, nil
+		}
+	}
+	return FormatDefault, fmt.Errorf("unknown format %q: expected one of x, o, b, d, c or s", s)
+}
+
+// formatNumber renders the integer n of type t, signed or not, with format
+// f, as it's loaded.
+func formatNumber(n int64, signed bool, t dwarf.Type, f Format) string {
+	switch f {
+	case FormatDefault, FormatString:
+		if signed {
+			it, _ := resolveTypedef(t).(*dwarf.IntType)
+			return formatInt(n, it)
+		}
+		return strconv.FormatUint(uint64(n), 10)
+	}
+	size := int64(8)
+	if t != nil {
+		size = t.Size()
+	}
+	return formatInteger(n, signed, size, f)
+}
+
+// formatInteger renders the integer n of size bytes with format f.
+// Negative numbers are printed as their two's complement bit pattern in
+// hexadecimal, octal and binary.
+func formatInteger(n int64, signed bool, size int64, f Format) string {
+	u := uint64(n)
+	if size > 0 && size < 8 {
+		u &= 1<<uint(8*size) - 1
+	}
+	dec := strconv.FormatUint(uint64(n), 10)
+	if signed {
+		dec = strconv.FormatInt(n, 10)
+	}
+	switch f {
+	case FormatHex:
+		return fmt.Sprintf("%#x", u)
+	case FormatOctal:
+		return fmt.Sprintf("%#o", u)
+	case FormatBinary:
+		return "0b" + strconv.FormatUint(u, 2)
+	case FormatChar:
+		if (n >= 0 || !signed) && u <= unicode.MaxRune && unicode.IsPrint(rune(u)) {
+			return dec + " " + strconv.QuoteRune(rune(u))
+		}
+	}
+	return dec
+}
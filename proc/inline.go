@@ -0,0 +1,213 @@
+package proc
+
+import (
+	"debug/dwarf"
+	"debug/gosym"
+	"sort"
+
+	"github.com/derekparker/delve/dwarf/frame"
+)
+
+// InlinedCall is a call the compiler replaced with the body of the
+// called function.
+type InlinedCall struct {
+	Name     string      // Name of the inlined function.
+	Ranges   [][2]uint64 // PC ranges [low, high) of the inlined body.
+	CallFile string      // Location of the call.
+	CallLine int
+	Parent   *InlinedCall // Inlined call the call is part of, nil if none.
+	depth    int
+}
+
+// Entry returns the first PC of the inlined body.
+func (ic *InlinedCall) Entry() uint64 {
+	entry := ic.Ranges[0][0]
+	for _, r := range ic.Ranges[1:] {
+		if r[0] < entry {
+			entry = r[0]
+		}
+	}
+	return entry
+}
+
+func (ic *InlinedCall) contains(pc uint64) bool {
+	for _, r := range ic.Ranges {
+		if pc >= r[0] && pc < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// fn returns a function symbol standing for the inlined function.
+func (ic *InlinedCall) fn() *gosym.Func {
+	entry := ic.Entry()
+	return &gosym.Func{Entry: entry, Sym: &gosym.Sym{Name: ic.Name, Value: entry}}
+}
+
+// loadInlinedCalls reads the DW_TAG_inlined_subroutine entries of the
+// debug info.
+func (dbp *Process) loadInlinedCalls() error {
+	var (
+		rdr     = dbp.dwarf.Reader()
+		stack   []*InlinedCall // Enclosing entries, nil for the ones that aren't inlined calls.
+		origins = map[dwarf.Offset][]*InlinedCall{}
+		calls   = map[uint64][]*InlinedCall{}
+	)
+	for {
+		entry, err := rdr.Next()
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag == 0 {
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		var ic *InlinedCall
+		if entry.Tag == dwarf.TagInlinedSubroutine {
+			ranges, err := dbp.dwarf.Ranges(entry)
+			if err != nil {
+				return err
+			}
+			origin, ok := entry.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
+			if ok && len(ranges) > 0 {
+				ic = &InlinedCall{Ranges: ranges}
+				if file, ok := entry.Val(dwarf.AttrCallFile).(int64); ok && file > 0 && int(file) <= len(dbp.lineInfo.FileNames) {
+					ic.CallFile = dbp.lineInfo.FileNames[file-1].Name
+				}
+				if line, ok := entry.Val(dwarf.AttrCallLine).(int64); ok {
+					ic.CallLine = int(line)
+				}
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] != nil {
+						ic.Parent = stack[i]
+						ic.depth = stack[i].depth + 1
+						break
+					}
+				}
+				origins[origin] = append(origins[origin], ic)
+				if fn := dbp.goSymTable.PCToFunc(ic.Entry()); fn != nil {
+					calls[fn.Entry] = append(calls[fn.Entry], ic)
+				}
+			}
+		}
+		if entry.Children {
+			stack = append(stack, ic)
+		}
+	}
+
+	for off, ics := range origins {
+		rdr.Seek(off)
+		entry, err := rdr.Next()
+		if err != nil {
+			return err
+		}
+		name, _ := entry.Val(dwarf.AttrName).(string)
+		for _, ic := range ics {
+			ic.Name = name
+		}
+	}
+	dbp.inlinedCalls = calls
+	return nil
+}
+
+// InlinedCallsAt returns the inlined calls whose body contains pc,
+// innermost first.
+func (dbp *Process) InlinedCallsAt(pc uint64) []*InlinedCall {
+	fn := dbp.goSymTable.PCToFunc(pc)
+	if fn == nil {
+		return nil
+	}
+	var calls []*InlinedCall
+	for _, ic := range dbp.inlinedCalls[fn.Entry] {
+		if ic.contains(pc) {
+			calls = append(calls, ic)
+		}
+	}
+	sort.Sort(byDepth(calls))
+	return calls
+}
+
+// innermostInlinedCall returns the innermost inlined call containing pc,
+// nil if there is none.
+func (dbp *Process) innermostInlinedCall(pc uint64) *InlinedCall {
+	if calls := dbp.InlinedCallsAt(pc); len(calls) > 0 {
+		return calls[0]
+	}
+	return nil
+}
+
+// InlinedEntries returns the entry points of every inlined copy of the
+// named function.
+func (dbp *Process) InlinedEntries(name string) []uint64 {
+	var entries []uint64
+	for _, calls := range dbp.inlinedCalls {
+		for _, ic := range calls {
+			if ic.Name == name {
+				entries = append(entries, ic.Entry())
+			}
+		}
+	}
+	sort.Sort(uint64s(entries))
+	return entries
+}
+
+// byDepth sorts inlined calls innermost first.
+type byDepth []*InlinedCall
+
+func (s byDepth) Len() int           { return len(s) }
+func (s byDepth) Less(i, j int) bool { return s[i].depth > s[j].depth }
+func (s byDepth) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+type uint64s []uint64
+
+func (s uint64s) Len() int           { return len(s) }
+func (s uint64s) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint64s) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// inlinedNextPCs adjusts the PCs next sets temporary breakpoints on for
+// inlined calls: the bodies of the calls inlined in the lines being
+// stepped are stepped over, and when stepping inside an inlined body
+// execution also stops in its caller once the body is done.
+func (dbp *Process) inlinedNextPCs(curpc uint64, fde *frame.FrameDescriptionEntry, pcs []uint64) []uint64 {
+	var calls []*InlinedCall
+	if fn := dbp.goSymTable.PCToFunc(curpc); fn != nil {
+		calls = dbp.inlinedCalls[fn.Entry]
+	}
+	innermost := func(pc uint64) *InlinedCall {
+		var in *InlinedCall
+		for _, ic := range calls {
+			if ic.contains(pc) && (in == nil || ic.depth > in.depth) {
+				in = ic
+			}
+		}
+		return in
+	}
+
+	cur := innermost(curpc)
+	filtered := make([]uint64, 0, len(pcs))
+	for _, pc := range pcs {
+		if fde.Cover(pc) {
+			if innermost(pc) != cur {
+				continue
+			}
+		} else if dbp.innermostInlinedCall(pc) != nil {
+			// Another copy of the function, inlined in a different caller.
+			continue
+		}
+		filtered = append(filtered, pc)
+	}
+	if cur != nil {
+		for _, pc := range dbp.lineInfo.AllPCsBetween(fde.Begin(), fde.End()) {
+			if innermost(pc) == cur.Parent {
+				filtered = append(filtered, pc)
+			}
+		}
+	}
+	return filtered
+}
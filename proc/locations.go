@@ -0,0 +1,220 @@
+package proc
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/derekparker/delve/dwarf/op"
+)
+
+// fakeAddress is where the values of variables which are not in memory
+// are mapped while they're read. No process maps memory this high.
+const fakeAddress = 0xbeef000000000000
+
+// compositeMemory is the value of a variable stored in registers, or in
+// pieces scattered across registers and memory, mapped at addr so that it
+// is read like the value of any other variable.
+type compositeMemory struct {
+	addr uint64
+	data []byte
+}
+
+// isFakeAddress returns true if addr is in the range composite memory is
+// mapped at.
+func isFakeAddress(addr int64) bool {
+	return uint64(addr) >= fakeAddress
+}
+
+// mapCompositeMemory maps data in composite memory and returns its address.
+func (dbp *Process) mapCompositeMemory(data []byte) uint64 {
+	addr := uint64(fakeAddress)
+	if n := len(dbp.compositeMemory); n > 0 {
+		last := dbp.compositeMemory[n-1]
+		addr = (last.addr + uint64(len(last.data)) + 16) &^ 7
+	}
+	dbp.compositeMemory = append(dbp.compositeMemory, &compositeMemory{addr: addr, data: data})
+	return addr
+}
+
+// readCompositeMemory reads size bytes of composite memory at addr.
+func (dbp *Process) readCompositeMemory(addr uintptr, size int) ([]byte, error) {
+	for _, mem := range dbp.compositeMemory {
+		if uint64(addr) >= mem.addr && uint64(addr)+uint64(size) <= mem.addr+uint64(len(mem.data)) {
+			off := uint64(addr) - mem.addr
+			return append([]byte(nil), mem.data[off:off+uint64(size)]...), nil
+		}
+	}
+	return nil, fmt.Errorf("could not read %d bytes at %#x", size, addr)
+}
+
+// locationExpr returns the location expression of the variable described
+// by entry, choosing the one that applies at the PC of the scope if the
+// variable has a location list.
+func (scope *EvalScope) locationExpr(entry *dwarf.Entry) ([]byte, error) {
+	switch loc := entry.Val(dwarf.AttrLocation).(type) {
+	case []byte:
+		return loc, nil
+	case int64:
+		dbp := scope.Thread.dbp
+		if dbp.loclist == nil {
+			return nil, errors.New("could not find .debug_loc section in binary")
+		}
+		base, err := dbp.compileUnitBase(scope.PC)
+		if err != nil {
+			return nil, err
+		}
+		instructions, err := dbp.loclist.Find(loc, base, scope.PC)
+		if err != nil {
+			return nil, err
+		}
+		if instructions == nil {
+			name, _ := entry.Val(dwarf.AttrName).(string)
+			return nil, fmt.Errorf("%s is not available at %#x", name, scope.PC)
+		}
+		return instructions, nil
+	}
+	return nil, fmt.Errorf("type assertion failed")
+}
+
+// compileUnitBase returns the base address of the compile unit containing
+// pc, which the addresses of its location lists are relative to.
+func (dbp *Process) compileUnitBase(pc uint64) (uint64, error) {
+	cu, err := dbp.dwarf.Reader().SeekPC(pc)
+	if err != nil {
+		return 0, err
+	}
+	base, _ := cu.Val(dwarf.AttrLowpc).(uint64)
+	return base, nil
+}
+
+// opContext returns the context location expressions are executed in in
+// the frame of the scope.
+func (scope *EvalScope) opContext() *op.Context {
+	thread := scope.Thread
+	ctx := &op.Context{
+		CFA:     scope.CFA,
+		PtrSize: thread.dbp.arch.PtrSize(),
+		ReadMemory: func(addr uint64, size int) ([]byte, error) {
+			return thread.readMemory(uintptr(addr), size)
+		},
+	}
+	if scope.Regs != nil {
+		ctx.Reg = scope.Regs.Reg
+	}
+	ctx.FrameBase = func() (int64, error) {
+		return scope.frameBase(ctx)
+	}
+	return ctx
+}
+
+// frameBase returns the frame base of the function of the scope, which
+// the locations of its variables can be relative to.
+func (scope *EvalScope) frameBase(ctx *op.Context) (int64, error) {
+	if scope.frameBaseKnown {
+		return scope.fbase, nil
+	}
+	reader := scope.Thread.dbp.DwarfReader()
+	fn, err := reader.SeekToFunction(scope.PC)
+	if err != nil {
+		return 0, err
+	}
+	instructions, ok := fn.Val(dwarf.AttrFrameBase).([]byte)
+	if !ok {
+		return 0, fmt.Errorf("no frame base for function at %#x", scope.PC)
+	}
+	pieces, err := op.Execute(instructions, ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(pieces) != 1 || pieces[0].Kind != op.AddrPiece {
+		return 0, errors.New("frame base is not an address")
+	}
+	scope.fbase, scope.frameBaseKnown = pieces[0].Addr, true
+	return scope.fbase, nil
+}
+
+// locate returns the address of the variable of type typ described by
+// entry. The values of variables stored in registers or in pieces are
+// copied to composite memory, and their address is a fake one.
+func (scope *EvalScope) locate(entry *dwarf.Entry, typ dwarf.Type) (int64, error) {
+	instructions, err := scope.locationExpr(entry)
+	if err != nil {
+		return 0, err
+	}
+	pieces, err := op.Execute(instructions, scope.opContext())
+	if err != nil {
+		return 0, err
+	}
+	if len(pieces) == 1 && pieces[0].Kind == op.AddrPiece && pieces[0].BitSize == 0 {
+		return pieces[0].Addr, nil
+	}
+	data, err := scope.readPieces(pieces, typ.Size())
+	if err != nil {
+		return 0, err
+	}
+	return int64(scope.Thread.dbp.mapCompositeMemory(data)), nil
+}
+
+// readPieces assembles the size bytes of a value from the pieces of its
+// location. Pieces optimized away read as zeroes.
+func (scope *EvalScope) readPieces(pieces []op.Piece, size int64) ([]byte, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid size %d", size)
+	}
+	buf := make([]byte, size)
+	bit := 0
+	for _, p := range pieces {
+		bitSize := p.BitSize
+		if bitSize == 0 {
+			bitSize = len(buf) * 8
+		}
+		n := (p.BitOffset + bitSize + 7) / 8
+
+		var src []byte
+		switch p.Kind {
+		case op.AddrPiece:
+			var err error
+			if src, err = scope.Thread.readMemory(uintptr(p.Addr), n); err != nil {
+				return nil, err
+			}
+		case op.RegPiece:
+			var (
+				val uint64
+				ok  bool
+			)
+			if scope.Regs != nil {
+				val, ok = scope.Regs.Reg(p.Reg)
+			}
+			if !ok {
+				return nil, fmt.Errorf("register %d not available", p.Reg)
+			}
+			src = make([]byte, 8)
+			binary.LittleEndian.PutUint64(src, val)
+		case op.ImmPiece:
+			src = p.Bytes
+		}
+
+		for i := 0; i < bitSize; i++ {
+			sb, db := p.BitOffset+i, bit+i
+			if sb/8 < len(src) && db/8 < len(buf) && src[sb/8]&(1<<uint(sb%8)) != 0 {
+				buf[db/8] |= 1 << uint(db%8)
+			}
+		}
+		bit += bitSize
+	}
+	return buf, nil
+}
+
+// clearFakeAddrs zeroes the addresses of v and its children that point
+// into composite memory: those values are not in the memory of the process.
+func clearFakeAddrs(v *Variable) {
+	if !isFakeAddress(int64(v.Addr)) {
+		return
+	}
+	v.Addr = 0
+	for _, child := range v.Children {
+		clearFakeAddrs(child)
+	}
+}
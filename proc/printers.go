@@ -0,0 +1,555 @@
+package proc
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PrettyPrinter renders v, a variable of the type it's registered for, in
+// place of its fields. The children of v are rendered before v, within
+// the limits of the LoadConfig v was loaded with, and may be missing if v
+// is nested too deep; mem reads the memory of the process. When a printer
+// returns an error the value of v is rendered as usual.
+type PrettyPrinter func(v *Variable, mem MemoryReader) (string, error)
+
+// MemoryReader reads size bytes of the memory of the process at addr.
+type MemoryReader func(addr uint64, size int) ([]byte, error)
+
+var (
+	prettyPrintersMu sync.RWMutex
+	prettyPrinters   = map[string]PrettyPrinter{
+		"time.Time":                printTime,
+		"math/big.Int":             printBigInt,
+		"net.IP":                   printIP,
+		"sync.Mutex":               printMutex,
+		"strings.Builder":          printBuilder,
+		"bytes.Buffer":             printBuffer,
+		"context.backgroundCtx":    printConstant("context.Background"),
+		"context.todoCtx":          printConstant("context.TODO"),
+		"context.cancelCtx":        printCancelCtx,
+		"context.timerCtx":         printTimerCtx,
+		"context.valueCtx":         printValueCtx,
+		"context.withoutCancelCtx": printWithoutCancelCtx,
+	}
+)
+
+// RegisterPrettyPrinter makes p render the values of the type named name,
+// as reported in the Type of variables without the struct keyword, e.g.
+// time.Time. It replaces the printer registered for the type before, if
+// any, and unregisters it if p is nil.
+//
+// Printers are Go functions registered by the program proc is built into,
+// they can't be added through the RPC service: a headless dlv prints the
+// types above and those registered by its own code only.
+func RegisterPrettyPrinter(name string, p PrettyPrinter) {
+	prettyPrintersMu.Lock()
+	defer prettyPrintersMu.Unlock()
+	if p == nil {
+		delete(prettyPrinters, name)
+		return
+	}
+	prettyPrinters[name] = p
+}
+
+// prettyPrinter returns the printer of the type named typ, nil if none.
+func prettyPrinter(typ string) PrettyPrinter {
+	prettyPrintersMu.RLock()
+	defer prettyPrintersMu.RUnlock()
+	return prettyPrinters[strings.TrimPrefix(typ, "struct ")]
+}
+
+// setPrinter makes v rendered by the pretty-printer of its type, unless
+// the loader is in raw mode.
+func (l *loader) setPrinter(v *Variable) {
+	if l.cfg.Raw {
+		return
+	}
+	p := prettyPrinter(v.Type)
+	if p == nil {
+		return
+	}
+	thread := l.thread
+	mem := func(addr uint64, size int) ([]byte, error) {
+		return thread.readMemory(uintptr(addr), size)
+	}
+	v.printer = func() (string, error) {
+		return p(v, mem)
+	}
+}
+
+// decodeInt decodes the integer or pointer of type t in b, sign extended
+// if t is a signed integer.
+func decodeInt(b []byte, t dwarf.Type) (uint64, error) {
+	size := t.Size()
+	if int64(len(b)) < size {
+		return 0, fmt.Errorf("%d bytes too short for %s", len(b), t)
+	}
+	var n uint64
+	switch size {
+	case 1:
+		n = uint64(b[0])
+	case 2:
+		n = uint64(binary.LittleEndian.Uint16(b))
+	case 4:
+		n = uint64(binary.LittleEndian.Uint32(b))
+	case 8:
+		n = binary.LittleEndian.Uint64(b)
+	default:
+		return 0, fmt.Errorf("%s of size %d is not an integer", t, size)
+	}
+	if _, signed := resolveTypedef(t).(*dwarf.IntType); signed && size < 8 {
+		shift := uint(64 - 8*size)
+		n = uint64(int64(n<<shift) >> shift)
+	}
+	return n, nil
+}
+
+// readField reads the integer or pointer field found by path, as in
+// memberAt, of the value of type t at addr.
+func readField(mem MemoryReader, t dwarf.Type, addr uint64, path string) (uint64, error) {
+	addr, t, err := memberAt(t, addr, path)
+	if err != nil {
+		return 0, err
+	}
+	b, err := mem(addr, int(t.Size()))
+	if err != nil {
+		return 0, err
+	}
+	return decodeInt(b, t)
+}
+
+// sliceElem returns the type of the elements of the slice type t, and the
+// name of the field pointing to them. Strings are slices of bytes.
+func sliceElem(t dwarf.Type) (dwarf.Type, string, error) {
+	data := "array"
+	if st, ok := resolveTypedef(t).(*dwarf.StructType); ok && st.StructName == "string" {
+		data = "str"
+	}
+	_, dataType, err := memberAt(t, 0, data)
+	if err != nil {
+		return nil, "", err
+	}
+	ptr, ok := resolveTypedef(dataType).(*dwarf.PtrType)
+	if !ok {
+		return nil, "", fmt.Errorf("%s is not a slice", t)
+	}
+	return ptr.Type, data, nil
+}
+
+// sliceAt returns the address of the elements, the length and the type of
+// the elements of the slice of type t at addr.
+func sliceAt(mem MemoryReader, t dwarf.Type, addr uint64) (uint64, int64, dwarf.Type, error) {
+	elem, data, err := sliceElem(t)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	base, err := readField(mem, t, addr, data)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	n, err := readField(mem, t, addr, "len")
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return base, int64(n), elem, nil
+}
+
+// readSlice reads at most max elements of the slice of type t at addr,
+// starting from element off. It also returns the length of the slice.
+func readSlice(mem MemoryReader, t dwarf.Type, addr uint64, off, max int64) ([]byte, int64, error) {
+	base, length, elem, err := sliceAt(mem, t, addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	if off < 0 || off > length {
+		return nil, 0, fmt.Errorf("invalid slice offset %d", off)
+	}
+	count, size := length-off, elem.Size()
+	if count > max {
+		count = max
+	}
+	if count == 0 || size <= 0 {
+		return nil, length, nil
+	}
+	b, err := mem(base+uint64(off*size), int(count*size))
+	return b, length, err
+}
+
+// readSliceField is like readSlice for the slice field of the value of
+// type t at addr found by path, as in memberAt.
+func readSliceField(mem MemoryReader, t dwarf.Type, addr uint64, path string, off, max int64) ([]byte, int64, error) {
+	addr, t, err := memberAt(t, addr, path)
+	if err != nil {
+		return nil, 0, err
+	}
+	return readSlice(mem, t, addr, off, max)
+}
+
+// quoteBytes renders the first bytes b of n as a quoted string.
+func quoteBytes(b []byte, n int64) string {
+	s := strconv.Quote(string(b))
+	if int64(len(b)) < n {
+		s += fmt.Sprintf("...+%d more", n-int64(len(b)))
+	}
+	return s
+}
+
+// field returns the field of the struct v named name, nil if it isn't loaded.
+func field(v *Variable, name string) *Variable {
+	for _, f := range v.Children {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func printConstant(s string) PrettyPrinter {
+	return func(v *Variable, mem MemoryReader) (string, error) {
+		return s, nil
+	}
+}
+
+// Constants of the encoding of time.Time since Go 1.9, see time/time.go.
+const (
+	timeNsecMask       = 1<<30 - 1
+	timeNsecShift      = 30
+	timeHasMonotonic   = 1 << 63
+	timeWallToInternal = (1884*365 + 1884/4 - 1884/100 + 1884/400) * 86400
+	timeUnixToInternal = (1969*365 + 1969/4 - 1969/100 + 1969/400) * 86400
+)
+
+// printTime renders a time.Time as time.Time.String does, in its zone as
+// described by the time.Location of the process.
+func printTime(v *Variable, mem MemoryReader) (string, error) {
+	var sec, nsec int64
+	if _, _, err := memberAt(v.typ, v.Addr, "sec"); err == nil {
+		// Before Go 1.9: seconds since year 1 and nanoseconds.
+		s, err := readField(mem, v.typ, v.Addr, "sec")
+		if err != nil {
+			return "", err
+		}
+		ns, err := readField(mem, v.typ, v.Addr, "nsec")
+		if err != nil {
+			return "", err
+		}
+		sec, nsec = int64(s), int64(ns)
+	} else {
+		wall, err := readField(mem, v.typ, v.Addr, "wall")
+		if err != nil {
+			return "", err
+		}
+		ext, err := readField(mem, v.typ, v.Addr, "ext")
+		if err != nil {
+			return "", err
+		}
+		sec = int64(ext)
+		if wall&timeHasMonotonic != 0 {
+			sec = timeWallToInternal + int64(wall<<1>>(timeNsecShift+1))
+		}
+		nsec = int64(wall & timeNsecMask)
+	}
+	t := time.Unix(sec-timeUnixToInternal, nsec).UTC()
+
+	_, loct, err := memberAt(v.typ, v.Addr, "loc")
+	if err != nil {
+		return "", err
+	}
+	locp, err := readField(mem, v.typ, v.Addr, "loc")
+	if err != nil || locp == 0 {
+		return t.String(), err
+	}
+	ptr, ok := resolveTypedef(loct).(*dwarf.PtrType)
+	if !ok {
+		return "", fmt.Errorf("invalid location type %s", loct)
+	}
+	name, offset, err := lookupZone(mem, ptr.Type, locp, t.Unix())
+	if err != nil {
+		return "", err
+	}
+	return t.In(time.FixedZone(name, offset)).String(), nil
+}
+
+// Maximum number of zones and transitions of a time.Location read.
+const maxZones = 1 << 12
+
+// lookupZone returns the name and the offset from UTC of the zone in
+// effect at unix of the time.Location of type t at addr, as
+// time.Location.lookup does without the rule of the zones past its
+// transitions. Locations without zones are UTC.
+func lookupZone(mem MemoryReader, t dwarf.Type, addr uint64, unix int64) (string, int, error) {
+	zonesAddr, zonesType, err := memberAt(t, addr, "zone")
+	if err != nil {
+		return "", 0, err
+	}
+	zones, nzones, zoneType, err := sliceAt(mem, zonesType, zonesAddr)
+	if err != nil {
+		return "", 0, err
+	}
+	if nzones <= 0 {
+		return "UTC", 0, nil
+	}
+	if nzones > maxZones {
+		return "", 0, fmt.Errorf("too many zones %d", nzones)
+	}
+	zoneSize := zoneType.Size()
+
+	var zone int64
+	start, err := readField(mem, t, addr, "cacheStart")
+	if err != nil {
+		return "", 0, err
+	}
+	end, err := readField(mem, t, addr, "cacheEnd")
+	if err != nil {
+		return "", 0, err
+	}
+	cached, err := readField(mem, t, addr, "cacheZone")
+	if err != nil {
+		return "", 0, err
+	}
+	if cached >= zones && int64(start) <= unix && unix < int64(end) {
+		zone = int64(cached-zones) / zoneSize
+	} else {
+		// The zone of the last transition before unix.
+		tx, _, err := readSliceField(mem, t, addr, "tx", 0, maxZones)
+		if err != nil {
+			return "", 0, err
+		}
+		_, txType, err := memberAt(t, addr, "tx")
+		if err != nil {
+			return "", 0, err
+		}
+		transType, _, err := sliceElem(txType)
+		if err != nil {
+			return "", 0, err
+		}
+		whenOff, whenType, err := memberAt(transType, 0, "when")
+		if err != nil {
+			return "", 0, err
+		}
+		indexOff, indexType, err := memberAt(transType, 0, "index")
+		if err != nil {
+			return "", 0, err
+		}
+		for b := tx; int64(len(b)) >= transType.Size(); b = b[transType.Size():] {
+			when, err := decodeInt(b[whenOff:], whenType)
+			if err != nil {
+				return "", 0, err
+			}
+			if int64(when) > unix {
+				break
+			}
+			index, err := decodeInt(b[indexOff:], indexType)
+			if err != nil {
+				return "", 0, err
+			}
+			zone = int64(index)
+		}
+	}
+	if zone >= nzones {
+		return "", 0, fmt.Errorf("invalid zone %d", zone)
+	}
+
+	zoneAddr := zones + uint64(zone*zoneSize)
+	name, _, err := readSliceField(mem, zoneType, zoneAddr, "name", 0, maxStringLen)
+	if err != nil {
+		return "", 0, err
+	}
+	offset, err := readField(mem, zoneType, zoneAddr, "offset")
+	if err != nil {
+		return "", 0, err
+	}
+	return string(name), int(int64(offset)), nil
+}
+
+// printBigInt renders a math/big.Int in decimal.
+func printBigInt(v *Variable, mem MemoryReader) (string, error) {
+	neg, err := readField(mem, v.typ, v.Addr, "neg")
+	if err != nil {
+		return "", err
+	}
+	// abs is a slice of words, least significant first, so that its
+	// bytes are those of the number in little endian order.
+	b, n, err := readSliceField(mem, v.typ, v.Addr, "abs", 0, maxStringLen/8)
+	if err != nil {
+		return "", err
+	}
+	if n > maxStringLen/8 {
+		return "", fmt.Errorf("integer of %d words too large", n)
+	}
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	x := new(big.Int).SetBytes(b)
+	if neg != 0 {
+		x.Neg(x)
+	}
+	return x.String(), nil
+}
+
+// printIP renders a net.IP as net.IP.String does.
+func printIP(v *Variable, mem MemoryReader) (string, error) {
+	b, n, err := readSlice(mem, v.typ, v.Addr, 0, net.IPv6len)
+	if err != nil {
+		return "", err
+	}
+	if int64(len(b)) < n {
+		return "", fmt.Errorf("invalid IP length %d", n)
+	}
+	return net.IP(b).String(), nil
+}
+
+// Bits of the state of sync.Mutex, see sync/mutex.go.
+const (
+	mutexLocked      = 1
+	mutexWoken       = 2
+	mutexStarving    = 4
+	mutexWaiterShift = 3
+)
+
+// printMutex renders whether a sync.Mutex is locked and how many
+// goroutines are waiting for it.
+func printMutex(v *Variable, mem MemoryReader) (string, error) {
+	n, err := readField(mem, v.typ, v.Addr, "state")
+	if err != nil {
+		// Since Go 1.24 the state is in an internal/sync.Mutex.
+		if n, err = readField(mem, v.typ, v.Addr, "mu.state"); err != nil {
+			return "", err
+		}
+	}
+	state := uint32(n)
+	s := "unlocked"
+	if state&mutexLocked != 0 {
+		s = "locked"
+	}
+	if waiters := state >> mutexWaiterShift; waiters > 0 {
+		s += fmt.Sprintf(", %d waiters", waiters)
+	}
+	if state&mutexWoken != 0 {
+		s += ", woken"
+	}
+	if state&mutexStarving != 0 {
+		s += ", starving"
+	}
+	return s, nil
+}
+
+// printBuilder renders the contents of a strings.Builder.
+func printBuilder(v *Variable, mem MemoryReader) (string, error) {
+	b, n, err := readSliceField(mem, v.typ, v.Addr, "buf", 0, maxStringLen)
+	if err != nil {
+		return "", err
+	}
+	return quoteBytes(b, n), nil
+}
+
+// printBuffer renders the unread contents of a bytes.Buffer.
+func printBuffer(v *Variable, mem MemoryReader) (string, error) {
+	off, err := readField(mem, v.typ, v.Addr, "off")
+	if err != nil {
+		return "", err
+	}
+	b, n, err := readSliceField(mem, v.typ, v.Addr, "buf", int64(off), maxStringLen)
+	if err != nil {
+		return "", err
+	}
+	return quoteBytes(b, n-int64(off)), nil
+}
+
+var errNotLoaded = errors.New("fields not loaded")
+
+// contextString renders the context held by the interface iface.
+func contextString(iface *Variable) string {
+	if iface.isnil {
+		return "nil"
+	}
+	if len(iface.Children) == 0 {
+		return "..."
+	}
+	dyn := iface.Children[0]
+	v := dyn
+	if v.Kind == reflect.Ptr && len(v.Children) > 0 {
+		v = v.Children[0]
+	}
+	if v.printed {
+		return v.Value
+	}
+	return dyn.Name
+}
+
+// valueString renders the value held by the interface iface.
+func valueString(iface *Variable) string {
+	if iface.isnil {
+		return "nil"
+	}
+	if len(iface.Children) == 0 {
+		return "..."
+	}
+	v := iface.Children[0]
+	val := v.format(false)
+	if v.Kind == reflect.String {
+		val = `"` + val + `"`
+		if v.Name == "string" {
+			return val
+		}
+	}
+	return fmt.Sprintf("%s(%s)", v.Name, val)
+}
+
+// printCancelCtx renders a context made by context.WithCancel.
+func printCancelCtx(v *Variable, mem MemoryReader) (string, error) {
+	parent, cerr := field(v, "Context"), field(v, "err")
+	if parent == nil || cerr == nil {
+		return "", errNotLoaded
+	}
+	s := fmt.Sprintf("context.WithCancel(%s)", contextString(parent))
+	if !cerr.isnil {
+		s += " (canceled)"
+	}
+	return s, nil
+}
+
+// printTimerCtx renders a context made by context.WithDeadline or
+// context.WithTimeout.
+func printTimerCtx(v *Variable, mem MemoryReader) (string, error) {
+	cc, deadline := field(v, "cancelCtx"), field(v, "deadline")
+	if cc == nil || deadline == nil {
+		return "", errNotLoaded
+	}
+	parent, cerr := field(cc, "Context"), field(cc, "err")
+	if parent == nil || cerr == nil {
+		return "", errNotLoaded
+	}
+	s := fmt.Sprintf("context.WithDeadline(%s, %s)", contextString(parent), deadline.format(false))
+	if !cerr.isnil {
+		s += " (canceled)"
+	}
+	return s, nil
+}
+
+// printValueCtx renders a context made by context.WithValue.
+func printValueCtx(v *Variable, mem MemoryReader) (string, error) {
+	parent, key, val := field(v, "Context"), field(v, "key"), field(v, "val")
+	if parent == nil || key == nil || val == nil {
+		return "", errNotLoaded
+	}
+	return fmt.Sprintf("context.WithValue(%s, %s, %s)", contextString(parent), valueString(key), valueString(val)), nil
+}
+
+// printWithoutCancelCtx renders a context made by context.WithoutCancel.
+func printWithoutCancelCtx(v *Variable, mem MemoryReader) (string, error) {
+	parent := field(v, "c")
+	if parent == nil {
+		return "", errNotLoaded
+	}
+	return fmt.Sprintf("context.WithoutCancel(%s)", contextString(parent)), nil
+}
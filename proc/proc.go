@@ -16,6 +16,7 @@ import (
 
 	"github.com/derekparker/delve/dwarf/frame"
 	"github.com/derekparker/delve/dwarf/line"
+	"github.com/derekparker/delve/dwarf/loclist"
 	"github.com/derekparker/delve/dwarf/reader"
 	"github.com/derekparker/delve/source"
 )
@@ -40,10 +41,14 @@ type Process struct {
 	// Active thread. This is the default thread used for setting breakpoints, evaluating variables, etc..
 	CurrentThread *Thread
 
+	// Maximum number of entries read when evaluating a map.
+	MaxMapValues int
+
 	dwarf                   *dwarf.Data
 	goSymTable              *gosym.Table
 	frameEntries            frame.FrameDescriptionEntries
 	lineInfo                *line.DebugLineInfo
+	loclist                 *loclist.Reader
 	firstStart              bool
 	singleStepping          bool
 	os                      *OSProcessDetails
@@ -55,6 +60,17 @@ type Process struct {
 	exited                  bool
 	ptraceChan              chan func()
 	ptraceDoneChan          chan interface{}
+
+	// Runtime struct types looked up so far, by name.
+	structTypes map[string]*dwarf.StructType
+	// Types looked up by name so far.
+	types map[string]dwarf.Type
+	// Calls to inlined functions, by entry point of the function they
+	// are inlined in.
+	inlinedCalls map[uint64][]*InlinedCall
+	// Values of variables stored in registers, mapped in memory while
+	// the process is stopped.
+	compositeMemory []*compositeMemory
 }
 
 func New(pid int) *Process {
@@ -67,6 +83,9 @@ func New(pid int) *Process {
 		ast:            source.New(),
 		ptraceChan:     make(chan func()),
 		ptraceDoneChan: make(chan interface{}),
+		structTypes:    make(map[string]*dwarf.StructType),
+		types:          make(map[string]dwarf.Type),
+		MaxMapValues:   maxArrayValues,
 	}
 	go dbp.handlePtraceFuncs()
 	return dbp
@@ -141,7 +160,9 @@ func (dbp *Process) Running() bool {
 // to parse the following information:
 // * Dwarf .debug_frame section
 // * Dwarf .debug_line section
+// * Dwarf .debug_loc section
 // * Go symbol table.
+// * Dwarf inlined subroutines.
 func (dbp *Process) LoadInformation(path string) error {
 	var wg sync.WaitGroup
 
@@ -150,13 +171,15 @@ func (dbp *Process) LoadInformation(path string) error {
 		return err
 	}
 
-	wg.Add(3)
+	wg.Add(4)
 	go dbp.parseDebugFrame(exe, &wg)
 	go dbp.obtainGoSymbols(exe, &wg)
 	go dbp.parseDebugLineInfo(exe, &wg)
+	go dbp.parseDebugLoc(exe, &wg)
 	wg.Wait()
 
-	return nil
+	// Call sites of inlined functions refer to the files of the line table.
+	return dbp.loadInlinedCalls()
 }
 
 // Find a location by string (file+line, function, breakpoint id, addr)
@@ -195,7 +218,7 @@ func (dbp *Process) FindLocation(str string) (uint64, error) {
 	}
 
 	for _, bp := range dbp.Breakpoints {
-		if uint64(bp.ID) == id {
+		if uint64(bp.ID) == id && bp.CopyOf == nil {
 			return bp.Addr, nil
 		}
 	}
@@ -362,29 +385,47 @@ func (dbp *Process) setChanRecvBreakpoints() (int, error) {
 
 // Resume process.
 func (dbp *Process) Continue() error {
-	for _, thread := range dbp.Threads {
-		err := thread.Continue()
-		if err != nil {
-			return fmt.Errorf("could not continue thread %d %s", thread.Id, err)
+	for {
+		for _, thread := range dbp.Threads {
+			err := thread.Continue()
+			if err != nil {
+				return fmt.Errorf("could not continue thread %d %s", thread.Id, err)
+			}
+		}
+		var stopped bool
+		err := dbp.run(func() (err error) {
+			stopped, err = dbp.resume()
+			return err
+		})
+		if err != nil || stopped {
+			return err
 		}
 	}
-	return dbp.run(dbp.resume)
 }
 
-func (dbp *Process) resume() error {
+// resume waits for the process to stop. Returns false if it stopped at
+// a catchpoint whose filter does not match, in which case execution
+// must be resumed.
+func (dbp *Process) resume() (bool, error) {
 	thread, err := dbp.trapWait(-1)
 	if err != nil {
-		return err
+		return true, err
 	}
 	if dbp.CurrentThread != thread {
 		dbp.SwitchThread(thread.Id)
 	}
+	if bp := thread.CurrentBreakpoint; bp != nil && bp.Catch != NoCatch {
+		if err := dbp.Halt(); err != nil {
+			return true, err
+		}
+		return dbp.handleCatchpoint(thread, bp)
+	}
 	pc, err := thread.PC()
 	if err != nil {
-		return err
+		return true, err
 	}
 	if dbp.CurrentBreakpoint != nil || dbp.halt {
-		return dbp.Halt()
+		return true, dbp.Halt()
 	}
 	// Check to see if we hit a runtime.breakpoint
 	fn := dbp.goSymTable.PCToFunc(pc)
@@ -392,13 +433,13 @@ func (dbp *Process) resume() error {
 		// step twice to get back to user code
 		for i := 0; i < 2; i++ {
 			if err = thread.Step(); err != nil {
-				return err
+				return true, err
 			}
 		}
-		return dbp.Halt()
+		return true, dbp.Halt()
 	}
 
-	return fmt.Errorf("unrecognized breakpoint %#v", pc)
+	return true, fmt.Errorf("unrecognized breakpoint %#v", pc)
 }
 
 // Single step, will execute a single instruction.
@@ -647,8 +688,10 @@ func (dbp *Process) run(fn func() error) error {
 		return fmt.Errorf("process has already exited")
 	}
 	dbp.halt = false
+	dbp.compositeMemory = nil
 	for _, th := range dbp.Threads {
 		th.CurrentBreakpoint = nil
+		th.CaughtGoroutine = nil
 	}
 	if err := fn(); err != nil {
 		if _, ok := err.(ManualStopError); !ok {
@@ -16,6 +16,7 @@ import (
 
 	"github.com/derekparker/delve/dwarf/frame"
 	"github.com/derekparker/delve/dwarf/line"
+	"github.com/derekparker/delve/dwarf/loclist"
 	sys "golang.org/x/sys/unix"
 )
 
@@ -158,6 +159,16 @@ func (dbp *Process) parseDebugFrame(exe *macho.File, wg *sync.WaitGroup) {
 		fmt.Println("could not find __debug_frame section in binary")
 		os.Exit(1)
 	}
+
+	// C code linked in by cgo is only described by __eh_frame.
+	if sec := exe.Section("__eh_frame"); sec != nil {
+		ehFrame, err := sec.Data()
+		if err != nil {
+			fmt.Println("could not get __eh_frame section", err)
+			os.Exit(1)
+		}
+		dbp.frameEntries = dbp.frameEntries.Merge(frame.ParseEhFrame(ehFrame, sec.Addr))
+	}
 }
 
 func (dbp *Process) obtainGoSymbols(exe *macho.File, wg *sync.WaitGroup) {
@@ -211,6 +222,24 @@ func (dbp *Process) parseDebugLineInfo(exe *macho.File, wg *sync.WaitGroup) {
 	}
 }
 
+func (dbp *Process) parseDebugLoc(exe *macho.File, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	// Only optimized builds have location lists.
+	if sec := exe.Section("__debug_loc"); sec != nil {
+		debugLoc, err := sec.Data()
+		if err != nil {
+			fmt.Println("could not get __debug_loc section", err)
+			os.Exit(1)
+		}
+		ptrSize := 8
+		if exe.Magic == macho.Magic32 {
+			ptrSize = 4
+		}
+		dbp.loclist = loclist.New(debugLoc, ptrSize)
+	}
+}
+
 func (dbp *Process) findExecutable(path string) (*macho.File, error) {
 	if path == "" {
 		path = C.GoString(C.find_executable(C.int(dbp.Pid)))
@@ -283,3 +312,8 @@ func wait(pid, tgid, options int) (int, *sys.WaitStatus, error) {
 	wpid, err := sys.Wait4(pid, &status, options, nil)
 	return wpid, &status, err
 }
+
+// nanotime returns the time of the clock the runtime reads in nanotime.
+func nanotime() (int64, error) {
+	return 0, fmt.Errorf("not implemented on darwin")
+}
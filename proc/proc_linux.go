@@ -11,11 +11,13 @@ import (
 	"sync"
 	"syscall"
 	"time"
+	"unsafe"
 
 	sys "golang.org/x/sys/unix"
 
 	"github.com/derekparker/delve/dwarf/frame"
 	"github.com/derekparker/delve/dwarf/line"
+	"github.com/derekparker/delve/dwarf/loclist"
 )
 
 const (
@@ -170,6 +172,16 @@ func (dbp *Process) parseDebugFrame(exe *elf.File, wg *sync.WaitGroup) {
 		fmt.Println("could not find .debug_frame section in binary")
 		os.Exit(1)
 	}
+
+	// C code linked in by cgo is only described by .eh_frame.
+	if sec := exe.Section(".eh_frame"); sec != nil {
+		ehFrame, err := sec.Data()
+		if err != nil {
+			fmt.Println("could not get .eh_frame section", err)
+			os.Exit(1)
+		}
+		dbp.frameEntries = dbp.frameEntries.Merge(frame.ParseEhFrame(ehFrame, sec.Addr))
+	}
 }
 
 func (dbp *Process) obtainGoSymbols(exe *elf.File, wg *sync.WaitGroup) {
@@ -197,7 +209,7 @@ func (dbp *Process) obtainGoSymbols(exe *elf.File, wg *sync.WaitGroup) {
 		}
 	}
 
-	pcln := gosym.NewLineTable(pclndat, exe.Section(".text").Addr)
+	pcln := gosym.NewLineTable(pclndat, textStart(exe))
 	tab, err := gosym.NewTable(symdat, pcln)
 	if err != nil {
 		fmt.Println("could not get initialize line table", err)
@@ -207,6 +219,19 @@ func (dbp *Process) obtainGoSymbols(exe *elf.File, wg *sync.WaitGroup) {
 	dbp.goSymTable = tab
 }
 
+// textStart returns the address of the first Go function. When the
+// binary is linked by the external linker .text starts with C code.
+func textStart(exe *elf.File) uint64 {
+	if syms, err := exe.Symbols(); err == nil {
+		for _, sym := range syms {
+			if sym.Name == "runtime.text" {
+				return sym.Value
+			}
+		}
+	}
+	return exe.Section(".text").Addr
+}
+
 func (dbp *Process) parseDebugLineInfo(exe *elf.File, wg *sync.WaitGroup) {
 	defer wg.Done()
 
@@ -223,6 +248,24 @@ func (dbp *Process) parseDebugLineInfo(exe *elf.File, wg *sync.WaitGroup) {
 	}
 }
 
+func (dbp *Process) parseDebugLoc(exe *elf.File, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	// Only optimized builds have location lists.
+	if sec := exe.Section(".debug_loc"); sec != nil {
+		debugLoc, err := sec.Data()
+		if err != nil {
+			fmt.Println("could not get .debug_loc section", err)
+			os.Exit(1)
+		}
+		ptrSize := 8
+		if exe.Class == elf.ELFCLASS32 {
+			ptrSize = 4
+		}
+		dbp.loclist = loclist.New(debugLoc, ptrSize)
+	}
+}
+
 func (dbp *Process) trapWait(pid int) (*Thread, error) {
 	for {
 		wpid, status, err := wait(pid, dbp.Pid, 0)
@@ -291,6 +334,15 @@ func (dbp *Process) trapWait(pid int) (*Thread, error) {
 		}
 		if th != nil {
 			// TODO(dp) alert user about unexpected signals here.
+			if sig := status.StopSignal(); sig != sys.SIGSTOP {
+				// Deliver the signal when the thread resumes, the
+				// process may be waiting for it.
+				th.os.pendingSig = sig
+				if !th.running {
+					// A halted thread reporting a stop late stays halted.
+					continue
+				}
+			}
 			if err := th.Continue(); err != nil {
 				return nil, err
 			}
@@ -354,3 +406,16 @@ func wait(pid, tgid, options int) (int, *sys.WaitStatus, error) {
 		}
 	}
 }
+
+// nanotime returns the time of the monotonic clock the runtime reads in
+// nanotime, to which the times it records are relative.
+func nanotime() (int64, error) {
+	var ts sys.Timespec
+	if _, _, errno := sys.Syscall(sys.SYS_CLOCK_GETTIME, clockMonotonic, uintptr(unsafe.Pointer(&ts)), 0); errno != 0 {
+		return 0, errno
+	}
+	return sys.TimespecToNsec(ts), nil
+}
+
+// CLOCK_MONOTONIC, see clock_gettime(2).
+const clockMonotonic = 1
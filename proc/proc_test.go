@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"testing"
 	"time"
@@ -471,6 +472,75 @@ func TestStacktrace(t *testing.T) {
 	})
 }
 
+func TestStackframes(t *testing.T) {
+	stacks := [][]loc{
+		[]loc{{4, "main.stacktraceme"}, {8, "main.func1"}, {16, "main.main"}},
+		[]loc{{4, "main.stacktraceme"}, {8, "main.func1"}, {12, "main.func2"}, {17, "main.main"}},
+	}
+	withTestProcess("stacktraceprog", t, func(p *Process, fixture protest.Fixture) {
+		_, err := p.SetBreakpointByLocation("main.stacktraceme")
+		assertNoError(err, t, "BreakByLocation()")
+
+		for i := range stacks {
+			assertNoError(p.Continue(), t, "Continue()")
+			frames, err := p.CurrentThread.Stackframes(40)
+			assertNoError(err, t, "Stackframes()")
+
+			if len(frames) < len(stacks[i]) {
+				t.Fatalf("Wrong stack trace size %d %d\n", len(frames), len(stacks[i]))
+			}
+			for j := range stacks[i] {
+				if !stacks[i][j].match(frames[j].Call) {
+					t.Fatalf("Wrong stack trace pos %d: %s:%d", j, frames[j].Call.File, frames[j].Call.Line)
+				}
+				if j > 0 && frames[j].CFA <= frames[j-1].CFA {
+					t.Fatalf("CFA of frame %d (%#x) not above the CFA of its callee (%#x)", j, frames[j].CFA, frames[j-1].CFA)
+				}
+			}
+			if i == 1 {
+				args, err := frames[2].Scope(p.CurrentThread).FunctionArguments()
+				assertNoError(err, t, "FunctionArguments()")
+				if len(args) != 1 || args[0].Name != "f" {
+					t.Fatalf("Wrong arguments for main.func2: %#v", args)
+				}
+			}
+		}
+	})
+}
+
+func TestFramePointerUnwinding(t *testing.T) {
+	withTestProcess("stacktraceprog", t, func(p *Process, fixture protest.Fixture) {
+		// Stop in the body of main.func1, past the prologue setting up its frame pointer.
+		_, err := p.SetBreakpointByLocation(fixture.Source + ":8")
+		assertNoError(err, t, "SetBreakpointByLocation()")
+		assertNoError(p.Continue(), t, "Continue()")
+
+		regs, err := p.CurrentThread.Registers()
+		assertNoError(err, t, "Registers()")
+		frames, err := p.stackframes(p.CurrentThread, regs.DwarfRegisters(), 1)
+		assertNoError(err, t, "stackframes()")
+		if len(frames) != 2 {
+			t.Fatalf("Wrong number of frames %d", len(frames))
+		}
+
+		cfa, callers, err := p.unwindFramePointer(regs.DwarfRegisters())
+		assertNoError(err, t, "unwindFramePointer()")
+		if cfa != frames[0].cfa {
+			t.Fatalf("CFA %#x from the frame pointer, %#x from the frame description", cfa, frames[0].cfa)
+		}
+		if callers.PC() != frames[1].pc {
+			t.Fatalf("Return address %#x from the frame pointer, %#x from the frame description", callers.PC(), frames[1].pc)
+		}
+
+		// Go functions don't preserve registers for their callers.
+		for _, n := range []uint64{amd64DwarfRBX, amd64DwarfR12, amd64DwarfR15} {
+			if _, ok := frames[1].regs.Reg(n); ok {
+				t.Fatalf("Register %d known in the caller", n)
+			}
+		}
+	})
+}
+
 func stackMatch(stack []loc, locations []Location) bool {
 	if len(stack) > len(locations) {
 		return false
@@ -535,3 +605,345 @@ func TestStacktraceGoroutine(t *testing.T) {
 		p.Continue()
 	})
 }
+
+func TestCatchGoroutineCreate(t *testing.T) {
+	withTestProcess("goroutinestackprog", t, func(p *Process, fixture protest.Fixture) {
+		filter := regexp.MustCompile(`^main\.main$`)
+		bp, err := p.SetCatchpoint(CatchGoroutineCreate, filter)
+		assertNoError(err, t, "SetCatchpoint()")
+
+		ids := map[int]bool{}
+		for i := 0; i < 3; i++ {
+			assertNoError(p.Continue(), t, "Continue()")
+			if p.CurrentBreakpoint() != bp {
+				t.Fatalf("Did not stop at catchpoint")
+			}
+			g := p.CurrentThread.CaughtGoroutine
+			if g == nil {
+				t.Fatal("No goroutine caught")
+			}
+			if g.Func == nil || g.Func.Name != "main.main" {
+				t.Fatalf("Wrong creation site for goroutine %d: %#v", g.Id, g.Func)
+			}
+			if ids[g.Id] {
+				t.Fatalf("Goroutine %d caught twice", g.Id)
+			}
+			ids[g.Id] = true
+		}
+	})
+}
+
+func TestCatchGoroutineExitFiltered(t *testing.T) {
+	// Hundreds of goroutines exit without matching the filter before the
+	// one started by main.main does.
+	withTestProcess("catchfilterprog", t, func(p *Process, fixture protest.Fixture) {
+		filter := regexp.MustCompile(`^main\.main$`)
+		bp, err := p.SetCatchpoint(CatchGoroutineExit, filter)
+		assertNoError(err, t, "SetCatchpoint()")
+
+		assertNoError(p.Continue(), t, "Continue()")
+		if p.CurrentBreakpoint() != bp {
+			t.Fatalf("Did not stop at catchpoint")
+		}
+		g := p.CurrentThread.CaughtGoroutine
+		if g == nil {
+			t.Fatal("No goroutine caught")
+		}
+		if g.Func == nil || g.Func.Name != "main.main" {
+			t.Fatalf("Wrong creation site for goroutine %d: %#v", g.Id, g.Func)
+		}
+	})
+}
+
+func TestWaitGraph(t *testing.T) {
+	withTestProcess("deadlockprog", t, func(p *Process, fixture protest.Fixture) {
+		_, err := p.SetBreakpointByLocation("main.stopped")
+		assertNoError(err, t, "SetBreakpointByLocation()")
+		assertNoError(p.Continue(), t, "Continue()")
+
+		graph, err := p.WaitGraph()
+		assertNoError(err, t, "WaitGraph()")
+
+		var (
+			workers []int
+			waiters []*BlockedG
+		)
+		for _, bg := range graph.Blocked {
+			if bg.Location == nil || bg.Location.Fn == nil {
+				continue
+			}
+			if bg.Location.Fn.Name == "main.waiter" {
+				waiters = append(waiters, bg)
+				continue
+			}
+			if bg.Location.Fn.Name != "main.worker" {
+				continue
+			}
+			if bg.ObjectKind != "chan" || bg.ObjectAddr == 0 {
+				t.Fatalf("Goroutine %d not blocked on a channel: %q %#x", bg.Id, bg.ObjectKind, bg.ObjectAddr)
+			}
+			workers = append(workers, bg.Id)
+		}
+		if len(workers) != 2 {
+			t.Fatalf("Wrong number of blocked workers: %v", workers)
+		}
+		// Goroutines receiving from the same channel wait on the same object.
+		if len(waiters) != 2 || waiters[0].ObjectKind != "chan" || waiters[0].ObjectAddr == 0 || waiters[0].ObjectAddr != waiters[1].ObjectAddr {
+			t.Fatalf("Waiters not blocked on the same channel: %#v", waiters)
+		}
+		if len(graph.Cycles) != 1 || len(graph.Cycles[0]) != 2 {
+			t.Fatalf("Wrong cycles: %v", graph.Cycles)
+		}
+		for _, id := range workers {
+			if id != graph.Cycles[0][0] && id != graph.Cycles[0][1] {
+				t.Fatalf("Goroutine %d not part of the cycle %v", id, graph.Cycles[0])
+			}
+		}
+	})
+}
+
+func TestGoroutineState(t *testing.T) {
+	withTestProcess("goroutinestackprog", t, func(p *Process, fixture protest.Fixture) {
+		_, err := p.SetBreakpointByLocation("main.stacktraceme")
+		assertNoError(err, t, "SetBreakpointByLocation()")
+		assertNoError(p.Continue(), t, "Continue()")
+
+		gs, err := p.GoroutinesInfo()
+		assertNoError(err, t, "GoroutinesInfo")
+
+		running := 0
+		for _, g := range gs {
+			if g.Status == Gdead {
+				continue
+			}
+			if g.StackLo >= g.StackHi {
+				t.Fatalf("Goroutine %d has invalid stack bounds [%#x, %#x)", g.Id, g.StackLo, g.StackHi)
+			}
+			switch g.StatusString() {
+			case "running":
+				running++
+				if g.ThreadID == 0 {
+					t.Fatalf("Running goroutine %d has no thread", g.Id)
+				}
+			case "waiting":
+				if g.SP < g.StackLo || g.SP >= g.StackHi {
+					t.Fatalf("Goroutine %d SP %#x outside of its stack [%#x, %#x)", g.Id, g.SP, g.StackLo, g.StackHi)
+				}
+			}
+		}
+		if running == 0 {
+			t.Fatal("No running goroutine")
+		}
+	})
+}
+
+func TestInlinedStackframes(t *testing.T) {
+	fixture := protest.BuildInlinedFixture("testinline")
+	p, err := Launch([]string{fixture.Path})
+	if err != nil {
+		t.Fatal("Launch():", err)
+	}
+	defer func() {
+		p.Halt()
+		p.Detach(true)
+	}()
+
+	entries := p.InlinedEntries("main.inlineThis")
+	if len(entries) == 0 {
+		t.Fatal("main.inlineThis was not inlined")
+	}
+	_, err = p.SetBreakpoint(entries[0])
+	assertNoError(err, t, "SetBreakpoint()")
+	assertNoError(p.Continue(), t, "Continue()")
+
+	frames, err := p.CurrentThread.Stackframes(2)
+	assertNoError(err, t, "Stackframes()")
+	if len(frames) < 2 {
+		t.Fatalf("Wrong stack trace size %d", len(frames))
+	}
+	if !frames[0].Inlined || frames[0].Call.Fn == nil || frames[0].Call.Fn.Name != "main.inlineThis" {
+		t.Fatalf("Wrong innermost frame: %#v", frames[0])
+	}
+	caller := loc{15, "main.main"}
+	if frames[1].Inlined || !caller.match(frames[1].Call) {
+		t.Fatalf("Wrong caller frame: %s:%d", frames[1].Call.File, frames[1].Call.Line)
+	}
+	if frames[0].CFA != frames[1].CFA {
+		t.Fatalf("Inlined frame CFA %#x differs from its caller's %#x", frames[0].CFA, frames[1].CFA)
+	}
+}
+
+func TestInlinedBreakpointCopies(t *testing.T) {
+	fixture := protest.BuildInlinedFixture("testinline")
+	p, err := Launch([]string{fixture.Path})
+	if err != nil {
+		t.Fatal("Launch():", err)
+	}
+	defer func() {
+		p.Halt()
+		p.Detach(true)
+	}()
+
+	entries := p.InlinedEntries("main.inlineThis")
+	if len(entries) < 2 {
+		t.Fatalf("main.inlineThis not inlined twice: %#v", entries)
+	}
+	for _, entry := range entries {
+		if calls := p.InlinedCallsAt(entry); len(calls) == 0 || calls[0].Name != "main.inlineThis" {
+			t.Fatalf("No inlined call at %#x", entry)
+		}
+	}
+
+	bp, err := p.SetBreakpoint(entries[0])
+	assertNoError(err, t, "SetBreakpoint()")
+	for _, entry := range entries[1:] {
+		c, err := p.SetBreakpointCopy(bp, entry)
+		assertNoError(err, t, "SetBreakpointCopy()")
+		if c.ID != bp.ID {
+			t.Fatalf("Copy has ID %d, breakpoint %d", c.ID, bp.ID)
+		}
+	}
+
+	// Clearing a copy clears the whole breakpoint.
+	cleared, err := p.ClearBreakpoint(entries[1])
+	assertNoError(err, t, "ClearBreakpoint()")
+	if cleared != bp {
+		t.Fatalf("Cleared %v instead of %v", cleared, bp)
+	}
+	for _, entry := range entries {
+		if _, ok := p.Breakpoints[entry]; ok {
+			t.Fatalf("Breakpoint left at %#x", entry)
+		}
+	}
+}
+
+func TestRuntimeInfo(t *testing.T) {
+	withTestProcess("testnextprog", t, func(p *Process, fixture protest.Fixture) {
+		pc, err := p.FindLocation("main.helloworld")
+		assertNoError(err, t, "FindLocation()")
+		_, err = p.SetBreakpoint(pc)
+		assertNoError(err, t, "SetBreakpoint()")
+		assertNoError(p.Continue(), t, "Continue()")
+
+		info, err := p.RuntimeInfo()
+		assertNoError(err, t, "RuntimeInfo()")
+		if info.GOMAXPROCS <= 0 || len(info.Ps) != info.GOMAXPROCS {
+			t.Fatalf("expected GOMAXPROCS Ps, got %d for GOMAXPROCS %d", len(info.Ps), info.GOMAXPROCS)
+		}
+		var m *M
+		for _, im := range info.Ms {
+			if im.ThreadID == p.CurrentThread.Id {
+				m = im
+			}
+		}
+		if m == nil || m.CurG <= 0 || m.P < 0 {
+			t.Fatalf("no M running a goroutine on thread %d with a P: %#v", p.CurrentThread.Id, m)
+		}
+		for _, ip := range info.Ps {
+			if ip.ID == m.P && (ip.Status != "running" || ip.M != m.ID) {
+				t.Fatalf("P%d of M%d is %s on M%d", ip.ID, m.ID, ip.Status, ip.M)
+			}
+		}
+		// A GC cycle may be running at any time.
+		known := false
+		for _, phase := range gcPhases {
+			known = known || info.GCPhase == phase
+		}
+		if !known {
+			t.Fatalf("unexpected GC phase %s", info.GCPhase)
+		}
+		if len(info.MemStats) == 0 {
+			t.Fatal("no memory statistics")
+		}
+	})
+}
+
+// stackFunctions returns the names of the functions of the stack of the
+// current thread, innermost first.
+func stackFunctions(p *Process, t *testing.T) []string {
+	frames, err := p.CurrentThread.Stackframes(50)
+	assertNoError(err, t, "Stackframes()")
+	var names []string
+	for _, frame := range frames {
+		name := "?"
+		if frame.Current.Fn != nil {
+			name = frame.Current.Fn.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func hasFunction(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStacktraceSystemStack(t *testing.T) {
+	// runtime.readmemstats_m runs on the system stack of the thread, its
+	// callers on the stack of the goroutine.
+	withTestProcess("systemstackprog", t, func(p *Process, fixture protest.Fixture) {
+		_, err := p.SetBreakpointByLocation("runtime.readmemstats_m")
+		assertNoError(err, t, "SetBreakpointByLocation()")
+		assertNoError(p.Continue(), t, "Continue()")
+
+		names := stackFunctions(p, t)
+		if !hasFunction(names, "runtime.systemstack") || !hasFunction(names, "main.main") {
+			t.Fatalf("Stack not unwound across the stack switch: %v", names)
+		}
+	})
+}
+
+func TestStacktraceSignalHandler(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the fixture sends a signal to its own thread with tgkill")
+	}
+	withTestProcess("sigprog", t, func(p *Process, fixture protest.Fixture) {
+		_, err := p.SetBreakpointByLocation("runtime.sighandler")
+		assertNoError(err, t, "SetBreakpointByLocation()")
+
+		// The runtime may handle signals of its own before the fixture
+		// sends one.
+		var names []string
+		for i := 0; i < 10; i++ {
+			assertNoError(p.Continue(), t, "Continue()")
+			names = stackFunctions(p, t)
+			if hasFunction(names, "main.main") {
+				break
+			}
+		}
+		if !hasFunction(names, "runtime.sigtramp") || !hasFunction(names, "main.main") {
+			t.Fatalf("Stack not unwound across the signal frame: %v", names)
+		}
+	})
+}
+
+func TestStacktraceCgo(t *testing.T) {
+	withTestProcess("cgoprog", t, func(p *Process, fixture protest.Fixture) {
+		_, err := p.SetBreakpointByLocation("main.stopped")
+		assertNoError(err, t, "SetBreakpointByLocation()")
+		assertNoError(p.Continue(), t, "Continue()")
+
+		// A thread is running the C function called by main.spinner, which
+		// is only described by .eh_frame. Its caller runs on the stack of
+		// the goroutine.
+		for _, th := range p.Threads {
+			pc, err := th.PC()
+			assertNoError(err, t, "PC()")
+			if _, _, fn := p.PCToLine(pc); fn != nil {
+				continue
+			}
+			assertNoError(p.SwitchThread(th.Id), t, "SwitchThread()")
+			names := stackFunctions(p, t)
+			if !hasFunction(names, "main.spinner") {
+				t.Fatalf("Stack not unwound from C code: %v", names)
+			}
+			return
+		}
+		t.Fatal("No thread running C code")
+	})
+}
@@ -12,6 +12,70 @@ type Registers interface {
 	CX() uint64
 	SetPC(*Thread, uint64) error
 	String() string
+	DwarfRegisters() *DwarfRegisters
+}
+
+// DWARF numbers of the amd64 registers, as defined by the System V ABI.
+const (
+	amd64DwarfRAX = iota
+	amd64DwarfRDX
+	amd64DwarfRCX
+	amd64DwarfRBX
+	amd64DwarfRSI
+	amd64DwarfRDI
+	amd64DwarfRBP
+	amd64DwarfRSP
+	amd64DwarfR8
+	amd64DwarfR9
+	amd64DwarfR10
+	amd64DwarfR11
+	amd64DwarfR12
+	amd64DwarfR13
+	amd64DwarfR14
+	amd64DwarfR15
+	amd64DwarfRIP // Return address column.
+
+	amd64DwarfRegs
+)
+
+// DwarfRegisters is a set of registers indexed by DWARF register number.
+// While unwinding the stack not every register can be recovered for the
+// callers, so each register is either known or not.
+type DwarfRegisters struct {
+	regs  [amd64DwarfRegs]uint64
+	known [amd64DwarfRegs]bool
+}
+
+// Reg returns the value of register n and whether it is known.
+func (r *DwarfRegisters) Reg(n uint64) (uint64, bool) {
+	if n >= amd64DwarfRegs {
+		return 0, false
+	}
+	return r.regs[n], r.known[n]
+}
+
+// SetReg sets the value of register n, ignoring registers delve doesn't track.
+func (r *DwarfRegisters) SetReg(n, val uint64) {
+	if n >= amd64DwarfRegs {
+		return
+	}
+	r.regs[n] = val
+	r.known[n] = true
+}
+
+// PC returns the program counter.
+func (r *DwarfRegisters) PC() uint64 {
+	return r.regs[amd64DwarfRIP]
+}
+
+// SP returns the stack pointer.
+func (r *DwarfRegisters) SP() uint64 {
+	return r.regs[amd64DwarfRSP]
+}
+
+// BP returns the frame pointer and whether it is known.
+func (r *DwarfRegisters) BP() (uint64, bool) {
+	return r.Reg(amd64DwarfRBP)
 }
 
 // Obtains register values from the debugged process.
@@ -77,6 +77,20 @@ func (r *Regs) CX() uint64 {
 	return r.rcx
 }
 
+func (r *Regs) DwarfRegisters() *DwarfRegisters {
+	dregs := &DwarfRegisters{}
+	for n, val := range []uint64{
+		r.rax, r.rdx, r.rcx, r.rbx,
+		r.rsi, r.rdi, r.rbp, r.rsp,
+		r.r8, r.r9, r.r10, r.r11,
+		r.r12, r.r13, r.r14, r.r15,
+		r.rip,
+	} {
+		dregs.SetReg(uint64(n), val)
+	}
+	return dregs
+}
+
 func (r *Regs) SetPC(thread *Thread, pc uint64) error {
 	kret := C.set_pc(thread.os.thread_act, C.uint64_t(pc))
 	if kret != C.KERN_SUCCESS {
@@ -60,6 +60,20 @@ func (r *Regs) CX() uint64 {
 	return r.regs.Rcx
 }
 
+func (r *Regs) DwarfRegisters() *DwarfRegisters {
+	dregs := &DwarfRegisters{}
+	for n, val := range []uint64{
+		r.regs.Rax, r.regs.Rdx, r.regs.Rcx, r.regs.Rbx,
+		r.regs.Rsi, r.regs.Rdi, r.regs.Rbp, r.regs.Rsp,
+		r.regs.R8, r.regs.R9, r.regs.R10, r.regs.R11,
+		r.regs.R12, r.regs.R13, r.regs.R14, r.regs.R15,
+		r.regs.PC(),
+	} {
+		dregs.SetReg(uint64(n), val)
+	}
+	return dregs
+}
+
 func (r *Regs) SetPC(thread *Thread, pc uint64) (err error) {
 	r.regs.SetPC(pc)
 	thread.dbp.execPtraceFunc(func() { err = sys.PtraceSetRegs(thread.Id, r.regs) })
@@ -0,0 +1,374 @@
+package proc
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"strings"
+
+	"github.com/derekparker/delve/dwarf/op"
+)
+
+// RuntimeInfo is the state of the scheduler, the garbage collector and the
+// memory allocator of the runtime of the process.
+type RuntimeInfo struct {
+	GOMAXPROCS int
+	Ps         []*P
+	Ms         []*M
+
+	GlobalRunqLen int // Goroutines in the global run queue.
+	IdlePs        int
+	IdleMs        int
+	SpinningMs    int
+
+	GCPhase string // One of off, mark or mark termination.
+	GCCycle uint64 // Number of the current or last GC cycle.
+
+	// Statistics of the memory allocator, named after the members of
+	// runtime.memstats and runtime.gcController they are read from.
+	MemStats []MemStat
+}
+
+// MemStat is an integer member of the memory statistics of the runtime.
+type MemStat struct {
+	Name  string
+	Value uint64
+}
+
+// Values of the status of a P, see runtime/runtime2.go.
+var pStatuses = []string{"idle", "running", "syscall", "gcstop", "dead"}
+
+// Values of runtime.gcphase since Go 1.6, see runtime/mgc.go.
+var gcPhases = []string{"off", "mark", "mark termination"}
+
+// Members of runtime.memstats and runtime.gcController reported in
+// MemStats, when the runtime of the process has them.
+var (
+	memstatsMembers = []string{
+		"alloc", "total_alloc", "sys", "nmalloc", "nfree",
+		"heap_alloc", "heap_sys", "heap_idle", "heap_inuse", "heap_released", "heap_objects",
+		"stacks_inuse", "stacks_sys", "next_gc", "last_gc", "last_gc_unix",
+		"pause_total_ns", "numgc", "numforcedgc",
+	}
+	gcControllerMembers = []string{
+		"heapLive", "heapMarked", "heapInUse", "heapFree", "heapReleased",
+		"totalAlloc", "totalFree", "lastHeapGoal",
+	}
+)
+
+// Maximum number of Ms followed in runtime.allm, in case it's corrupted.
+const maxMs = 1 << 16
+
+// RuntimeInfo reads the state of the scheduler, garbage collector and
+// memory allocator from the variables of the runtime. Members the runtime
+// of the process doesn't have are left out.
+func (dbp *Process) RuntimeInfo() (*RuntimeInfo, error) {
+	thread := dbp.CurrentThread
+	info := &RuntimeInfo{}
+
+	gomaxprocs, err := dbp.readRuntimeInt("runtime.gomaxprocs")
+	if err != nil {
+		return nil, err
+	}
+	info.GOMAXPROCS = int(gomaxprocs)
+
+	gtyp, err := dbp.structTypeNamed("runtime.g")
+	if err != nil {
+		return nil, err
+	}
+	mtyp, err := dbp.structTypeNamed("runtime.m")
+	if err != nil {
+		return nil, err
+	}
+	ptyp, err := dbp.structTypeNamed("runtime.p")
+	if err != nil {
+		return nil, err
+	}
+
+	// IDs of the Ms and Ps, which reference each other.
+	mID := func(maddr uint64) (int, error) {
+		if maddr == 0 {
+			return -1, nil
+		}
+		id, _, err := thread.readMember(mtyp, maddr, "id")
+		return int(int64(id)), err
+	}
+	pID := func(paddr uint64) (int, error) {
+		if paddr == 0 {
+			return -1, nil
+		}
+		id, _, err := thread.readMember(ptyp, paddr, "id")
+		return int(int32(id)), err
+	}
+
+	paddrs, err := dbp.allp(info.GOMAXPROCS)
+	if err != nil {
+		return nil, err
+	}
+	for _, paddr := range paddrs {
+		p := &P{}
+		if p.ID, err = pID(paddr); err != nil {
+			return nil, err
+		}
+		status, _, err := thread.readMember(ptyp, paddr, "status")
+		if err != nil {
+			return nil, err
+		}
+		p.Status = fmt.Sprintf("unknown(%d)", status)
+		if status < uint64(len(pStatuses)) {
+			p.Status = pStatuses[status]
+		}
+		maddr, _, err := thread.readMember(ptyp, paddr, "m")
+		if err != nil {
+			return nil, err
+		}
+		if p.M, err = mID(maddr); err != nil {
+			return nil, err
+		}
+		head, _, err := thread.readMember(ptyp, paddr, "runqhead")
+		if err != nil {
+			return nil, err
+		}
+		tail, _, err := thread.readMember(ptyp, paddr, "runqtail")
+		if err != nil {
+			return nil, err
+		}
+		p.RunqLen = int(uint32(tail - head))
+		if runnext, ok, err := thread.readMember(ptyp, paddr, "runnext"); err != nil {
+			return nil, err
+		} else if ok && runnext != 0 {
+			p.RunqLen++
+		}
+		info.Ps = append(info.Ps, p)
+	}
+
+	maddr, err := dbp.readRuntimeInt("runtime.allm")
+	if err != nil {
+		return nil, err
+	}
+	for ; maddr != 0 && len(info.Ms) < maxMs; maddr, _, err = thread.readMember(mtyp, maddr, "alllink") {
+		if err != nil {
+			return nil, err
+		}
+		m := &M{}
+		if m.ID, err = mID(maddr); err != nil {
+			return nil, err
+		}
+		procid, _, err := thread.readMember(mtyp, maddr, "procid")
+		if err != nil {
+			return nil, err
+		}
+		m.ThreadID = int(procid)
+		curg, _, err := thread.readMember(mtyp, maddr, "curg")
+		if err != nil {
+			return nil, err
+		}
+		if curg != 0 {
+			goid, ok, err := thread.readMember(gtyp, curg, "goid")
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("runtime.g has no member goid")
+			}
+			m.CurG = int(goid)
+		}
+		paddr, _, err := thread.readMember(mtyp, maddr, "p")
+		if err != nil {
+			return nil, err
+		}
+		if m.P, err = pID(paddr); err != nil {
+			return nil, err
+		}
+		spinning, _, err := thread.readMember(mtyp, maddr, "spinning")
+		if err != nil {
+			return nil, err
+		}
+		blocked, _, err := thread.readMember(mtyp, maddr, "blocked")
+		if err != nil {
+			return nil, err
+		}
+		m.Spinning, m.Blocked = spinning != 0, blocked != 0
+		info.Ms = append(info.Ms, m)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	saddr, styp, err := dbp.runtimeVar("runtime.sched")
+	if err != nil {
+		return nil, err
+	}
+	sched := []struct {
+		dst   *int
+		paths []string
+	}{
+		{&info.GlobalRunqLen, []string{"runqsize", "runq.size"}},
+		{&info.IdlePs, []string{"npidle"}},
+		{&info.IdleMs, []string{"nmidle"}},
+		{&info.SpinningMs, []string{"nmspinning"}},
+	}
+	for _, f := range sched {
+		for _, path := range f.paths {
+			n, ok, err := thread.readMember(styp, saddr, path)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				*f.dst = int(int32(n))
+				break
+			}
+		}
+	}
+
+	phase, err := dbp.readRuntimeInt("runtime.gcphase")
+	if err != nil {
+		return nil, err
+	}
+	info.GCPhase = fmt.Sprintf("unknown(%d)", phase)
+	if phase < uint64(len(gcPhases)) {
+		info.GCPhase = gcPhases[phase]
+	}
+
+	if err := dbp.readMemStats(info, "runtime.memstats", memstatsMembers); err != nil {
+		return nil, err
+	}
+	if _, _, err := dbp.runtimeVar("runtime.gcController"); err == nil {
+		if err := dbp.readMemStats(info, "runtime.gcController", gcControllerMembers); err != nil {
+			return nil, err
+		}
+	}
+
+	// The number of the cycle is kept in work since Go 1.8.
+	if waddr, wtyp, err := dbp.runtimeVar("runtime.work"); err == nil {
+		cycles, ok, err := thread.readMember(wtyp, waddr, "cycles")
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			info.GCCycle = cycles
+			return info, nil
+		}
+	}
+	for _, stat := range info.MemStats {
+		if stat.Name == "memstats.numgc" {
+			info.GCCycle = stat.Value
+		}
+	}
+	return info, nil
+}
+
+// allp returns the addresses of the Ps in runtime.allp, an array in Go 1.9
+// and earlier, a slice since.
+func (dbp *Process) allp(gomaxprocs int) ([]uint64, error) {
+	addr, typ, err := dbp.runtimeVar("runtime.allp")
+	if err != nil {
+		return nil, err
+	}
+	thread := dbp.CurrentThread
+	ptrSize := int64(dbp.arch.PtrSize())
+	base, n := addr, int64(gomaxprocs)
+	if st, ok := resolveTypedef(typ).(*dwarf.StructType); ok {
+		if base, _, err = thread.readMember(st, addr, "array"); err != nil {
+			return nil, err
+		}
+		length, _, err := thread.readMember(st, addr, "len")
+		if err != nil {
+			return nil, err
+		}
+		n = int64(length)
+	}
+	if n > maxMs {
+		return nil, fmt.Errorf("invalid number of Ps %d", n)
+	}
+	var paddrs []uint64
+	for i := int64(0); i < n; i++ {
+		paddr, err := thread.readUintRaw(uintptr(base+uint64(i*ptrSize)), ptrSize)
+		if err != nil {
+			return nil, err
+		}
+		if paddr != 0 {
+			paddrs = append(paddrs, paddr)
+		}
+	}
+	return paddrs, nil
+}
+
+// readMemStats appends to the MemStats of info the members of the
+// runtime variable name that the runtime of the process has.
+func (dbp *Process) readMemStats(info *RuntimeInfo, name string, members []string) error {
+	addr, typ, err := dbp.runtimeVar(name)
+	if err != nil {
+		return err
+	}
+	prefix := strings.TrimPrefix(name, "runtime.")
+	for _, member := range members {
+		n, ok, err := dbp.CurrentThread.readMember(typ, addr, member)
+		if err != nil {
+			return err
+		}
+		if ok {
+			info.MemStats = append(info.MemStats, MemStat{Name: prefix + "." + member, Value: n})
+		}
+	}
+	return nil
+}
+
+// runtimeVar returns the address and type of the package variable name,
+// e.g. runtime.sched.
+func (dbp *Process) runtimeVar(name string) (uint64, dwarf.Type, error) {
+	entry, err := dbp.DwarfReader().FindEntryNamed(name, false)
+	if err != nil {
+		return 0, nil, err
+	}
+	off, ok := entry.Val(dwarf.AttrType).(dwarf.Offset)
+	if !ok {
+		return 0, nil, fmt.Errorf("%s has no type", name)
+	}
+	typ, err := dbp.dwarf.Type(off)
+	if err != nil {
+		return 0, nil, err
+	}
+	instructions, ok := entry.Val(dwarf.AttrLocation).([]byte)
+	if !ok {
+		return 0, nil, fmt.Errorf("%s has no location", name)
+	}
+	addr, err := op.ExecuteStackProgram(0, instructions)
+	if err != nil {
+		return 0, nil, err
+	}
+	return uint64(addr), typ, nil
+}
+
+// readRuntimeInt reads the integer or pointer package variable name.
+func (dbp *Process) readRuntimeInt(name string) (uint64, error) {
+	addr, typ, err := dbp.runtimeVar(name)
+	if err != nil {
+		return 0, err
+	}
+	size := typ.Size()
+	if _, ok := typ.(*dwarf.PtrType); ok {
+		size = int64(dbp.arch.PtrSize())
+	}
+	return dbp.CurrentThread.readUintRaw(uintptr(addr), size)
+}
+
+// readMember reads the integer member of the struct of type typ at addr
+// found by following path, a dot separated list of member names. ok is
+// false if the struct has no such member.
+func (thread *Thread) readMember(typ dwarf.Type, addr uint64, path string) (n uint64, ok bool, err error) {
+	addr, typ, err = memberAt(typ, addr, path)
+	if err != nil {
+		return 0, false, nil
+	}
+	size := typ.Size()
+	if _, isPtr := resolveTypedef(typ).(*dwarf.PtrType); isPtr {
+		size = int64(thread.dbp.arch.PtrSize())
+	}
+	switch size {
+	case 1, 2, 4, 8:
+	default:
+		return 0, false, fmt.Errorf("member %s of size %d is not an integer", path, size)
+	}
+	n, err = thread.readUintRaw(uintptr(addr), size)
+	return n, err == nil, err
+}
@@ -0,0 +1,12 @@
+package proc
+
+import "fmt"
+
+// signalContext returns the registers of the code interrupted by a
+// signal, given the CFA of runtime.sigtramp.
+//
+// On OS X sigtramp is called by the libc trampoline, which keeps the
+// ucontext pointer in a register that isn't saved anywhere we can find.
+func (dbp *Process) signalContext(cfa int64) (*DwarfRegisters, error) {
+	return nil, fmt.Errorf("cannot unwind signal frames on darwin")
+}
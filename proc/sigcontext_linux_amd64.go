@@ -0,0 +1,32 @@
+package proc
+
+import "encoding/binary"
+
+// Offset of uc_mcontext in the ucontext the kernel pushes on the stack
+// when delivering a signal: uc_flags, uc_link and the 24 bytes of uc_stack.
+const ucMcontextOffset = 40
+
+// DWARF numbers of the general purpose registers of sigcontext, in order.
+var sigcontextRegs = []uint64{
+	amd64DwarfR8, amd64DwarfR9, amd64DwarfR10, amd64DwarfR11,
+	amd64DwarfR12, amd64DwarfR13, amd64DwarfR14, amd64DwarfR15,
+	amd64DwarfRDI, amd64DwarfRSI, amd64DwarfRBP, amd64DwarfRBX,
+	amd64DwarfRDX, amd64DwarfRAX, amd64DwarfRCX, amd64DwarfRSP,
+	amd64DwarfRIP,
+}
+
+// signalContext returns the registers of the code interrupted by a
+// signal, given the CFA of runtime.sigtramp. The kernel calls the handler
+// with the return address to its restorer on top of the signal frame, so
+// the ucontext starts at the CFA.
+func (dbp *Process) signalContext(cfa int64) (*DwarfRegisters, error) {
+	data, err := dbp.CurrentThread.readMemory(uintptr(cfa+ucMcontextOffset), len(sigcontextRegs)*8)
+	if err != nil {
+		return nil, err
+	}
+	regs := &DwarfRegisters{}
+	for i, n := range sigcontextRegs {
+		regs.SetReg(n, binary.LittleEndian.Uint64(data[i*8:]))
+	}
+	return regs, nil
+}
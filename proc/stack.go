@@ -2,6 +2,11 @@ package proc
 
 import (
 	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/derekparker/delve/dwarf/frame"
+	"github.com/derekparker/delve/dwarf/op"
 )
 
 // Takes an offset from RSP and returns the address of the
@@ -11,6 +16,9 @@ func (thread *Thread) ReturnAddress() (uint64, error) {
 	if err != nil {
 		return 0, err
 	}
+	if len(locations) == 0 {
+		return 0, fmt.Errorf("no caller frame")
+	}
 	return locations[0].PC, nil
 }
 
@@ -21,7 +29,7 @@ func (thread *Thread) Stacktrace(depth int) ([]Location, error) {
 	if err != nil {
 		return nil, err
 	}
-	locations, err := thread.dbp.stacktrace(regs.PC(), regs.SP(), depth)
+	locations, err := thread.dbp.stacktrace(thread, regs.DwarfRegisters(), depth)
 	if err != nil {
 		return nil, err
 	}
@@ -34,52 +42,407 @@ func (dbp *Process) GoroutineStacktrace(g *G, depth int) ([]Location, error) {
 	if g.thread != nil {
 		return g.thread.Stacktrace(depth)
 	}
-	return dbp.stacktrace(g.PC, g.SP, depth)
+	return dbp.stacktrace(nil, goroutineRegisters(g), depth)
+}
+
+// goroutineRegisters returns the registers saved by the scheduler
+// when g was parked.
+func goroutineRegisters(g *G) *DwarfRegisters {
+	regs := &DwarfRegisters{}
+	regs.SetReg(amd64DwarfRIP, g.PC)
+	regs.SetReg(amd64DwarfRSP, g.SP)
+	return regs
 }
 
+// Returns the current location of a goroutine.
 func (dbp *Process) GoroutineLocation(g *G) *Location {
+	if g.thread != nil {
+		if loc, err := g.thread.Location(); err == nil {
+			return loc
+		}
+	}
 	f, l, fn := dbp.PCToLine(g.PC)
 	return &Location{PC: g.PC, File: f, Line: l, Fn: fn}
 }
 
+// How far down the stack of a goroutine we look for a frame outside of
+// the runtime.
+const maxUserDepth = 20
+
+// GoroutineUserLocation returns the innermost frame of a goroutine that
+// is not part of the runtime, or its current location if there is none.
+func (dbp *Process) GoroutineUserLocation(g *G) *Location {
+	loc := dbp.GoroutineLocation(g)
+	if !isRuntimeLocation(loc) {
+		return loc
+	}
+	locs, err := dbp.GoroutineStacktrace(g, maxUserDepth)
+	if err != nil {
+		return loc
+	}
+	for i := range locs {
+		if !isRuntimeLocation(&locs[i]) {
+			return &locs[i]
+		}
+	}
+	return loc
+}
+
+func isRuntimeLocation(loc *Location) bool {
+	return loc.Fn != nil && strings.HasPrefix(loc.Fn.Name, "runtime.")
+}
+
 type NullAddrError struct{}
 
 func (n NullAddrError) Error() string {
 	return "NULL address"
 }
 
-func (dbp *Process) stacktrace(pc, sp uint64, depth int) ([]Location, error) {
+func (dbp *Process) stacktrace(thread *Thread, regs *DwarfRegisters, depth int) ([]Location, error) {
+	frames, err := dbp.stackframes(thread, regs, depth)
+	if err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, nil
+	}
+	locations := make([]Location, 0, len(frames)-1)
+	for _, frame := range frames[1:] {
+		f, l, fn := dbp.goSymTable.PCToLine(frame.pc)
+		locations = append(locations, Location{PC: frame.pc, File: f, Line: l, Fn: fn})
+	}
+	return locations, nil
+}
+
+// Stackframe is a frame of a call stack.
+type Stackframe struct {
+	// Current is where execution is at in the frame: the current PC for
+	// the innermost frame, the return address for the others.
+	Current Location
+	// Call is the location of the call instruction, its PC pointing inside
+	// it, for all frames but the innermost one for which it is Current.
+	Call Location
+	// CFA is the canonical frame address, the value of SP before the call
+	// to the frame's function.
+	CFA int64
+	// Regs are the registers of the frame. Only the ones that could be
+	// recovered while unwinding are known in frames other than the innermost.
+	Regs *DwarfRegisters
+	// Inlined is true if the frame is a call the compiler inlined, in
+	// which case it shares its CFA and registers with its caller.
+	Inlined bool
+}
+
+// Scope returns the scope variables of the frame are evaluated in.
+func (frame *Stackframe) Scope(thread *Thread) *EvalScope {
+	return &EvalScope{Thread: thread, PC: frame.Call.PC, CFA: frame.CFA, Regs: frame.Regs}
+}
+
+// Stackframes returns the innermost frame of the stack of thread followed
+// by at most depth of its callers.
+func (thread *Thread) Stackframes(depth int) ([]Stackframe, error) {
+	regs, err := thread.Registers()
+	if err != nil {
+		return nil, err
+	}
+	return thread.dbp.frames(thread, regs.DwarfRegisters(), depth)
+}
+
+// GoroutineStackframes returns the innermost frame of the stack of g
+// followed by at most depth of its callers.
+func (dbp *Process) GoroutineStackframes(g *G, depth int) ([]Stackframe, error) {
+	if g.thread != nil {
+		return g.thread.Stackframes(depth)
+	}
+	return dbp.frames(nil, goroutineRegisters(g), depth)
+}
+
+func (dbp *Process) frames(thread *Thread, regs *DwarfRegisters, depth int) ([]Stackframe, error) {
+	rawframes, err := dbp.stackframes(thread, regs, depth)
+	if err != nil {
+		return nil, err
+	}
+	frames := make([]Stackframe, 0, len(rawframes))
+	for i, raw := range rawframes {
+		f, l, fn := dbp.goSymTable.PCToLine(raw.pc)
+		frame := Stackframe{Current: Location{PC: raw.pc, File: f, Line: l, Fn: fn}, CFA: raw.cfa, Regs: raw.regs}
+		if i == 0 {
+			frame.Call = frame.Current
+		} else {
+			// The return address may belong to the line following the call,
+			// or even to another function if the call is the last instruction.
+			f, l, fn := dbp.goSymTable.PCToLine(raw.pc - 1)
+			frame.Call = Location{PC: raw.pc - 1, File: f, Line: l, Fn: fn}
+		}
+		// Calls inlined into the frame's function are logical frames of
+		// their own, sharing the physical frame.
+		for _, ic := range dbp.InlinedCallsAt(frame.Call.PC) {
+			if len(frames) > depth {
+				return frames, nil
+			}
+			inlined := frame
+			inlined.Call.Fn = ic.fn()
+			inlined.Current.Fn = inlined.Call.Fn
+			inlined.Inlined = true
+			frames = append(frames, inlined)
+
+			frame.Current = Location{PC: raw.pc, File: ic.CallFile, Line: ic.CallLine, Fn: fn}
+			frame.Call = Location{PC: frame.Call.PC, File: ic.CallFile, Line: ic.CallLine, Fn: frame.Call.Fn}
+		}
+		if len(frames) > depth {
+			break
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// stackframe is a frame on the stack of a goroutine.
+type stackframe struct {
+	pc   uint64          // Return address into this frame, or current PC for the innermost frame.
+	cfa  int64           // Canonical frame address, the value of SP before the call to this frame.
+	regs *DwarfRegisters // Registers of the frame, as far as they could be recovered.
+}
+
+// Runtime functions switching from the goroutine stack to the system
+// stack of the thread, g0, to run Go or C code. Their caller is on the
+// goroutine stack, as saved in the g.sched of the goroutine running on
+// the thread.
+var stackSwitchFunctions = map[string]bool{
+	"runtime.systemstack": true,
+	"runtime.mcall":       true,
+	"runtime.morestack":   true,
+	"runtime.asmcgocall":  true,
+}
+
+// stackframes walks the stack starting at the frame for regs, returning
+// at most depth+1 frames, the innermost one included.
+//
+// Frames are unwound using the register rules of the frame description
+// entry covering their PC. Frames not covered by any, such as C code
+// without debug_frame or eh_frame, are unwound following the chain of
+// frame pointers. The caller of a signal handler is the context saved by
+// the kernel, and if thread is not nil the unwinder follows it from its
+// system stack back to the stack of the goroutine it runs.
+func (dbp *Process) stackframes(thread *Thread, regs *DwarfRegisters, depth int) ([]stackframe, error) {
 	var (
-		ret       = pc
-		data      = make([]byte, dbp.arch.PtrSize())
-		btoffset  int64
-		locations []Location
-		retaddr   uintptr
+		frames   = make([]stackframe, 0, depth+1)
+		switched bool
 	)
-	for i := int64(0); i < int64(depth); i++ {
-		fde, err := dbp.frameEntries.FDEForPC(ret)
+	for i := 0; i <= depth; i++ {
+		pc := regs.PC()
+		fde, err := dbp.frameEntries.FDEForPC(pc)
 		if err != nil {
-			return nil, err
+			if _, ok := regs.BP(); !ok {
+				if i == 0 {
+					return nil, err
+				}
+				// We can't unwind any further, but the return address is still good.
+				frames = append(frames, stackframe{pc: pc, regs: regs})
+				break
+			}
 		}
-		btoffset += fde.ReturnAddressOffset(ret)
-		retaddr = uintptr(int64(sp) + btoffset + (i * int64(dbp.arch.PtrSize())))
-		if retaddr == 0 {
-			return nil, NullAddrError{}
+		var (
+			cfa     int64
+			callers *DwarfRegisters
+		)
+		if fde != nil {
+			cfa, callers, err = dbp.unwindFrame(fde.EstablishFrame(pc), regs)
+		} else {
+			cfa, callers, err = dbp.unwindFramePointer(regs)
 		}
-		_, err = readMemory(dbp.CurrentThread, retaddr, data)
 		if err != nil {
-			return nil, err
+			if i == 0 {
+				return nil, err
+			}
+			frames = append(frames, stackframe{pc: pc, regs: regs})
+			break
 		}
-		ret = binary.LittleEndian.Uint64(data)
-		if ret <= 0 {
+		frames = append(frames, stackframe{pc: pc, cfa: cfa, regs: regs})
+		if i == depth {
 			break
 		}
-		f, l, fn := dbp.goSymTable.PCToLine(ret)
-		locations = append(locations, Location{PC: ret, File: f, Line: l, Fn: fn})
-		if fn != nil && fn.Name == "runtime.goexit" {
+		fn := dbp.goSymTable.PCToFunc(pc)
+		if i > 0 && fn != nil && fn.Name == "runtime.goexit" {
+			break
+		}
+		if fn != nil && fn.Name == "runtime.sigtramp" {
+			if callers, err = dbp.signalContext(cfa); err != nil {
+				break
+			}
+		} else if fn != nil && stackSwitchFunctions[fn.Name] && thread != nil && !switched {
+			curg, err := thread.curgRegisters()
+			if err != nil {
+				break
+			}
+			if curg != nil {
+				callers = curg
+				switched = true
+			}
+		}
+		if callers.PC() == 0 {
 			break
 		}
+		regs = callers
+	}
+	return frames, nil
+}
 
+// unwindFrame applies the rules of fctx to the registers of a frame,
+// returning the CFA of the frame and the registers of its caller.
+func (dbp *Process) unwindFrame(fctx *frame.FrameContext, regs *DwarfRegisters) (int64, *DwarfRegisters, error) {
+	if fctx.CFAExpression() != nil {
+		return 0, nil, fmt.Errorf("unsupported CFA expression at %#x", regs.PC())
 	}
-	return locations, nil
+	base, ok := regs.Reg(fctx.CFARegister())
+	if !ok {
+		return 0, nil, fmt.Errorf("CFA register %d unknown at %#x", fctx.CFARegister(), regs.PC())
+	}
+	cfa := int64(base) + fctx.CFAOffset()
+	if cfa == 0 {
+		return 0, nil, NullAddrError{}
+	}
+
+	// Go functions don't preserve any register for their caller, so the
+	// registers of the caller are unknown unless the frame description
+	// has a rule for them.
+	callers := &DwarfRegisters{}
+	rules := fctx.Regs()
+	if _, ok := rules[fctx.RetAddrReg()]; !ok {
+		// The return address is pushed by the call instruction right below the CFA.
+		val, err := dbp.CurrentThread.readUintRaw(uintptr(cfa-int64(dbp.arch.PtrSize())), int64(dbp.arch.PtrSize()))
+		if err != nil {
+			return 0, nil, err
+		}
+		callers.SetReg(fctx.RetAddrReg(), val)
+	}
+	for n, rule := range rules {
+		switch rule.Rule() {
+		case frame.RuleUndefined:
+			if n < amd64DwarfRegs {
+				callers.known[n] = false
+			}
+		case frame.RuleSameVal:
+			if val, ok := regs.Reg(n); ok {
+				callers.SetReg(n, val)
+			}
+		case frame.RuleOffset:
+			val, err := dbp.CurrentThread.readUintRaw(uintptr(cfa+rule.Offset()), int64(dbp.arch.PtrSize()))
+			if err != nil {
+				return 0, nil, err
+			}
+			callers.SetReg(n, val)
+		case frame.RuleValOffset:
+			callers.SetReg(n, uint64(cfa+rule.Offset()))
+		case frame.RuleRegister:
+			if val, ok := regs.Reg(rule.Reg()); ok {
+				callers.SetReg(n, val)
+			}
+		case frame.RuleExpression, frame.RuleValExpression:
+			val, err := dbp.executeRuleExpression(rule.Expression(), cfa, regs)
+			if err != nil {
+				continue
+			}
+			if rule.Rule() == frame.RuleExpression {
+				v, err := dbp.CurrentThread.readUintRaw(uintptr(val), int64(dbp.arch.PtrSize()))
+				if err != nil {
+					return 0, nil, err
+				}
+				val = int64(v)
+			}
+			callers.SetReg(n, uint64(val))
+		}
+	}
+	if n := fctx.RetAddrReg(); n != amd64DwarfRIP {
+		val, _ := callers.Reg(n)
+		callers.SetReg(amd64DwarfRIP, val)
+	}
+	// By definition the CFA is the value of SP in the caller.
+	callers.SetReg(amd64DwarfRSP, uint64(cfa))
+	return cfa, callers, nil
+}
+
+// executeRuleExpression evaluates the DWARF expression of a register rule
+// in the frame of regs, which starts with the CFA pushed on the stack.
+func (dbp *Process) executeRuleExpression(instructions []byte, cfa int64, regs *DwarfRegisters) (int64, error) {
+	ctx := &op.Context{
+		CFA:   cfa,
+		Stack: []int64{cfa},
+		Reg:   regs.Reg,
+		ReadMemory: func(addr uint64, size int) ([]byte, error) {
+			return dbp.CurrentThread.readMemory(uintptr(addr), size)
+		},
+		PtrSize: dbp.arch.PtrSize(),
+	}
+	pieces, err := op.Execute(instructions, ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(pieces) != 1 || pieces[0].Kind != op.AddrPiece || pieces[0].BitSize != 0 {
+		return 0, fmt.Errorf("register rule at %#x is not an address", regs.PC())
+	}
+	return pieces[0].Addr, nil
+}
+
+// unwindFramePointer unwinds a frame that has no frame description entry
+// assuming it saved the frame pointer of its caller right below the
+// return address and points RBP to it.
+func (dbp *Process) unwindFramePointer(regs *DwarfRegisters) (int64, *DwarfRegisters, error) {
+	bp, _ := regs.BP()
+	if bp == 0 {
+		return 0, nil, NullAddrError{}
+	}
+	var (
+		ptrSize = uint64(dbp.arch.PtrSize())
+		callers = &DwarfRegisters{}
+	)
+	data, err := dbp.CurrentThread.readMemory(uintptr(bp), int(2*ptrSize))
+	if err != nil {
+		return 0, nil, err
+	}
+	callers.SetReg(amd64DwarfRBP, binary.LittleEndian.Uint64(data[:ptrSize]))
+	callers.SetReg(amd64DwarfRIP, binary.LittleEndian.Uint64(data[ptrSize:]))
+	cfa := int64(bp + 2*ptrSize)
+	callers.SetReg(amd64DwarfRSP, uint64(cfa))
+	return cfa, callers, nil
+}
+
+// curgRegisters returns the registers saved in the g.sched of the
+// goroutine scheduled on thread, if the thread is running on its system
+// stack, nil otherwise.
+func (thread *Thread) curgRegisters() (*DwarfRegisters, error) {
+	gaddr, err := thread.gAddr()
+	if err != nil {
+		return nil, err
+	}
+	gtyp, err := thread.dbp.structTypeNamed("runtime.g")
+	if err != nil {
+		return nil, err
+	}
+	mtyp, err := thread.dbp.structTypeNamed("runtime.m")
+	if err != nil {
+		return nil, err
+	}
+	m, ok, err := thread.readMember(gtyp, gaddr, "m")
+	if err != nil || !ok || m == 0 {
+		return nil, err
+	}
+	curg, ok, err := thread.readMember(mtyp, m, "curg")
+	if err != nil || !ok || curg == 0 || curg == gaddr {
+		return nil, err
+	}
+	regs := &DwarfRegisters{}
+	for _, r := range []struct {
+		reg  uint64
+		name string
+	}{{amd64DwarfRIP, "sched.pc"}, {amd64DwarfRSP, "sched.sp"}, {amd64DwarfRBP, "sched.bp"}} {
+		val, ok, err := thread.readMember(gtyp, curg, r.name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			regs.SetReg(r.reg, val)
+		}
+	}
+	return regs, nil
 }
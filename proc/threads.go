@@ -19,6 +19,7 @@ type Thread struct {
 	Id                int             // Thread ID or mach port
 	Status            *sys.WaitStatus // Status returned from last wait call
 	CurrentBreakpoint *Breakpoint     // Breakpoint thread is currently stopped at
+	CaughtGoroutine   *G              // Goroutine created or exiting, if stopped at a catchpoint
 
 	dbp            *Process
 	singleStepping bool
@@ -183,6 +184,7 @@ func (thread *Thread) next(curpc uint64, fde *frame.FrameDescriptionEntry, file
 	for i := range lines {
 		pcs = append(pcs, thread.dbp.lineInfo.AllPCsForFileLine(file, lines[i])...)
 	}
+	pcs = thread.dbp.inlinedNextPCs(curpc, fde, pcs)
 
 	var covered bool
 	for i := range pcs {
@@ -210,7 +212,7 @@ func (thread *Thread) next(curpc uint64, fde *frame.FrameDescriptionEntry, file
 // the benefit of an AST we can't be sure we're not at a branching statement and thus
 // cannot accurately predict where we may end up.
 func (thread *Thread) cnext(curpc uint64, fde *frame.FrameDescriptionEntry) error {
-	pcs := thread.dbp.lineInfo.AllPCsBetween(fde.Begin(), fde.End())
+	pcs := thread.dbp.inlinedNextPCs(curpc, fde, thread.dbp.lineInfo.AllPCsBetween(fde.Begin(), fde.End()))
 	ret, err := thread.ReturnAddress()
 	if err != nil {
 		return err
@@ -260,7 +262,22 @@ func (thread *Thread) SetPC(pc uint64) error {
 // current instruction stream. The instructions are obviously arch/os dependant, as they
 // vary on how thread local storage is implemented, which MMU register is used and
 // what the offset into thread local storage is.
-func (thread *Thread) getG() (g *G, err error) {
+func (thread *Thread) getG() (*G, error) {
+	gaddr, err := thread.gAddr()
+	if err != nil {
+		return nil, err
+	}
+	g, err := parseG(thread, gaddr, false)
+	if err != nil {
+		return nil, err
+	}
+	g.thread = thread
+	return g, nil
+}
+
+// gAddr returns the address of the G structure running on thread, as
+// described for getG.
+func (thread *Thread) gAddr() (gaddr uint64, err error) {
 	var pcInt uint64
 	pcInt, err = thread.PC()
 	if err != nil {
@@ -305,17 +322,15 @@ func (thread *Thread) getG() (g *G, err error) {
 	// known breakpoints.
 	thread.dbp.halt = true
 	defer func(dbp *Process) { dbp.halt = false }(thread.dbp)
-	if _, err = thread.dbp.trapWait(-1); err != nil {
+	// Only wait for this thread, other threads may report stops of
+	// their own that must be handled once the process resumes.
+	if _, err = thread.dbp.trapWait(thread.Id); err != nil {
 		return
 	}
 	// Grab *G from RCX.
 	regs, err := thread.Registers()
 	if err != nil {
-		return nil, err
-	}
-	g, err = parseG(thread, regs.CX(), false)
-	if err == nil {
-		g.thread = thread
+		return 0, err
 	}
-	return
+	return regs.CX(), nil
 }
@@ -9,11 +9,13 @@ import (
 // Not actually used, but necessary
 // to be defined.
 type OSSpecificDetails struct {
-	registers sys.PtraceRegs
+	registers  sys.PtraceRegs
+	pendingSig sys.Signal // Signal to deliver when the thread resumes.
 }
 
 func (t *Thread) Halt() error {
 	if stopped(t.Id) {
+		t.running = false
 		return nil
 	}
 	err := sys.Tgkill(t.dbp.Pid, t.Id, sys.SIGSTOP)
@@ -29,8 +31,10 @@ func (t *Thread) Halt() error {
 }
 
 func (t *Thread) resume() (err error) {
+	sig := t.os.pendingSig
+	t.os.pendingSig = 0
 	t.running = true
-	t.dbp.execPtraceFunc(func() { err = PtraceCont(t.Id, 0) })
+	t.dbp.execPtraceFunc(func() { err = PtraceCont(t.Id, int(sig)) })
 	return
 }
 
@@ -0,0 +1,229 @@
+package proc
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TypeInfo describes the memory layout of a type.
+type TypeInfo struct {
+	Name string
+	Size int64
+	// Underlying is the Go type the type is defined as, "struct" for
+	// structs, whose fields are listed in Fields.
+	Underlying string
+	Fields     []FieldInfo
+}
+
+// FieldInfo describes a field of a struct.
+type FieldInfo struct {
+	Name   string
+	Type   string
+	Offset int64
+	Size   int64
+}
+
+// Types returns the names of the types of the process, sorted.
+func (dbp *Process) Types() ([]string, error) {
+	var (
+		rdr   = dbp.DwarfReader()
+		seen  = map[string]bool{}
+		types []string
+	)
+	for entry, err := rdr.NextType(); entry != nil; entry, err = rdr.NextType() {
+		if err != nil {
+			return nil, err
+		}
+		rdr.SkipChildren()
+		name, ok := entry.Val(dwarf.AttrName).(string)
+		if !ok || name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types, nil
+}
+
+// DescribeType returns the layout of the type with the given name.
+func (dbp *Process) DescribeType(name string) (*TypeInfo, error) {
+	typ, err := dbp.typeNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	info := &TypeInfo{Name: name, Size: dbp.sizeOf(typ)}
+	st, ok := resolveTypedef(typ).(*dwarf.StructType)
+	if !ok || st.StructName == "string" || strings.HasPrefix(st.StructName, "[]") {
+		info.Underlying = underlyingName(typ)
+		return info, nil
+	}
+	info.Underlying = "struct"
+	for _, f := range st.Field {
+		info.Fields = append(info.Fields, FieldInfo{
+			Name:   f.Name,
+			Type:   goTypeName(f.Type),
+			Offset: f.ByteOffset,
+			Size:   dbp.sizeOf(f.Type),
+		})
+	}
+	return info, nil
+}
+
+// underlyingName returns the Go name of the type typ is defined as.
+func underlyingName(typ dwarf.Type) string {
+	u := resolveTypedef(typ)
+	if _, ok := u.(*dwarf.FuncType); ok {
+		// DWARF doesn't tell parameters from results, but function types
+		// are named after their signature.
+		for t, ok := typ.(*dwarf.TypedefType); ok; t, ok = t.Type.(*dwarf.TypedefType) {
+			if strings.HasPrefix(t.Name, "func(") {
+				return t.Name
+			}
+		}
+	}
+	return goTypeName(u)
+}
+
+// sizeOf returns the size of values of type t. DWARF doesn't always give
+// the size of pointers.
+func (dbp *Process) sizeOf(t dwarf.Type) int64 {
+	if _, ok := resolveTypedef(t).(*dwarf.PtrType); ok {
+		return int64(dbp.arch.PtrSize())
+	}
+	return t.Size()
+}
+
+// WhatIs returns the Go type of expr in the current frame.
+func (thread *Thread) WhatIs(expr string) (string, error) {
+	scope, err := thread.Scope()
+	if err != nil {
+		return "", err
+	}
+	return scope.WhatIs(expr)
+}
+
+// WhatIs returns the Go type of expr, the default type of untyped
+// constants.
+func (scope *EvalScope) WhatIs(expr string) (string, error) {
+	v, err := scope.evalExpr(expr)
+	if err != nil {
+		return "", err
+	}
+	if v.typ == nil {
+		return valueType(v), nil
+	}
+	return goTypeName(v.typ), nil
+}
+
+// structTypeNamed returns the struct type with the given name.
+func (dbp *Process) structTypeNamed(name string) (*dwarf.StructType, error) {
+	if st, ok := dbp.structTypes[name]; ok {
+		return st, nil
+	}
+	rdr := dbp.DwarfReader()
+	entry, err := rdr.SeekToTypeNamed(name)
+	if err != nil {
+		return nil, err
+	}
+	typ, err := dbp.dwarf.Type(entry.Offset)
+	if err != nil {
+		return nil, err
+	}
+	st, ok := typ.(*dwarf.StructType)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a struct", name)
+	}
+	dbp.structTypes[name] = st
+	return st, nil
+}
+
+// typeNamed returns the type with the given name.
+func (dbp *Process) typeNamed(name string) (dwarf.Type, error) {
+	if typ, ok := dbp.types[name]; ok {
+		return typ, nil
+	}
+	rdr := dbp.DwarfReader()
+	for entry, err := rdr.NextType(); entry != nil; entry, err = rdr.NextType() {
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := entry.Val(dwarf.AttrName).(string); n != name {
+			rdr.SkipChildren()
+			continue
+		}
+		typ, err := dbp.dwarf.Type(entry.Offset)
+		if err != nil {
+			return nil, err
+		}
+		dbp.types[name] = typ
+		return typ, nil
+	}
+	return nil, fmt.Errorf("could not find type %s", name)
+}
+
+// typeReported returns the type reported as name in the Type of
+// variables. Types DWARF names differently, like anonymous structs and
+// pointers to structs, are found by comparing the names they are reported
+// with.
+func (dbp *Process) typeReported(name string) (dwarf.Type, error) {
+	if typ, err := dbp.typeNamed(name); err == nil && typeString(typ) == name {
+		return typ, nil
+	}
+	if typ, err := dbp.typeNamed(strings.TrimPrefix(name, "struct ")); err == nil && typeString(typ) == name {
+		return typ, nil
+	}
+	rdr := dbp.DwarfReader()
+	for entry, err := rdr.NextType(); entry != nil; entry, err = rdr.NextType() {
+		if err != nil {
+			return nil, err
+		}
+		rdr.SkipChildren()
+		typ, err := dbp.dwarf.Type(entry.Offset)
+		if err != nil {
+			return nil, err
+		}
+		if typeString(typ) == name {
+			return typ, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find type %s", name)
+}
+
+// memberAt returns the address and the type of the member of the value
+// of type typ at addr found by path, the names of nested members
+// separated by dots. Offsets are looked up in DWARF rather than assumed
+// to be those of a given Go version. With addr 0 it returns the offset.
+func memberAt(typ dwarf.Type, addr uint64, path string) (uint64, dwarf.Type, error) {
+	for _, name := range strings.Split(path, ".") {
+		st, ok := resolveTypedef(typ).(*dwarf.StructType)
+		if !ok {
+			return 0, nil, fmt.Errorf("%s is not a struct", typ)
+		}
+		var found *dwarf.StructField
+		for _, f := range st.Field {
+			if f.Name == name {
+				found = f
+				break
+			}
+		}
+		if found == nil {
+			return 0, nil, fmt.Errorf("%s has no member %s", st.StructName, name)
+		}
+		addr, typ = addr+uint64(found.ByteOffset), found.Type
+	}
+	return addr, typ, nil
+}
+
+// memberAtAny is like memberAt for the first of paths the value has, for
+// members renamed across Go versions.
+func memberAtAny(typ dwarf.Type, addr uint64, paths ...string) (uint64, dwarf.Type, error) {
+	for _, path := range paths {
+		if maddr, mtyp, err := memberAt(typ, addr, path); err == nil {
+			return maddr, mtyp, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("%s has no member %s", typ, strings.Join(paths, " or "))
+}
@@ -6,8 +6,13 @@ import (
 	"debug/gosym"
 	"encoding/binary"
 	"fmt"
+	"math"
+	"reflect"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 	"unsafe"
 
 	"github.com/derekparker/delve/dwarf/op"
@@ -15,26 +20,87 @@ import (
 )
 
 const (
-	maxVariableRecurse = 1  // How far to recurse when evaluating nested types.
-	maxArrayValues     = 64 // Max value for reading large arrays.
+	maxVariableRecurse = 1    // How far to recurse when evaluating nested types.
+	maxArrayValues     = 64   // Max value for reading large arrays.
+	maxStringLen       = 4096 // Max number of bytes read from strings.
 
 	ChanRecv = "chan receive"
 	ChanSend = "chan send"
 )
 
+// Goroutine status values, see runtime/runtime2.go.
+const (
+	Gidle uint64 = iota
+	Grunnable
+	Grunning
+	Gsyscall
+	Gwaiting
+	Gmoribund // Currently unused.
+	Gdead
+	Genqueue // Currently unused.
+	Gcopystack
+
+	// Set in addition to one of the statuses above
+	// while the goroutine's stack is being scanned.
+	Gscan uint64 = 0x1000
+)
+
 // Represents an evaluated variable.
 type Variable struct {
 	Name  string
 	Value string
 	Type  string
+
+	Addr uint64       // Address of the variable, zero if it was computed.
+	Kind reflect.Kind // Kind of the value, Invalid if unknown.
+
+	// Length of strings, arrays, slices, maps and channels,
+	// capacity of slices and channels.
+	Len int64
+	Cap int64
+
+	// Fields of structs, elements of arrays, slices and channels,
+	// the pointee of pointers, the dynamic value of interfaces, and
+	// alternating keys and values of maps. Only the first elements are
+	// loaded when there are more than allowed by the LoadConfig.
+	Children []*Variable
+
+	// Unloaded is set if the children of the variable weren't read
+	// because they are nested too deep or reference a value being loaded.
+	Unloaded bool
+
+	// Shadowed is set for local variables hidden by a variable with the
+	// same name declared in an inner lexical block.
+	Shadowed bool
+
+	typ     dwarf.Type
+	isnil   bool
+	channel *Channel
+	fn      string // Name and location of the function of func values.
+	// numFormat is the format integers are printed with.
+	numFormat Format
+	// printer renders the value with the pretty-printer of its type, and
+	// printed is set once it did.
+	printer func() (string, error)
+	printed bool
 }
 
 // Represents a runtime M (OS thread) structure.
 type M struct {
-	procid   int     // Thread ID or port.
-	spinning uint8   // Busy looping.
-	blocked  uint8   // Waiting on futex / semaphore.
-	curg     uintptr // Current G running on this thread.
+	ID       int  // ID of the M in the runtime.
+	ThreadID int  // Thread ID or port.
+	CurG     int  // ID of the goroutine running on the M, zero if none.
+	P        int  // ID of the P held by the M, -1 if none.
+	Spinning bool // Busy looping looking for work.
+	Blocked  bool // Waiting on futex / semaphore.
+}
+
+// Represents a runtime P (processor) structure.
+type P struct {
+	ID      int
+	Status  string // One of idle, running, syscall, gcstop or dead.
+	M       int    // ID of the M holding the P, -1 if none.
+	RunqLen int    // Goroutines in the local run queue of the P.
 }
 
 // Represents a runtime G (goroutine) structure (at least the
@@ -45,6 +111,22 @@ type G struct {
 	SP         uint64 // SP of goroutine when it was parked.
 	GoPC       uint64 // PC of 'go' statement that created this goroutine.
 	WaitReason string // Reason for goroutine being parked.
+	Status     uint64 // Status of the goroutine, one of the G* constants.
+	WaitSince  int64  // Approximate runtime nanotime when the goroutine was parked, zero if unknown.
+
+	// Approximate time the goroutine has been waiting for, zero if unknown.
+	WaitTime time.Duration
+
+	// Thread ID of the M the goroutine is running on, zero if none.
+	ThreadID int
+	// Whether the goroutine is locked to its thread by runtime.LockOSThread.
+	LockedToThread bool
+
+	// Bounds of the goroutine's stack.
+	StackLo, StackHi uint64
+
+	// Profiler labels set with runtime/pprof.
+	Labels map[string]string
 
 	// Information on goroutine location.
 	File string
@@ -56,6 +138,34 @@ type G struct {
 
 	// Thread that this goroutine is currently allocated to
 	thread *Thread
+
+	// Address of the runtime G structure.
+	addr uint64
+}
+
+// StatusString returns the name of the status of the goroutine.
+func (g *G) StatusString() string {
+	switch g.Status &^ Gscan {
+	case Gidle:
+		return "idle"
+	case Grunnable:
+		return "runnable"
+	case Grunning:
+		return "running"
+	case Gsyscall:
+		return "syscall"
+	case Gwaiting:
+		return "waiting"
+	case Gmoribund:
+		return "moribund"
+	case Gdead:
+		return "dead"
+	case Genqueue:
+		return "enqueue"
+	case Gcopystack:
+		return "copystack"
+	}
+	return fmt.Sprintf("unknown (%d)", g.Status)
 }
 
 // Returns whether the goroutine is blocked on
@@ -66,10 +176,13 @@ func (g *G) ChanRecvBlocked() bool {
 
 // chanRecvReturnAddr returns the address of the return from a channel read.
 func (g *G) chanRecvReturnAddr(dbp *Process) (uint64, error) {
-	locs, err := dbp.stacktrace(g.PC, g.SP, 4)
+	locs, err := dbp.stacktrace(nil, goroutineRegisters(g), 4)
 	if err != nil {
 		return 0, err
 	}
+	if len(locs) == 0 {
+		return 0, fmt.Errorf("no caller frame")
+	}
 	topLoc := locs[len(locs)-1]
 	return topLoc.PC, nil
 }
@@ -157,6 +270,15 @@ func parseG(thread *Thread, gaddr uint64, deref bool) (*G, error) {
 	if err != nil {
 		return nil, err
 	}
+	// Parse atomicstatus
+	statusAddr, err := rdr.AddrForMember("atomicstatus", initialInstructions)
+	if err != nil {
+		return nil, err
+	}
+	status, err := thread.readUintRaw(uintptr(statusAddr), 4)
+	if err != nil {
+		return nil, err
+	}
 	// Parse goid
 	goidAddr, err := rdr.AddrForMember("goid", initialInstructions)
 	if err != nil {
@@ -195,62 +317,145 @@ func parseG(thread *Thread, gaddr uint64, deref bool) (*G, error) {
 		Line:       l,
 		Func:       fn,
 		WaitReason: waitreason,
+		Status:     status,
 		DeferPC:    deferPC,
+		addr:       gaddr,
+	}
+	if err := thread.loadGState(g); err != nil {
+		return nil, err
 	}
 	return g, nil
 }
 
-// Returns the value of the named variable.
-func (thread *Thread) EvalVariable(name string) (*Variable, error) {
-	pc, err := thread.PC()
+// loadGState reads the members of the runtime G structure describing the
+// stack, scheduling state and labels of g. Members missing in the runtime
+// version of the process are skipped.
+func (thread *Thread) loadGState(g *G) error {
+	gtyp, err := thread.dbp.structTypeNamed("runtime.g")
 	if err != nil {
-		return nil, err
+		return err
 	}
+	ptrSize := int64(thread.dbp.arch.PtrSize())
 
-	reader := thread.dbp.DwarfReader()
-
-	_, err = reader.SeekToFunction(pc)
+	if g.StackLo, _, err = thread.readMember(gtyp, g.addr, "stack.lo"); err != nil {
+		return err
+	}
+	if g.StackHi, _, err = thread.readMember(gtyp, g.addr, "stack.hi"); err != nil {
+		return err
+	}
+	if waitsince, ok, err := thread.readMember(gtyp, g.addr, "waitsince"); err != nil {
+		return err
+	} else if ok {
+		g.WaitSince = int64(waitsince)
+		if now, err := nanotime(); err == nil && g.WaitSince > 0 && now > g.WaitSince {
+			g.WaitTime = time.Duration(now - g.WaitSince)
+		}
+	}
+	lockedm, _, err := thread.readMember(gtyp, g.addr, "lockedm")
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	varName := name
-	memberName := ""
-	if strings.Contains(name, ".") {
-		idx := strings.Index(name, ".")
-		varName = name[:idx]
-		memberName = name[idx+1:]
+	g.LockedToThread = lockedm != 0
+	maddr, _, err := thread.readMember(gtyp, g.addr, "m")
+	if err != nil {
+		return err
 	}
-
-	for entry, err := reader.NextScopeVariable(); entry != nil; entry, err = reader.NextScopeVariable() {
+	if maddr != 0 {
+		mtyp, err := thread.dbp.structTypeNamed("runtime.m")
 		if err != nil {
-			return nil, err
+			return err
 		}
-
-		n, ok := entry.Val(dwarf.AttrName).(string)
-		if !ok {
-			continue
+		procid, _, err := thread.readMember(mtyp, maddr, "procid")
+		if err != nil {
+			return err
 		}
-
-		if n == varName {
-			if len(memberName) == 0 {
-				return thread.extractVariableFromEntry(entry)
-			}
-			return thread.evaluateStructMember(entry, reader, memberName)
+		g.ThreadID = int(procid)
+	}
+	labels, _, err := thread.readMember(gtyp, g.addr, "labels")
+	if err != nil {
+		return err
+	}
+	if labels != 0 {
+		// labels points to a runtime/pprof.labelMap, a map[string]string.
+		hmap, err := thread.readUintRaw(uintptr(labels), ptrSize)
+		if err != nil {
+			return err
+		}
+		if g.Labels, err = thread.readStringMap(hmap); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// EvalScope is the scope variables are evaluated in: the frame of the
+// function containing PC, whose canonical frame address is CFA and
+// registers Regs. Memory is read through Thread.
+type EvalScope struct {
+	Thread *Thread
+	PC     uint64
+	CFA    int64
+	Regs   *DwarfRegisters
+
+	// Frame base of the function, computed on first use.
+	fbase          int64
+	frameBaseKnown bool
+}
 
-	return nil, fmt.Errorf("could not find symbol value for %s", name)
+// Scope returns the scope of the innermost frame of thread.
+func (thread *Thread) Scope() (*EvalScope, error) {
+	frames, err := thread.Stackframes(0)
+	if err != nil {
+		return nil, err
+	}
+	return frames[0].Scope(thread), nil
+}
+
+// Returns the value of the named variable.
+func (thread *Thread) EvalVariable(name string) (*Variable, error) {
+	scope, err := thread.Scope()
+	if err != nil {
+		return nil, err
+	}
+	return scope.EvalVariable(name)
+}
+
+// EvalExpression returns the value of expr in the current frame, loaded
+// with cfg.
+func (thread *Thread) EvalExpression(expr string, cfg LoadConfig) (*Variable, error) {
+	scope, err := thread.Scope()
+	if err != nil {
+		return nil, err
+	}
+	return scope.EvalExpression(expr, cfg)
 }
 
 // LocalVariables returns all local variables from the current function scope.
 func (thread *Thread) LocalVariables() ([]*Variable, error) {
-	return thread.variablesByTag(dwarf.TagVariable)
+	scope, err := thread.Scope()
+	if err != nil {
+		return nil, err
+	}
+	return scope.LocalVariables()
 }
 
 // FunctionArguments returns the name, value, and type of all current function arguments.
 func (thread *Thread) FunctionArguments() ([]*Variable, error) {
-	return thread.variablesByTag(dwarf.TagFormalParameter)
+	scope, err := thread.Scope()
+	if err != nil {
+		return nil, err
+	}
+	return scope.FunctionArguments()
+}
+
+// LocalVariables returns all local variables from the function of the scope.
+func (scope *EvalScope) LocalVariables() ([]*Variable, error) {
+	return scope.variablesByTag(dwarf.TagVariable)
+}
+
+// FunctionArguments returns the name, value, and type of all arguments of the function of the scope.
+func (scope *EvalScope) FunctionArguments() ([]*Variable, error) {
+	return scope.variablesByTag(dwarf.TagFormalParameter)
 }
 
 // PackageVariables returns the name, value, and type of all package variables in the application.
@@ -265,7 +470,7 @@ func (thread *Thread) PackageVariables() ([]*Variable, error) {
 		}
 
 		// Ignore errors trying to extract values
-		val, err := thread.extractVariableFromEntry(entry)
+		val, err := (&EvalScope{Thread: thread}).extractVariableFromEntry(entry)
 		if err != nil {
 			continue
 		}
@@ -275,74 +480,8 @@ func (thread *Thread) PackageVariables() ([]*Variable, error) {
 	return vars, nil
 }
 
-func (thread *Thread) evaluateStructMember(parentEntry *dwarf.Entry, rdr *reader.Reader, memberName string) (*Variable, error) {
-	parentAddr, err := thread.extractVariableDataAddress(parentEntry, rdr)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get parent variable name
-	parentName, ok := parentEntry.Val(dwarf.AttrName).(string)
-	if !ok {
-		return nil, fmt.Errorf("unable to retrive variable name")
-	}
-
-	// Seek reader to the type information so members can be iterated
-	_, err = rdr.SeekToType(parentEntry, true, true)
-	if err != nil {
-		return nil, err
-	}
-
-	// Iterate to find member by name
-	for memberEntry, err := rdr.NextMemberVariable(); memberEntry != nil; memberEntry, err = rdr.NextMemberVariable() {
-		if err != nil {
-			return nil, err
-		}
-
-		name, ok := memberEntry.Val(dwarf.AttrName).(string)
-		if !ok {
-			continue
-		}
-
-		if name == memberName {
-			// Nil ptr, wait until here to throw a nil pointer error to prioritize no such member error
-			if parentAddr == 0 {
-				return nil, fmt.Errorf("%s is nil", parentName)
-			}
-
-			memberInstr, err := rdr.InstructionsForEntry(memberEntry)
-			if err != nil {
-				return nil, err
-			}
-
-			offset, ok := memberEntry.Val(dwarf.AttrType).(dwarf.Offset)
-			if !ok {
-				return nil, fmt.Errorf("type assertion failed")
-			}
-
-			data := thread.dbp.dwarf
-			t, err := data.Type(offset)
-			if err != nil {
-				return nil, err
-			}
-
-			baseAddr := make([]byte, 8)
-			binary.LittleEndian.PutUint64(baseAddr, uint64(parentAddr))
-
-			parentInstructions := append([]byte{op.DW_OP_addr}, baseAddr...)
-			val, err := thread.extractValue(append(parentInstructions, memberInstr...), 0, t, true)
-			if err != nil {
-				return nil, err
-			}
-			return &Variable{Name: strings.Join([]string{parentName, memberName}, "."), Type: t.String(), Value: val}, nil
-		}
-	}
-
-	return nil, fmt.Errorf("%s has no member %s", parentName, memberName)
-}
-
 // Extracts the name, type, and value of a variable from a dwarf entry
-func (thread *Thread) extractVariableFromEntry(entry *dwarf.Entry) (*Variable, error) {
+func (scope *EvalScope) extractVariableFromEntry(entry *dwarf.Entry) (*Variable, error) {
 	if entry == nil {
 		return nil, fmt.Errorf("invalid entry")
 	}
@@ -361,297 +500,1056 @@ func (thread *Thread) extractVariableFromEntry(entry *dwarf.Entry) (*Variable, e
 		return nil, fmt.Errorf("type assertion failed")
 	}
 
-	data := thread.dbp.dwarf
+	data := scope.Thread.dbp.dwarf
 	t, err := data.Type(offset)
 	if err != nil {
 		return nil, err
 	}
 
-	instructions, ok := entry.Val(dwarf.AttrLocation).([]byte)
-	if !ok {
-		return nil, fmt.Errorf("type assertion failed")
-	}
-
-	val, err := thread.extractValue(instructions, 0, t, true)
+	addr, err := scope.locate(entry, t)
 	if err != nil {
 		return nil, err
 	}
+	return scope.Thread.loadVariable(n, addr, t, scope.Thread.dbp.DefaultLoadConfig())
+}
 
-	return &Variable{Name: n, Type: t.String(), Value: val}, nil
+// LoadConfig limits how much of a variable is read from the process.
+type LoadConfig struct {
+	// MaxVariableRecurse is how many levels of nested structs are loaded.
+	MaxVariableRecurse int
+	// MaxStringLen is the maximum number of bytes read from strings.
+	MaxStringLen int
+	// MaxArrayValues is the maximum number of elements read from arrays,
+	// slices and channels.
+	MaxArrayValues int
+	// MaxMapValues is the maximum number of entries read from maps.
+	MaxMapValues int
+	// Raw disables the pretty-printers of the types of values, see
+	// RegisterPrettyPrinter.
+	Raw bool
+	// Format is how integers are printed.
+	Format Format
 }
 
-// Execute the stack program taking into account the current stack frame
-func (thread *Thread) executeStackProgram(instructions []byte) (int64, error) {
-	regs, err := thread.Registers()
-	if err != nil {
-		return 0, err
+// DefaultLoadConfig returns the configuration variables are loaded with
+// when none is given.
+func (dbp *Process) DefaultLoadConfig() LoadConfig {
+	return LoadConfig{
+		MaxVariableRecurse: maxVariableRecurse,
+		MaxStringLen:       maxStringLen,
+		MaxArrayValues:     maxArrayValues,
+		MaxMapValues:       dbp.MaxMapValues,
 	}
+}
 
-	fde, err := thread.dbp.frameEntries.FDEForPC(regs.PC())
-	if err != nil {
-		return 0, err
+// loader reads variables and their children from the memory of the
+// process, within the limits of a LoadConfig.
+type loader struct {
+	thread *Thread
+	cfg    LoadConfig
+	// Values being loaded, by address and type, to stop at cycles.
+	loading map[loadKey]bool
+	// Values pointers were dereferenced to, when values reached through
+	// several pointers are loaded only once, see DumpExpression.
+	pointees map[loadKey]bool
+	// Whether the values of composite variables are left unrendered.
+	noValues bool
+}
+
+type loadKey struct {
+	addr int64
+	typ  string
+}
+
+func (thread *Thread) newLoader(cfg LoadConfig) *loader {
+	// Negative limits load nothing rather than everything.
+	for _, n := range []*int{&cfg.MaxVariableRecurse, &cfg.MaxStringLen, &cfg.MaxArrayValues, &cfg.MaxMapValues} {
+		if *n < 0 {
+			*n = 0
+		}
 	}
+	return &loader{thread: thread, cfg: cfg, loading: map[loadKey]bool{}}
+}
+
+// loadVariable reads the variable name of type typ at addr and renders the
+// values of it and its children.
+func (thread *Thread) loadVariable(name string, addr int64, typ dwarf.Type, cfg LoadConfig) (*Variable, error) {
+	return thread.newLoader(cfg).loadVariable(name, addr, typ)
+}
 
-	fctx := fde.EstablishFrame(regs.PC())
-	cfa := fctx.CFAOffset() + int64(regs.SP())
-	address, err := op.ExecuteStackProgram(cfa, instructions)
+// loadVariable is like the loadVariable method of Thread, reading the
+// variable with l.
+func (l *loader) loadVariable(name string, addr int64, typ dwarf.Type) (*Variable, error) {
+	v, err := l.load(name, addr, typ, 0)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return address, nil
+	if !l.noValues {
+		v.setValues()
+	}
+	clearFakeAddrs(v)
+	return v, nil
 }
 
-// Extracts the address of a variable, dereferencing any pointers
-func (thread *Thread) extractVariableDataAddress(entry *dwarf.Entry, rdr *reader.Reader) (int64, error) {
-	instructions, err := rdr.InstructionsForEntry(entry)
+// LoadVariable reads the value of the type named typ at addr, as reported
+// by the Type of a Variable, so that the children of a variable cut short
+// by the limits of a LoadConfig can be loaded later.
+func (thread *Thread) LoadVariable(addr uint64, typ string, cfg LoadConfig) (*Variable, error) {
+	t, err := thread.dbp.typeReported(typ)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	return thread.loadVariable("", int64(addr), t, cfg)
+}
 
-	address, err := thread.executeStackProgram(instructions)
+// extractValue renders the value of type typ at addr, loaded with the
+// default configuration. Structs are prefixed by the name of their type
+// if printStructName is set.
+func (thread *Thread) extractValue(addr int64, typ dwarf.Type, printStructName bool) (string, error) {
+	v, err := thread.newLoader(thread.dbp.DefaultLoadConfig()).load("", addr, typ, 0)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
+	return v.format(printStructName), nil
+}
 
-	// Dereference pointers to get down the concrete type
-	for typeEntry, err := rdr.SeekToType(entry, true, false); typeEntry != nil; typeEntry, err = rdr.SeekToType(typeEntry, true, false) {
-		if err != nil {
-			return 0, err
+// load reads the value of type typ at addr. depth is the number of
+// structs the value is nested in.
+func (l *loader) load(name string, addr int64, typ dwarf.Type, depth int) (*Variable, error) {
+	var (
+		thread = l.thread
+		v      = &Variable{Name: name, Type: typeString(typ), Addr: uint64(addr), Kind: kindOf(typ), typ: typ, numFormat: l.cfg.Format}
+		key    = loadKey{addr, v.Type}
+		err    error
+	)
+	if l.loading[key] {
+		v.Unloaded = true
+		return v, nil
+	}
+	l.loading[key] = true
+	defer delete(l.loading, key)
+	l.setPrinter(v)
+
+	if t, ok := typ.(*dwarf.TypedefType); ok {
+		switch {
+		case strings.HasPrefix(t.Name, "map["):
+			return v, l.loadMap(v, t, depth)
+		case isChan(t):
+			return v, l.loadChan(v, t, depth)
+		case isInterface(t):
+			return v, l.loadInterface(v, t, depth)
 		}
+	}
 
-		if typeEntry.Tag != dwarf.TagPointerType {
-			break
+	ptraddress := uintptr(addr)
+	switch t := resolveTypedef(typ).(type) {
+	case *dwarf.PtrType:
+		p, err := thread.readUintRaw(ptraddress, int64(thread.dbp.arch.PtrSize()))
+		if err != nil {
+			return nil, err
 		}
-
-		ptraddress := uintptr(address)
-
-		ptr, err := thread.readMemory(ptraddress, thread.dbp.arch.PtrSize())
+		if isUnsafePointer(t) {
+			v.Value = formatUnsafePointer(p)
+			return v, nil
+		}
+		if p == 0 {
+			v.isnil = true
+			return v, nil
+		}
+		if l.pointees != nil {
+			key := loadKey{int64(p), typeString(t.Type)}
+			if l.pointees[key] {
+				v.Children = []*Variable{{Type: key.typ, Addr: p, Kind: kindOf(t.Type), typ: t.Type, Unloaded: true}}
+				return v, nil
+			}
+			l.pointees[key] = true
+		}
+		// Don't increase the recursion level when dereferencing pointers
+		child, err := l.load("", int64(p), t.Type, depth)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
-		address = int64(binary.LittleEndian.Uint64(ptr))
+		v.Children = []*Variable{child}
+	case *dwarf.StructType:
+		switch {
+		case t.StructName == "string":
+			var s string
+			s, v.Len, err = thread.readStringN(ptraddress, l.cfg.MaxStringLen)
+			v.Value = formatString(s, v.Len)
+		case strings.HasPrefix(t.StructName, "[]"):
+			err = l.loadSlice(v, t, depth)
+		default:
+			if depth > l.cfg.MaxVariableRecurse {
+				v.Unloaded = true
+				return v, nil
+			}
+			v.Children = make([]*Variable, 0, len(t.Field))
+			for _, field := range t.Field {
+				child, err := l.load(field.Name, field.ByteOffset+addr, field.Type, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				v.Children = append(v.Children, child)
+			}
+		}
+	case *dwarf.ArrayType:
+		v.Len, v.Cap = t.Count, t.Count
+		if t.Count > 0 {
+			v.Children, err = l.loadElements(addr, t.Count, t.ByteSize/t.Count, t.Type, depth)
+		}
+	case *dwarf.IntType:
+		var n int64
+		n, err = thread.readIntRaw(ptraddress, t.ByteSize)
+		v.Value = formatNumber(n, true, t, l.cfg.Format)
+	case *dwarf.UintType:
+		var n uint64
+		n, err = thread.readUintRaw(ptraddress, t.ByteSize)
+		v.Value = formatNumber(int64(n), false, t, l.cfg.Format)
+	case *dwarf.FloatType:
+		v.Value, err = thread.readFloat(ptraddress, t.ByteSize)
+	case *dwarf.ComplexType:
+		v.Value, err = thread.readComplex(ptraddress, t.ByteSize)
+	case *dwarf.BoolType:
+		v.Value, err = thread.readBool(ptraddress)
+	case *dwarf.FuncType:
+		err = l.loadFunc(v, depth)
+	case *dwarf.VoidType:
+		v.Value = "(void)"
+	case *dwarf.UnspecifiedType:
+		v.Value = "(unknown)"
+	default:
+		return nil, fmt.Errorf("could not find value for type %s", typ)
 	}
-
-	return address, nil
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
 }
 
-// Extracts the value from the instructions given in the DW_AT_location entry.
-// We execute the stack program described in the DW_OP_* instruction stream, and
-// then grab the value from the other processes memory.
-func (thread *Thread) extractValue(instructions []byte, addr int64, typ interface{}, printStructName bool) (string, error) {
-	return thread.extractValueInternal(instructions, addr, typ, printStructName, 0)
+// loadElements reads at most MaxArrayValues of the count elements of type
+// t, stride bytes apart, starting at addr. count is read from the process
+// and may be garbage.
+func (l *loader) loadElements(addr, count, stride int64, t dwarf.Type, depth int) ([]*Variable, error) {
+	switch {
+	case count < 0:
+		count = 0
+	case count > int64(l.cfg.MaxArrayValues):
+		count = int64(l.cfg.MaxArrayValues)
+	}
+	if stride > 0 && count > math.MaxInt64/stride {
+		return nil, fmt.Errorf("%d elements of %d bytes exceed the address space", count, stride)
+	}
+	if err := l.thread.checkReadable(uintptr(addr), count*stride); err != nil {
+		return nil, fmt.Errorf("could not read %d elements: %s", count, err)
+	}
+	// The elements are appended as they are read rather than preallocated,
+	// count can be as large as the limits of the LoadConfig.
+	capacity := count
+	if capacity > maxArrayValues {
+		capacity = maxArrayValues
+	}
+	elems := make([]*Variable, 0, capacity)
+	for i := int64(0); i < count; i++ {
+		elem, err := l.load("", addr+i*stride, t, depth)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, elem)
+	}
+	return elems, nil
 }
 
-func (thread *Thread) extractValueInternal(instructions []byte, addr int64, typ interface{}, printStructName bool, recurseLevel int) (string, error) {
-	var err error
-
-	if addr == 0 {
-		addr, err = thread.executeStackProgram(instructions)
+func (l *loader) loadSlice(v *Variable, t *dwarf.StructType, depth int) error {
+	var (
+		thread    = l.thread
+		ptrSize   = int64(thread.dbp.arch.PtrSize())
+		arrayAddr uint64
+		arrayType dwarf.Type
+		err       error
+	)
+	for _, f := range t.Field {
+		addr := uintptr(int64(v.Addr) + f.ByteOffset)
+		switch f.Name {
+		case "array":
+			arrayAddr, err = thread.readUintRaw(addr, ptrSize)
+			// Dereference array type to get value type
+			ptrType, ok := f.Type.(*dwarf.PtrType)
+			if !ok {
+				return fmt.Errorf("Invalid type %s in slice array", f.Type)
+			}
+			arrayType = ptrType.Type
+		case "len":
+			v.Len, err = thread.readIntRaw(addr, ptrSize)
+		case "cap":
+			v.Cap, err = thread.readIntRaw(addr, ptrSize)
+		}
 		if err != nil {
-			return "", err
+			return err
 		}
 	}
+	if arrayType == nil {
+		return fmt.Errorf("Invalid type %s in slice array", t)
+	}
+	v.Children, err = l.loadElements(int64(arrayAddr), v.Len, thread.stride(arrayType), arrayType, depth)
+	return err
+}
 
-	// If we have a user defined type, find the
-	// underlying concrete type and use that.
-	for {
-		if tt, ok := typ.(*dwarf.TypedefType); ok {
-			typ = tt.Type
-		} else {
-			break
-		}
+func (l *loader) loadMap(v *Variable, t *dwarf.TypedefType, depth int) error {
+	thread := l.thread
+	mt, err := newMapType(t)
+	if err != nil {
+		return err
+	}
+	hmap, err := thread.readUintRaw(uintptr(v.Addr), int64(thread.dbp.arch.PtrSize()))
+	if err != nil {
+		return err
+	}
+	if hmap == 0 {
+		v.isnil = true
+		return nil
+	}
+	if v.Len, err = thread.mapLen(hmap, mt); err != nil {
+		return err
 	}
 
-	ptraddress := uintptr(addr)
-	switch t := typ.(type) {
-	case *dwarf.PtrType:
-		ptr, err := thread.readMemory(ptraddress, thread.dbp.arch.PtrSize())
-		if err != nil {
-			return "", err
+	var loadErr error
+	v.Children = make([]*Variable, 0)
+	err = thread.mapEntries(hmap, mt, func(key, value uintptr) bool {
+		if len(v.Children)/2 >= l.cfg.MaxMapValues {
+			return false
 		}
-
-		intaddr := int64(binary.LittleEndian.Uint64(ptr))
-		if intaddr == 0 {
-			return fmt.Sprintf("%s nil", t.String()), nil
+		var k, val *Variable
+		if k, loadErr = l.load("", int64(key), mt.key, depth); loadErr != nil {
+			return false
 		}
+		if val, loadErr = l.load("", int64(value), mt.value, depth); loadErr != nil {
+			return false
+		}
+		v.Children = append(v.Children, k, val)
+		return true
+	})
+	if err == nil {
+		err = loadErr
+	}
+	return err
+}
 
-		// Don't increase the recursion level when dereferencing pointers
-		val, err := thread.extractValueInternal(nil, intaddr, t.Type, printStructName, recurseLevel)
+func (l *loader) loadChan(v *Variable, t *dwarf.TypedefType, depth int) error {
+	ch, err := l.thread.readChannel(uintptr(v.Addr), t, l.cfg.MaxArrayValues)
+	if err != nil {
+		return err
+	}
+	v.channel = ch
+	v.Len, v.Cap = ch.Len, ch.Cap
+	if ch.Addr == 0 {
+		v.isnil = true
+		return nil
+	}
+	v.Children = make([]*Variable, 0, len(ch.elemAddrs))
+	for _, addr := range ch.elemAddrs {
+		elem, err := l.load("", addr, ch.elemType, depth)
 		if err != nil {
-			return "", err
+			return err
 		}
+		v.Children = append(v.Children, elem)
+	}
+	return nil
+}
 
-		return fmt.Sprintf("*%s", val), nil
+func (l *loader) loadInterface(v *Variable, t *dwarf.TypedefType, depth int) error {
+	name, valAddr, dyntyp, err := l.thread.interfaceValue(uintptr(v.Addr), t)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		v.isnil = true
+		return nil
+	}
+	child, err := l.load(name, valAddr, dyntyp, depth)
+	if err != nil {
+		return err
+	}
+	v.Children = []*Variable{child}
+	return nil
+}
+
+// kindOf returns the kind of values of type typ.
+func kindOf(typ dwarf.Type) reflect.Kind {
+	if t, ok := typ.(*dwarf.TypedefType); ok {
+		switch {
+		case strings.HasPrefix(t.Name, "map["):
+			return reflect.Map
+		case isChan(t):
+			return reflect.Chan
+		case isInterface(t):
+			return reflect.Interface
+		}
+	}
+	switch t := resolveTypedef(typ).(type) {
+	case *dwarf.PtrType:
+		if isUnsafePointer(t) {
+			return reflect.UnsafePointer
+		}
+		return reflect.Ptr
 	case *dwarf.StructType:
 		switch {
 		case t.StructName == "string":
-			return thread.readString(ptraddress)
+			return reflect.String
 		case strings.HasPrefix(t.StructName, "[]"):
-			return thread.readSlice(ptraddress, t)
-		default:
-			// Recursively call extractValue to grab
-			// the value of all the members of the struct.
-			if recurseLevel <= maxVariableRecurse {
-				fields := make([]string, 0, len(t.Field))
-				for _, field := range t.Field {
-					val, err := thread.extractValueInternal(nil, field.ByteOffset+addr, field.Type, printStructName, recurseLevel+1)
-					if err != nil {
-						return "", err
-					}
-
-					fields = append(fields, fmt.Sprintf("%s: %s", field.Name, val))
-				}
-				if printStructName {
-					return fmt.Sprintf("%s {%s}", t.StructName, strings.Join(fields, ", ")), nil
-				}
-				return fmt.Sprintf("{%s}", strings.Join(fields, ", ")), nil
-			}
-			// no fields
-			if printStructName {
-				return fmt.Sprintf("%s {...}", t.StructName), nil
-			}
-			return "{...}", nil
+			return reflect.Slice
 		}
+		return reflect.Struct
 	case *dwarf.ArrayType:
-		return thread.readArray(ptraddress, t)
+		return reflect.Array
 	case *dwarf.IntType:
-		return thread.readInt(ptraddress, t.ByteSize)
+		switch {
+		case t.Name == "int":
+			return reflect.Int
+		case t.ByteSize == 1:
+			return reflect.Int8
+		case t.ByteSize == 2:
+			return reflect.Int16
+		case t.ByteSize == 4:
+			return reflect.Int32
+		}
+		return reflect.Int64
 	case *dwarf.UintType:
-		return thread.readUint(ptraddress, t.ByteSize)
+		switch {
+		case t.Name == "uint":
+			return reflect.Uint
+		case t.Name == "uintptr":
+			return reflect.Uintptr
+		case t.ByteSize == 1:
+			return reflect.Uint8
+		case t.ByteSize == 2:
+			return reflect.Uint16
+		case t.ByteSize == 4:
+			return reflect.Uint32
+		}
+		return reflect.Uint64
 	case *dwarf.FloatType:
-		return thread.readFloat(ptraddress, t.ByteSize)
+		if t.ByteSize == 4 {
+			return reflect.Float32
+		}
+		return reflect.Float64
+	case *dwarf.ComplexType:
+		if t.ByteSize == 8 {
+			return reflect.Complex64
+		}
+		return reflect.Complex128
 	case *dwarf.BoolType:
-		return thread.readBool(ptraddress)
+		return reflect.Bool
 	case *dwarf.FuncType:
-		return thread.readFunctionPtr(ptraddress)
-	case *dwarf.VoidType:
-		return "(void)", nil
-	case *dwarf.UnspecifiedType:
-		return "(unknown)", nil
-	default:
-		fmt.Printf("Unknown type: %T\n", t)
+		return reflect.Func
+	}
+	return reflect.Invalid
+}
+
+// setValues renders the value of v if it isn't of a basic type, whose
+// values are read as they are loaded. The children of v are rendered in
+// its value only, rendering them at every level would cost as much as the
+// square of the depth of v.
+func (v *Variable) setValues() {
+	v.print()
+	if v.printed {
+		return
+	}
+	switch v.Kind {
+	case reflect.Ptr, reflect.Struct, reflect.Array, reflect.Slice, reflect.Map, reflect.Chan, reflect.Interface, reflect.Func:
+		v.Value = v.format(true)
+	}
+}
+
+// print renders v and its children with the pretty-printers of their
+// types.
+func (v *Variable) print() {
+	for _, child := range v.Children {
+		child.print()
+	}
+	if v.printer != nil {
+		if s, err := v.printer(); err == nil {
+			v.Value, v.printed = s, true
+		}
+	}
+}
+
+// format renders the value of v on a single line. Structs are prefixed by
+// the name of their type if printStructName is set.
+func (v *Variable) format(printStructName bool) string {
+	if v.printed {
+		return v.Value
+	}
+	if v.Unloaded && v.Kind != reflect.Struct && v.Kind != reflect.Func {
+		return "..."
+	}
+	// more renders the number of elements that weren't loaded.
+	more := func(vals []string, loaded int) []string {
+		if int64(loaded) < v.Len {
+			vals = append(vals, fmt.Sprintf("...+%d more", v.Len-int64(loaded)))
+		}
+		return vals
+	}
+	elems := func(children []*Variable, printStructName bool) []string {
+		vals := make([]string, 0, len(children)+1)
+		for _, child := range children {
+			vals = append(vals, child.format(printStructName))
+		}
+		return vals
+	}
+
+	switch v.Kind {
+	case reflect.Ptr:
+		if v.isnil {
+			return fmt.Sprintf("%s nil", resolveTypedef(v.typ))
+		}
+		return "*" + v.Children[0].format(printStructName)
+	case reflect.Struct:
+		structName := resolveTypedef(v.typ).(*dwarf.StructType).StructName
+		if isAnonymousStruct(structName) {
+			// The fields already spell out the type.
+			printStructName = false
+		}
+		if v.Unloaded {
+			if printStructName {
+				return fmt.Sprintf("%s {...}", structName)
+			}
+			return "{...}"
+		}
+		fields := make([]string, 0, len(v.Children))
+		for _, field := range v.Children {
+			fields = append(fields, fmt.Sprintf("%s: %s", field.Name, field.format(printStructName)))
+		}
+		if printStructName {
+			return fmt.Sprintf("%s {%s}", structName, strings.Join(fields, ", "))
+		}
+		return fmt.Sprintf("{%s}", strings.Join(fields, ", "))
+	case reflect.Array:
+		t := resolveTypedef(v.typ)
+		if v.Len == 0 {
+			return fmt.Sprintf("%s []", t)
+		}
+		if et, ok := resolveTypedef(t.(*dwarf.ArrayType).Type).(*dwarf.UintType); ok && et.ByteSize == 1 && v.numFormat == FormatString {
+			return fmt.Sprintf("%s %s", t, strings.Join(more([]string{formatBytesString(v.Children)}, len(v.Children)), ""))
+		}
+		return fmt.Sprintf("%s [%s]", t, strings.Join(more(elems(v.Children, false), len(v.Children)), ","))
+	case reflect.Slice:
+		var elemType dwarf.Type
+		for _, f := range resolveTypedef(v.typ).(*dwarf.StructType).Field {
+			if ptr, ok := f.Type.(*dwarf.PtrType); ok && f.Name == "array" {
+				elemType = ptr.Type
+			}
+		}
+		if t, ok := resolveTypedef(elemType).(*dwarf.UintType); ok && t.ByteSize == 1 {
+			switch v.numFormat {
+			case FormatDefault:
+				return fmt.Sprintf("[]%s len: %d, cap: %d, %s", elemType, v.Len, v.Cap, strings.Join(more([]string{formatBytes(v.Children)}, len(v.Children)), ""))
+			case FormatString:
+				return fmt.Sprintf("[]%s len: %d, cap: %d, %s", elemType, v.Len, v.Cap, strings.Join(more([]string{formatBytesString(v.Children)}, len(v.Children)), ""))
+			}
+		}
+		vals := more(elems(v.Children, false), len(v.Children))
+		return fmt.Sprintf("[]%s len: %d, cap: %d, [%s]", elemType, v.Len, v.Cap, strings.Join(vals, ","))
+	case reflect.Map:
+		name := v.typ.(*dwarf.TypedefType).Name
+		if v.isnil {
+			return fmt.Sprintf("%s nil", name)
+		}
+		entries := make([]string, 0, len(v.Children)/2+1)
+		for i := 0; i+1 < len(v.Children); i += 2 {
+			entries = append(entries, fmt.Sprintf("%s: %s", v.Children[i].format(false), v.Children[i+1].format(false)))
+		}
+		entries = more(entries, len(entries))
+		return fmt.Sprintf("%s len: %d, [%s]", name, v.Len, strings.Join(entries, ", "))
+	case reflect.Chan:
+		if v.isnil {
+			return fmt.Sprintf("%s nil", v.channel.Type)
+		}
+		vals := more(elems(v.Children, true), len(v.Children))
+		s := fmt.Sprintf("%s len: %d, cap: %d, [%s]", v.channel.Type, v.Len, v.Cap, strings.Join(vals, ","))
+		if v.channel.Closed {
+			s += ", closed"
+		}
+		if len(v.channel.RecvWaiters) > 0 {
+			s += fmt.Sprintf(", recvq: %v", v.channel.RecvWaiters)
+		}
+		if len(v.channel.SendWaiters) > 0 {
+			s += fmt.Sprintf(", sendq: %v", v.channel.SendWaiters)
+		}
+		return s
+	case reflect.Func:
+		switch {
+		case v.isnil || v.fn == "":
+			return v.Value
+		case v.Unloaded:
+			return v.fn + " {...}"
+		case len(v.Children) == 0:
+			return v.fn
+		}
+		captured := make([]string, 0, len(v.Children))
+		for _, cv := range v.Children {
+			captured = append(captured, fmt.Sprintf("%s: %s", cv.Name, cv.format(printStructName)))
+		}
+		return fmt.Sprintf("%s {%s}", v.fn, strings.Join(captured, ", "))
+	case reflect.Interface:
+		name := v.typ.(*dwarf.TypedefType).Name
+		if v.isnil {
+			return fmt.Sprintf("%s nil", name)
+		}
+		return fmt.Sprintf("%s(%s) %s", name, v.Children[0].Name, v.Children[0].format(false))
+	}
+	return v.Value
+}
+
+// typeString returns the name of typ as reported in the Type of variables.
+func typeString(typ dwarf.Type) string {
+	switch t := typ.(type) {
+	case *dwarf.PtrType:
+		if isUnsafePointer(t) {
+			// debug/dwarf doesn't keep the names of pointer types.
+			return "unsafe.Pointer"
+		}
+	case *dwarf.StructType:
+		if isAnonymousStruct(t.StructName) {
+			return t.StructName
+		}
+	}
+	return typ.String()
+}
+
+// isUnsafePointer returns whether t is unsafe.Pointer, which DWARF
+// describes as a pointer to nothing.
+func isUnsafePointer(t *dwarf.PtrType) bool {
+	_, ok := t.Type.(*dwarf.VoidType)
+	return ok
+}
+
+// isAnonymousStruct returns whether name is the name of a struct type
+// literal, as in struct { A int }.
+func isAnonymousStruct(name string) bool {
+	return strings.HasPrefix(name, "struct {") || name == "struct {}"
+}
+
+func formatUnsafePointer(p uint64) string {
+	if p == 0 {
+		return "unsafe.Pointer(nil)"
+	}
+	return fmt.Sprintf("unsafe.Pointer(%#x)", p)
+}
+
+// formatInt renders n of type t. Values of the rune type are followed by
+// their character when it's printable. Compilers describe runes as int32,
+// use the c format to print other integers as characters.
+func formatInt(n int64, t *dwarf.IntType) string {
+	s := strconv.FormatInt(n, 10)
+	if t != nil && t.Name == "rune" && n >= 0 && n <= unicode.MaxRune && unicode.IsPrint(rune(n)) {
+		s += " " + strconv.QuoteRune(rune(n))
+	}
+	return s
+}
+
+func formatComplex(re, im float64, bitSize int) string {
+	r := strconv.FormatFloat(re, 'f', -1, bitSize)
+	i := strconv.FormatFloat(im, 'f', -1, bitSize)
+	if i[0] != '-' && i[0] != '+' {
+		i = "+" + i
+	}
+	return "(" + r + i + "i)"
+}
+
+// formatString renders the first bytes s of a string of length n, with
+// non-printable characters, invalid UTF-8, backslashes and double quotes
+// escaped as in Go literals.
+func formatString(s string, n int64) string {
+	if int64(len(s)) < n {
+		// Don't split the last character.
+		for i := len(s) - 1; i >= 0 && i >= len(s)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(s[i]) {
+				if !utf8.FullRuneInString(s[i:]) {
+					s = s[:i]
+				}
+				break
+			}
+		}
+	}
+	var buf bytes.Buffer
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&buf, "\\x%02x", s[i])
+		case r == '\\' || r == '"':
+			// Escaped so that the value reads back unambiguously.
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case unicode.IsPrint(r):
+			buf.WriteString(s[i : i+size])
+		default:
+			q := strconv.QuoteRune(r)
+			buf.WriteString(q[1 : len(q)-1])
+		}
+		i += size
+	}
+	if int64(len(s)) < n {
+		fmt.Fprintf(&buf, "...+%d more", n-int64(len(s)))
 	}
+	return buf.String()
+}
 
-	return "", fmt.Errorf("could not find value for type %s", typ)
+// formatBytes renders the elements of a byte slice as a quoted string if
+// they are valid UTF-8, in hexadecimal otherwise.
+func formatBytes(elems []*Variable) string {
+	b := make([]byte, 0, len(elems))
+	for _, elem := range elems {
+		n, _ := strconv.ParseUint(elem.Value, 10, 8)
+		b = append(b, byte(n))
+	}
+	if utf8.Valid(b) {
+		return strconv.Quote(string(b))
+	}
+	return fmt.Sprintf("%#x", b)
+}
+
+// formatBytesString renders the elements of a byte slice as a quoted
+// string, escaping invalid UTF-8.
+func formatBytesString(elems []*Variable) string {
+	b := make([]byte, 0, len(elems))
+	for _, elem := range elems {
+		n, _ := strconv.ParseUint(elem.Value, 10, 8)
+		b = append(b, byte(n))
+	}
+	return strconv.Quote(string(b))
 }
 
 func (thread *Thread) readString(addr uintptr) (string, error) {
+	s, _, err := thread.readStringN(addr, -1)
+	return s, err
+}
+
+// readStringN reads at most max bytes of the string at addr, all of them
+// if max is negative. It also returns the length of the string.
+func (thread *Thread) readStringN(addr uintptr, max int) (string, int64, error) {
 	// string data structure is always two ptrs in size. Addr, followed by len
 	// http://research.swtch.com/godata
 
 	// read len
 	val, err := thread.readMemory(addr+uintptr(thread.dbp.arch.PtrSize()), thread.dbp.arch.PtrSize())
 	if err != nil {
-		return "", fmt.Errorf("could not read string len %s", err)
+		return "", 0, fmt.Errorf("could not read string len %s", err)
+	}
+	strlen := int64(binary.LittleEndian.Uint64(val))
+	if strlen < 0 {
+		return "", 0, fmt.Errorf("invalid string length %d", strlen)
 	}
-	strlen := int(binary.LittleEndian.Uint64(val))
 
 	// read addr
 	val, err = thread.readMemory(addr, thread.dbp.arch.PtrSize())
 	if err != nil {
-		return "", fmt.Errorf("could not read string pointer %s", err)
+		return "", 0, fmt.Errorf("could not read string pointer %s", err)
 	}
 	addr = uintptr(binary.LittleEndian.Uint64(val))
 	if addr == 0 {
-		return "", nil
+		return "", 0, nil
 	}
 
-	val, err = thread.readMemory(addr, strlen)
+	count := strlen
+	if max >= 0 && count > int64(max) {
+		count = int64(max)
+	}
+	if err := thread.checkReadable(addr, count); err != nil {
+		return "", 0, fmt.Errorf("could not read string of length %d: %s", strlen, err)
+	}
+	val, err = thread.readMemory(addr, int(count))
 	if err != nil {
-		return "", fmt.Errorf("could not read string at %#v due to %s", addr, err)
+		return "", 0, fmt.Errorf("could not read string at %#v due to %s", addr, err)
 	}
 
-	return *(*string)(unsafe.Pointer(&val)), nil
+	return *(*string)(unsafe.Pointer(&val)), strlen, nil
 }
 
-func (thread *Thread) readSlice(addr uintptr, t *dwarf.StructType) (string, error) {
-	var sliceLen, sliceCap int64
-	var arrayAddr uintptr
-	var arrayType dwarf.Type
-	for _, f := range t.Field {
+// Map bucket layout constants, see runtime/hashmap.go.
+const (
+	bucketCnt    = 8
+	minTopHash   = 4 // Smaller tophash values mark empty or evacuated cells.
+	sameSizeGrow = 8 // hmap flag set while growing to a map of the same size.
+)
+
+// readStringMap reads the map[string]string whose runtime hmap
+// structure is at addr.
+func (thread *Thread) readStringMap(addr uint64) (map[string]string, error) {
+	m := map[string]string{}
+	if addr == 0 {
+		return m, nil
+	}
+	typ, err := thread.dbp.typeNamed("map[string]string")
+	if err != nil {
+		return nil, err
+	}
+	t, ok := typ.(*dwarf.TypedefType)
+	if !ok {
+		return nil, fmt.Errorf("unexpected representation of map[string]string")
+	}
+	mt, err := newMapType(t)
+	if err != nil {
+		return nil, err
+	}
+	var rerr error
+	err = thread.mapEntries(addr, mt, func(key, value uintptr) bool {
+		var k, v string
+		if k, rerr = thread.readString(key); rerr != nil {
+			return false
+		}
+		if v, rerr = thread.readString(value); rerr != nil {
+			return false
+		}
+		m[k] = v
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, rerr
+}
+
+// mapType is the runtime layout of a map type: the DWARF type of a map is
+// a pointer to a hash<K,V> struct, a copy of runtime.hmap whose buckets
+// are bucket<K,V> structs.
+type mapType struct {
+	name                   string
+	key, value             dwarf.Type
+	keySize, valueSize     int64
+	countOff, bOff         int64
+	flagsOff, bucketsOff   int64
+	oldbucketsOff          int64
+	keysOff, valuesOff     int64
+	overflowOff, bucketLen int64
+}
+
+func newMapType(t *dwarf.TypedefType) (*mapType, error) {
+	ptr, ok := t.Type.(*dwarf.PtrType)
+	if !ok {
+		return nil, fmt.Errorf("unexpected representation of %s", t.Name)
+	}
+	hmap, ok := ptr.Type.(*dwarf.StructType)
+	if !ok {
+		return nil, fmt.Errorf("unexpected representation of %s", t.Name)
+	}
+	mt := &mapType{name: t.Name}
+	var bucket *dwarf.StructType
+	for _, f := range hmap.Field {
 		switch f.Name {
-		case "array":
-			val, err := thread.readMemory(addr+uintptr(f.ByteOffset), thread.dbp.arch.PtrSize())
-			if err != nil {
-				return "", err
-			}
-			arrayAddr = uintptr(binary.LittleEndian.Uint64(val))
-			// Dereference array type to get value type
-			ptrType, ok := f.Type.(*dwarf.PtrType)
-			if !ok {
-				return "", fmt.Errorf("Invalid type %s in slice array", f.Type)
-			}
-			arrayType = ptrType.Type
-		case "len":
-			lstr, err := thread.extractValue(nil, int64(addr+uintptr(f.ByteOffset)), f.Type, true)
-			if err != nil {
-				return "", err
+		case "count":
+			mt.countOff = f.ByteOffset
+		case "B":
+			mt.bOff = f.ByteOffset
+		case "flags":
+			mt.flagsOff = f.ByteOffset
+		case "buckets":
+			mt.bucketsOff = f.ByteOffset
+			if p, ok := f.Type.(*dwarf.PtrType); ok {
+				bucket, _ = p.Type.(*dwarf.StructType)
 			}
-			sliceLen, err = strconv.ParseInt(lstr, 10, 64)
-			if err != nil {
-				return "", err
-			}
-		case "cap":
-			cstr, err := thread.extractValue(nil, int64(addr+uintptr(f.ByteOffset)), f.Type, true)
-			if err != nil {
-				return "", err
+		case "oldbuckets":
+			mt.oldbucketsOff = f.ByteOffset
+		}
+	}
+	if bucket == nil {
+		return nil, fmt.Errorf("unexpected representation of %s", t.Name)
+	}
+	mt.bucketLen = bucket.ByteSize
+	for _, f := range bucket.Field {
+		switch f.Name {
+		case "keys", "values":
+			arr, ok := f.Type.(*dwarf.ArrayType)
+			if !ok || arr.Count != bucketCnt {
+				return nil, fmt.Errorf("unexpected representation of %s", t.Name)
 			}
-			sliceCap, err = strconv.ParseInt(cstr, 10, 64)
-			if err != nil {
-				return "", err
+			if f.Name == "keys" {
+				mt.key, mt.keySize, mt.keysOff = arr.Type, arr.ByteSize/bucketCnt, f.ByteOffset
+			} else {
+				mt.value, mt.valueSize, mt.valuesOff = arr.Type, arr.ByteSize/bucketCnt, f.ByteOffset
 			}
+		case "overflow":
+			mt.overflowOff = f.ByteOffset
 		}
 	}
+	if mt.key == nil || mt.value == nil {
+		return nil, fmt.Errorf("unexpected representation of %s", t.Name)
+	}
+	return mt, nil
+}
+
+// mapLen returns the number of entries of the map whose hmap is at hmap.
+func (thread *Thread) mapLen(hmap uint64, mt *mapType) (int64, error) {
+	if hmap == 0 {
+		return 0, nil
+	}
+	return thread.readIntRaw(uintptr(hmap+uint64(mt.countOff)), int64(thread.dbp.arch.PtrSize()))
+}
 
-	stride := arrayType.Size()
-	if _, ok := arrayType.(*dwarf.PtrType); ok {
-		stride = int64(thread.dbp.arch.PtrSize())
+// mapEntries calls visit with the address of the key and value of the
+// entries of the map whose hmap is at hmap, until visit returns false.
+// Entries are visited in bucket order, including the ones of the old
+// buckets not evacuated yet when the map is growing.
+func (thread *Thread) mapEntries(hmap uint64, mt *mapType, visit func(key, value uintptr) bool) error {
+	if hmap == 0 {
+		return nil
 	}
-	vals, err := thread.readArrayValues(arrayAddr, sliceLen, stride, arrayType)
+	ptrSize := int64(thread.dbp.arch.PtrSize())
+	b, err := thread.readUintRaw(uintptr(hmap+uint64(mt.bOff)), 1)
 	if err != nil {
-		return "", err
+		return err
+	}
+	flags, err := thread.readUintRaw(uintptr(hmap+uint64(mt.flagsOff)), 1)
+	if err != nil {
+		return err
+	}
+	buckets, err := thread.readUintRaw(uintptr(hmap+uint64(mt.bucketsOff)), ptrSize)
+	if err != nil {
+		return err
+	}
+	oldbuckets, err := thread.readUintRaw(uintptr(hmap+uint64(mt.oldbucketsOff)), ptrSize)
+	if err != nil {
+		return err
 	}
 
-	return fmt.Sprintf("[]%s len: %d, cap: %d, [%s]", arrayType, sliceLen, sliceCap, strings.Join(vals, ",")), nil
-}
+	visitBuckets := func(addr, n uint64) (bool, error) {
+		for i := uint64(0); i < n; i++ {
+			for bucket := addr + i*uint64(mt.bucketLen); bucket != 0; {
+				tophash, err := thread.readMemory(uintptr(bucket), bucketCnt)
+				if err != nil {
+					return false, err
+				}
+				for j := uint64(0); j < bucketCnt; j++ {
+					if tophash[j] < minTopHash {
+						continue
+					}
+					key := uintptr(bucket + uint64(mt.keysOff) + j*uint64(mt.keySize))
+					value := uintptr(bucket + uint64(mt.valuesOff) + j*uint64(mt.valueSize))
+					if !visit(key, value) {
+						return false, nil
+					}
+				}
+				if bucket, err = thread.readUintRaw(uintptr(bucket+uint64(mt.overflowOff)), ptrSize); err != nil {
+					return false, err
+				}
+			}
+		}
+		return true, nil
+	}
 
-func (thread *Thread) readArray(addr uintptr, t *dwarf.ArrayType) (string, error) {
-	if t.Count > 0 {
-		vals, err := thread.readArrayValues(addr, t.Count, t.ByteSize/t.Count, t.Type)
-		if err != nil {
-			return "", err
+	if buckets != 0 {
+		if more, err := visitBuckets(buckets, 1<<b); !more || err != nil {
+			return err
+		}
+	}
+	if oldbuckets != 0 {
+		// Cells not evacuated yet are only found in the old buckets.
+		n := uint64(1) << b
+		if flags&sameSizeGrow == 0 {
+			n >>= 1
 		}
-		return fmt.Sprintf("%s [%s]", t, strings.Join(vals, ",")), nil
+		_, err = visitBuckets(oldbuckets, n)
 	}
-	// because you can declare a zero-size array
-	return fmt.Sprintf("%s []", t), nil
+	return err
 }
 
-func (thread *Thread) readArrayValues(addr uintptr, count int64, stride int64, t dwarf.Type) ([]string, error) {
-	vals := make([]string, 0)
+// Flag of runtime._type.kind set when the value of an interface holding
+// the type is stored in the data word itself, see runtime/typekind.go.
+const kindDirectIface = 1 << 5
 
-	for i := int64(0); i < count; i++ {
-		// Cap number of elements
-		if i >= maxArrayValues {
-			vals = append(vals, fmt.Sprintf("...+%d more", count-maxArrayValues))
-			break
+// isInterface returns whether t is an interface type, represented by a
+// runtime.iface struct, or runtime.eface for the empty interface.
+func isInterface(t *dwarf.TypedefType) bool {
+	st, ok := t.Type.(*dwarf.StructType)
+	return ok && (st.StructName == "runtime.iface" || st.StructName == "runtime.eface")
+}
+
+// interfaceValue returns the name of the dynamic type of the interface of
+// type t at addr, along with the address and DWARF type of its value. The
+// name is empty for nil interfaces.
+func (thread *Thread) interfaceValue(addr uintptr, t *dwarf.TypedefType) (string, int64, dwarf.Type, error) {
+	var (
+		st      = t.Type.(*dwarf.StructType)
+		ptrSize = int64(thread.dbp.arch.PtrSize())
+		typeOff int64
+		dataOff int64
+		itab    bool
+	)
+	for _, f := range st.Field {
+		switch f.Name {
+		case "tab":
+			typeOff, itab = f.ByteOffset, true
+		case "_type", "type":
+			typeOff = f.ByteOffset
+		case "data":
+			dataOff = f.ByteOffset
 		}
+	}
+	rtype, err := thread.readUintRaw(addr+uintptr(typeOff), ptrSize)
+	if err != nil || rtype == 0 {
+		return "", 0, nil, err
+	}
+	if itab {
+		itabtyp, err := thread.dbp.structTypeNamed("runtime.itab")
+		if err != nil {
+			return "", 0, nil, err
+		}
+		typeAddr, _, err := memberAtAny(itabtyp, rtype, "_type", "type")
+		if err != nil {
+			return "", 0, nil, err
+		}
+		if rtype, err = thread.readUintRaw(uintptr(typeAddr), ptrSize); err != nil {
+			return "", 0, nil, err
+		}
+	}
+
+	rtypetyp, err := thread.dbp.structTypeNamed("runtime._type")
+	if err != nil {
+		return "", 0, nil, err
+	}
+	strMember, _, err := memberAtAny(rtypetyp, rtype, "_string", "string")
+	if err != nil {
+		return "", 0, nil, err
+	}
+	kindMember, _, err := memberAt(rtypetyp, rtype, "kind")
+	if err != nil {
+		return "", 0, nil, err
+	}
+	strAddr, err := thread.readUintRaw(uintptr(strMember), ptrSize)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	name, err := thread.readString(uintptr(strAddr))
+	if err != nil {
+		return "", 0, nil, err
+	}
+	kind, err := thread.readUintRaw(uintptr(kindMember), 1)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	dyntyp, err := thread.dbp.typeNamed(name)
+	if err != nil {
+		return "", 0, nil, err
+	}
 
-		val, err := thread.extractValue(nil, int64(addr+uintptr(i*stride)), t, false)
+	valAddr := int64(addr) + dataOff
+	if kind&kindDirectIface == 0 {
+		data, err := thread.readUintRaw(uintptr(valAddr), ptrSize)
 		if err != nil {
-			return nil, err
+			return "", 0, nil, err
 		}
-		vals = append(vals, val)
+		valAddr = int64(data)
 	}
-	return vals, nil
+	return name, valAddr, dyntyp, nil
 }
 
-func (thread *Thread) readInt(addr uintptr, size int64) (string, error) {
-	n, err := thread.readIntRaw(addr, size)
-	if err != nil {
-		return "", err
+// stride returns the distance between consecutive elements of type t in
+// arrays and slices.
+func (thread *Thread) stride(t dwarf.Type) int64 {
+	if _, ok := t.(*dwarf.PtrType); ok {
+		return int64(thread.dbp.arch.PtrSize())
 	}
-	return strconv.FormatInt(n, 10), nil
+	return t.Size()
 }
 
 func (thread *Thread) readIntRaw(addr uintptr, size int64) (int64, error) {
@@ -676,14 +1574,6 @@ func (thread *Thread) readIntRaw(addr uintptr, size int64) (int64, error) {
 	return n, nil
 }
 
-func (thread *Thread) readUint(addr uintptr, size int64) (string, error) {
-	n, err := thread.readUintRaw(addr, size)
-	if err != nil {
-		return "", err
-	}
-	return strconv.FormatUint(n, 10), nil
-}
-
 func (thread *Thread) readUintRaw(addr uintptr, size int64) (uint64, error) {
 	var n uint64
 
@@ -727,49 +1617,46 @@ func (thread *Thread) readFloat(addr uintptr, size int64) (string, error) {
 	return "", fmt.Errorf("could not read float")
 }
 
-func (thread *Thread) readBool(addr uintptr) (string, error) {
-	val, err := thread.readMemory(addr, 1)
+func (thread *Thread) readComplex(addr uintptr, size int64) (string, error) {
+	val, err := thread.readMemory(addr, int(size))
 	if err != nil {
 		return "", err
 	}
 
-	if val[0] == 0 {
-		return "false", nil
+	switch size {
+	case 8:
+		re := math.Float32frombits(binary.LittleEndian.Uint32(val))
+		im := math.Float32frombits(binary.LittleEndian.Uint32(val[4:]))
+		return formatComplex(float64(re), float64(im), 32), nil
+	case 16:
+		re := math.Float64frombits(binary.LittleEndian.Uint64(val))
+		im := math.Float64frombits(binary.LittleEndian.Uint64(val[8:]))
+		return formatComplex(re, im, 64), nil
 	}
 
-	return "true", nil
+	return "", fmt.Errorf("could not read complex")
 }
 
-func (thread *Thread) readFunctionPtr(addr uintptr) (string, error) {
-	val, err := thread.readMemory(addr, thread.dbp.arch.PtrSize())
-	if err != nil {
-		return "", err
-	}
-
-	// dereference pointer to find function pc
-	addr = uintptr(binary.LittleEndian.Uint64(val))
-	if addr == 0 {
-		return "nil", nil
-	}
-
-	val, err = thread.readMemory(addr, thread.dbp.arch.PtrSize())
+func (thread *Thread) readBool(addr uintptr) (string, error) {
+	val, err := thread.readMemory(addr, 1)
 	if err != nil {
 		return "", err
 	}
 
-	funcAddr := binary.LittleEndian.Uint64(val)
-	fn := thread.dbp.goSymTable.PCToFunc(uint64(funcAddr))
-	if fn == nil {
-		return "", fmt.Errorf("could not find function for %#v", funcAddr)
+	if val[0] == 0 {
+		return "false", nil
 	}
 
-	return fn.Name, nil
+	return "true", nil
 }
 
 func (thread *Thread) readMemory(addr uintptr, size int) ([]byte, error) {
 	if size == 0 {
 		return nil, nil
 	}
+	if isFakeAddress(int64(addr)) {
+		return thread.dbp.readCompositeMemory(addr, size)
+	}
 
 	buf := make([]byte, size)
 	_, err := readMemory(thread, addr, buf)
@@ -779,37 +1666,64 @@ func (thread *Thread) readMemory(addr uintptr, size int) ([]byte, error) {
 	return buf, nil
 }
 
-// Fetches all variables of a specific type in the current function scope
-func (thread *Thread) variablesByTag(tag dwarf.Tag) ([]*Variable, error) {
-	pc, err := thread.PC()
-	if err != nil {
-		return nil, err
+// checkReadable returns an error if the size bytes at addr, whose size was
+// read from the process and may be garbage, aren't all mapped. Only the
+// last byte is read, before allocating anything for them.
+func (thread *Thread) checkReadable(addr uintptr, size int64) error {
+	if size <= 0 {
+		return nil
 	}
+	end := uint64(addr) + uint64(size) - 1
+	if end < uint64(addr) || uint64(uintptr(end)) != end {
+		return fmt.Errorf("%d bytes at %#x exceed the address space", size, addr)
+	}
+	if _, err := thread.readMemory(uintptr(end), 1); err != nil {
+		return fmt.Errorf("%d bytes at %#x exceed the readable memory", size, addr)
+	}
+	return nil
+}
 
-	reader := thread.dbp.DwarfReader()
-
-	_, err = reader.SeekToFunction(pc)
+// Fetches all variables of a specific type in the current function scope
+func (scope *EvalScope) variablesByTag(tag dwarf.Tag) ([]*Variable, error) {
+	entries, err := scope.scopeVariables()
 	if err != nil {
 		return nil, err
 	}
 
 	vars := make([]*Variable, 0)
 
-	for entry, err := reader.NextScopeVariable(); entry != nil; entry, err = reader.NextScopeVariable() {
+	for i, entry := range entries {
+		if entry.Tag != tag {
+			continue
+		}
+		val, err := scope.extractVariableFromEntry(entry.Entry)
 		if err != nil {
-			return nil, err
+			// skip variables that we can't parse yet
+			continue
 		}
+		val.Shadowed = innermostVariable(entries, val.Name) != i
+		vars = append(vars, val)
+	}
 
-		if entry.Tag == tag {
-			val, err := thread.extractVariableFromEntry(entry)
-			if err != nil {
-				// skip variables that we can't parse yet
-				continue
-			}
+	return vars, nil
+}
 
-			vars = append(vars, val)
+// scopeVariables returns the variables and arguments of the function of
+// the scope which are in scope at its PC.
+func (scope *EvalScope) scopeVariables() ([]reader.Variable, error) {
+	_, line, _ := scope.Thread.dbp.goSymTable.PCToLine(scope.PC)
+	return reader.Variables(scope.Thread.dbp.dwarf, scope.PC, line)
+}
+
+// innermostVariable returns the index in vars of the variable named name
+// declared in the innermost lexical block, -1 if there is none. Among
+// variables of the same block the last one declared wins.
+func innermostVariable(vars []reader.Variable, name string) int {
+	idx := -1
+	for i, v := range vars {
+		if n, _ := v.Val(dwarf.AttrName).(string); n == name && (idx < 0 || v.Depth >= vars[idx].Depth) {
+			idx = i
 		}
 	}
-
-	return vars, nil
+	return idx
 }
@@ -1,10 +1,16 @@
 package proc
 
 import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
+	"github.com/derekparker/delve/dwarf/op"
 	protest "github.com/derekparker/delve/proc/test"
 )
 
@@ -15,6 +21,13 @@ type varTest struct {
 	err     error
 }
 
+// atFixture fills in the path of the fixture in expected values which
+// include source locations, where they are written {{fixture}}.
+func (tc varTest) atFixture(fixture protest.Fixture) varTest {
+	tc.value = strings.Replace(tc.value, "{{fixture}}", fixture.Source, -1)
+	return tc
+}
+
 func assertVariable(t *testing.T, variable *Variable, expected varTest) {
 	if variable.Name != expected.name {
 		t.Fatalf("Expected %s got %s\n", expected.name, variable.Name)
@@ -61,7 +74,7 @@ func TestVariableEvaluation(t *testing.T) {
 		{"u64", "18446744073709551615", "uint64", nil},
 		{"u8", "255", "uint8", nil},
 		{"up", "5", "uintptr", nil},
-		{"f", "main.barfoo", "func()", nil},
+		{"f", "main.barfoo at {{fixture}}:21", "func()", nil},
 		{"ba", "[]int len: 200, cap: 200, [0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,...+136 more]", "struct []int", nil},
 		{"ms", "main.Nest {Level: 0, Nest: *main.Nest {Level: 1, Nest: *main.Nest {...}}}", "main.Nest", nil},
 		{"NonExistent", "", "", fmt.Errorf("could not find symbol value for NonExistent")},
@@ -80,7 +93,7 @@ func TestVariableEvaluation(t *testing.T) {
 			variable, err := p.EvalVariable(tc.name)
 			if tc.err == nil {
 				assertNoError(err, t, "EvalVariable() returned an error")
-				assertVariable(t, variable, tc)
+				assertVariable(t, variable, tc.atFixture(fixture))
 			} else {
 				if tc.err.Error() != err.Error() {
 					t.Fatalf("Unexpected error. Expected %s got %s", tc.err.Error(), err.Error())
@@ -90,6 +103,320 @@ func TestVariableEvaluation(t *testing.T) {
 	})
 }
 
+func TestExpressionEvaluation(t *testing.T) {
+	testcases := []varTest{
+		{"a2 + 4", "10", "int", nil},
+		{"a2*a2 - 1", "35", "int", nil},
+		{"-a2", "-6", "int", nil},
+		{"a3 / 2", "3.615", "float64", nil},
+		{"a2 == 6 && !b2", "true", "bool", nil},
+		{"a2 > 10 || b1", "true", "bool", nil},
+		{"u8 + 1", "0", "uint8", nil},
+		{"float64(a2) / 4", "1.5", "float64", nil},
+		{"int8(a2)", "6", "int8", nil},
+		{"a1 + \"!\"", "foofoofoofoofoofoo!", "struct string", nil},
+		{"a1 == \"foofoofoofoofoofoo\"", "true", "bool", nil},
+		{"a4[1]", "2", "int", nil},
+		{"a5[a2-2]", "5", "int", nil},
+		{"a5[1:3]", "[]int len: 2, cap: 4, [2,3]", "struct []int", nil},
+		{"a4[:1]", "[]int len: 1, cap: 2, [1]", "struct []int", nil},
+		{"a1[1:4]", "oof", "struct string", nil},
+		{"a1[0]", "102", "uint8", nil},
+		{"a11[1].Bur", "b", "struct string", nil},
+		{"a13[2].Baz", "8", "int", nil},
+		{"*a7", "main.FooBar {Baz: 5, Bur: strum}", "main.FooBar", nil},
+		{"(*a7).Bur", "strum", "struct string", nil},
+		{"&a6", "*main.FooBar {Baz: 8, Bur: word}", "*main.FooBar", nil},
+		{"(&a6).Baz", "8", "int", nil},
+		{"ms.Nest.Nest.Level", "2", "int", nil},
+		{"a9 == nil", "true", "bool", nil},
+		{"a7 != nil", "true", "bool", nil},
+		{"len(a5)", "5", "int", nil},
+		{"cap(a5[2:])", "3", "int", nil},
+		{"len(a1)", "18", "int", nil},
+		{"len(a4)", "2", "int", nil},
+		{"packageVar.Baz", "42", "int", nil},
+		{"main.packageVar.Bur", "package", "struct string", nil},
+		{"a5[5]", "", "", fmt.Errorf("index out of range 5 with length 5")},
+		{"a2 / 0", "", "", fmt.Errorf("invalid operation a2 / 0: division by zero")},
+		{"*a9", "", "", fmt.Errorf("a9 is nil")},
+		{"a2.Baz", "", "", fmt.Errorf("a2 (type int) has no member Baz")},
+		{"a2 + a3", "", "", fmt.Errorf("mismatched types int and float64 in a2 + a3")},
+		{"i8 == 300", "", "", fmt.Errorf("constant 300 overflows int8")},
+		{"a2 == 1.5", "", "", fmt.Errorf("constant 1.5 truncated to integer")},
+		{"u8 == -1", "", "", fmt.Errorf("constant -1 overflows uint8")},
+		{"i8 == 1", "true", "bool", nil},
+		{"a2 == 6.0", "true", "bool", nil},
+	}
+
+	withTestProcess("testvariables", t, func(p *Process, fixture protest.Fixture) {
+		pc, _, _ := p.goSymTable.LineToPC(fixture.Source, 57)
+
+		_, err := p.SetBreakpoint(pc)
+		assertNoError(err, t, "SetBreakpoint() returned an error")
+
+		err = p.Continue()
+		assertNoError(err, t, "Continue() returned an error")
+
+		for _, tc := range testcases {
+			variable, err := p.EvalVariable(tc.name)
+			if tc.err == nil {
+				assertNoError(err, t, fmt.Sprintf("EvalVariable(%s) returned an error", tc.name))
+				assertVariable(t, variable, tc)
+			} else if err == nil || tc.err.Error() != err.Error() {
+				t.Fatalf("Unexpected error. Expected %s got %v", tc.err.Error(), err)
+			}
+		}
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	withTestProcess("testvariables", t, func(p *Process, fixture protest.Fixture) {
+		pc, _, _ := p.goSymTable.LineToPC(fixture.Source, 57)
+
+		_, err := p.SetBreakpoint(pc)
+		assertNoError(err, t, "SetBreakpoint() returned an error")
+
+		err = p.Continue()
+		assertNoError(err, t, "Continue() returned an error")
+
+		cfg := LoadConfig{MaxVariableRecurse: 0, MaxStringLen: 3, MaxArrayValues: 2, MaxMapValues: 2}
+
+		v, err := p.CurrentThread.EvalExpression("a1", cfg)
+		assertNoError(err, t, "EvalExpression(a1)")
+		if v.Kind != reflect.String || v.Len != 18 || v.Value != "foo...+15 more" {
+			t.Fatalf("a1: unexpected string %s (kind %s, len %d)", v.Value, v.Kind, v.Len)
+		}
+
+		v, err = p.CurrentThread.EvalExpression("ba", cfg)
+		assertNoError(err, t, "EvalExpression(ba)")
+		if v.Kind != reflect.Slice || v.Len != 200 || v.Cap != 200 || len(v.Children) != 2 {
+			t.Fatalf("ba: unexpected slice (kind %s, len %d, cap %d, %d children)", v.Kind, v.Len, v.Cap, len(v.Children))
+		}
+		if v.Value != "[]int len: 200, cap: 200, [0,0,...+198 more]" {
+			t.Fatalf("ba: unexpected value %s", v.Value)
+		}
+
+		v, err = p.CurrentThread.EvalExpression("ms", cfg)
+		assertNoError(err, t, "EvalExpression(ms)")
+		if v.Kind != reflect.Struct || len(v.Children) != 2 {
+			t.Fatalf("ms: unexpected struct (kind %s, %d children)", v.Kind, len(v.Children))
+		}
+		nest := v.Children[1]
+		if nest.Kind != reflect.Ptr || len(nest.Children) != 1 || !nest.Children[0].Unloaded {
+			t.Fatalf("ms.Nest: expected an unloaded pointee, got %s", nest.Value)
+		}
+		if v.Value != "main.Nest {Level: 0, Nest: *main.Nest {...}}" {
+			t.Fatalf("ms: unexpected value %s", v.Value)
+		}
+
+		unloaded := nest.Children[0]
+		v, err = p.CurrentThread.LoadVariable(unloaded.Addr, unloaded.Type, cfg)
+		assertNoError(err, t, "LoadVariable()")
+		if v.Value != "main.Nest {Level: 1, Nest: *main.Nest {...}}" {
+			t.Fatalf("LoadVariable: unexpected value %s", v.Value)
+		}
+
+		// Pointer types are reported with names DWARF doesn't give them.
+		a7, err := p.CurrentThread.EvalExpression("a7", p.DefaultLoadConfig())
+		assertNoError(err, t, "EvalExpression(a7)")
+		v, err = p.CurrentThread.LoadVariable(a7.Addr, a7.Type, p.DefaultLoadConfig())
+		assertNoError(err, t, "LoadVariable(a7)")
+		if v.Value != a7.Value {
+			t.Fatalf("LoadVariable(%s): expected %s got %s", a7.Type, a7.Value, v.Value)
+		}
+
+		// Negative limits load nothing.
+		cfg = LoadConfig{MaxVariableRecurse: -1, MaxStringLen: -1, MaxArrayValues: -1, MaxMapValues: -1}
+		v, err = p.CurrentThread.EvalExpression("ba", cfg)
+		assertNoError(err, t, "EvalExpression(ba)")
+		if len(v.Children) != 0 || v.Value != "[]int len: 200, cap: 200, [...+200 more]" {
+			t.Fatalf("ba: unexpected value %s", v.Value)
+		}
+		v, err = p.CurrentThread.EvalExpression("a10", cfg)
+		assertNoError(err, t, "EvalExpression(a10)")
+		if v.Value != "...+3 more" {
+			t.Fatalf("a10: unexpected value %s", v.Value)
+		}
+	})
+}
+
+func TestOptimizedVariables(t *testing.T) {
+	fixture := protest.BuildInlinedFixture("testoptimized")
+	p, err := Launch([]string{fixture.Path})
+	if err != nil {
+		t.Fatal("Launch():", err)
+	}
+	defer func() {
+		p.Halt()
+		p.Detach(true)
+	}()
+
+	// At its entry the arguments of describe are in registers, the string
+	// split between two of them.
+	fn := p.goSymTable.LookupFunc("main.describe")
+	if fn == nil {
+		t.Fatal("No main.describe")
+	}
+	_, err = p.SetBreakpoint(fn.Entry)
+	assertNoError(err, t, "SetBreakpoint()")
+	assertNoError(p.Continue(), t, "Continue()")
+
+	testcases := []varTest{
+		{"name", "answer", "struct string", nil},
+		{"n", "42", "int", nil},
+	}
+	for _, tc := range testcases {
+		variable, err := p.EvalVariable(tc.name)
+		assertNoError(err, t, fmt.Sprintf("EvalVariable(%s) returned an error", tc.name))
+		assertVariable(t, variable, tc)
+	}
+}
+
+func TestReadPieces(t *testing.T) {
+	regs := &DwarfRegisters{}
+	regs.SetReg(amd64DwarfRBX, 0xc000012345)
+	regs.SetReg(amd64DwarfRCX, 5)
+	regs.SetReg(amd64DwarfRDX, 0xff)
+	scope := &EvalScope{Regs: regs}
+
+	testcases := []struct {
+		pieces   []op.Piece
+		size     int64
+		expected []byte
+	}{
+		// A string split between a register holding the pointer and
+		// one holding the length.
+		{[]op.Piece{{Kind: op.RegPiece, Reg: amd64DwarfRBX, BitSize: 64}, {Kind: op.RegPiece, Reg: amd64DwarfRCX, BitSize: 64}}, 16,
+			[]byte{0x45, 0x23, 0x01, 0x00, 0xc0, 0, 0, 0, 5, 0, 0, 0, 0, 0, 0, 0}},
+		// A whole value in a register, truncated to its size.
+		{[]op.Piece{{Kind: op.RegPiece, Reg: amd64DwarfRBX}}, 4, []byte{0x45, 0x23, 0x01, 0x00}},
+		// The second half of a struct optimized away.
+		{[]op.Piece{{Kind: op.ImmPiece, Bytes: []byte{1, 2, 3, 4}, BitSize: 32}, {Kind: op.ImmPiece, BitSize: 32}}, 8,
+			[]byte{1, 2, 3, 4, 0, 0, 0, 0}},
+		// Bit pieces.
+		{[]op.Piece{{Kind: op.RegPiece, Reg: amd64DwarfRDX, BitSize: 4, BitOffset: 2}, {Kind: op.ImmPiece, Bytes: []byte{0x5}, BitSize: 4}}, 1,
+			[]byte{0x5f}},
+	}
+	for i, tc := range testcases {
+		data, err := scope.readPieces(tc.pieces, tc.size)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if !bytes.Equal(data, tc.expected) {
+			t.Fatalf("%d: expected %#v got %#v", i, tc.expected, data)
+		}
+	}
+
+	if _, err := scope.readPieces([]op.Piece{{Kind: op.RegPiece, Reg: amd64DwarfR8}}, 8); err == nil {
+		t.Fatal("expected an error reading an unknown register")
+	}
+}
+
+func TestVariableFormat(t *testing.T) {
+	var (
+		intType = &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 8, Name: "int"}}}
+		strType = &dwarf.StructType{StructName: "string", Kind: "struct"}
+		fooType = &dwarf.TypedefType{
+			CommonType: dwarf.CommonType{Name: "main.Foo"},
+			Type:       &dwarf.StructType{StructName: "main.Foo", Kind: "struct"},
+		}
+		ptrType = &dwarf.PtrType{CommonType: dwarf.CommonType{Name: "*main.Foo"}, Type: fooType}
+		arrType = &dwarf.ArrayType{CommonType: dwarf.CommonType{ByteSize: 24}, Type: intType, Count: 3}
+		mapType = &dwarf.TypedefType{CommonType: dwarf.CommonType{Name: "map[string]int"}}
+	)
+	scalar := func(name string, typ dwarf.Type, kind reflect.Kind, value string) *Variable {
+		return &Variable{Name: name, Type: typ.String(), Value: value, Kind: kind, typ: typ}
+	}
+	foo := func(unloaded bool) *Variable {
+		v := &Variable{Type: fooType.String(), Kind: reflect.Struct, typ: fooType, Unloaded: unloaded}
+		if !unloaded {
+			v.Children = []*Variable{
+				scalar("A", intType, reflect.Int, "1"),
+				scalar("B", strType, reflect.String, "x"),
+			}
+		}
+		return v
+	}
+
+	testcases := []struct {
+		v        *Variable
+		expected string
+	}{
+		{foo(false), "main.Foo {A: 1, B: x}"},
+		{foo(true), "main.Foo {...}"},
+		{&Variable{Kind: reflect.Ptr, typ: ptrType, Children: []*Variable{foo(false)}}, "*main.Foo {A: 1, B: x}"},
+		{&Variable{Kind: reflect.Ptr, typ: ptrType, isnil: true}, "*main.Foo nil"},
+		{&Variable{Kind: reflect.Ptr, typ: ptrType, Unloaded: true}, "..."},
+		{&Variable{Kind: reflect.Array, typ: arrType, Len: 3, Cap: 3, Children: []*Variable{
+			scalar("", intType, reflect.Int, "1"),
+			scalar("", intType, reflect.Int, "2"),
+		}}, "[3]int [1,2,...+1 more]"},
+		{&Variable{Kind: reflect.Slice, typ: sliceType(fooType, 8), Len: 4, Cap: 8, Children: []*Variable{foo(false)}},
+			"[]main.Foo len: 4, cap: 8, [{A: 1, B: x},...+3 more]"},
+		{&Variable{Kind: reflect.Map, typ: mapType, Len: 2, Children: []*Variable{
+			scalar("", strType, reflect.String, "one"),
+			scalar("", intType, reflect.Int, "1"),
+		}}, "map[string]int len: 2, [one: 1, ...+1 more]"},
+		{&Variable{Kind: reflect.Map, typ: mapType, isnil: true}, "map[string]int nil"},
+	}
+
+	for _, tc := range testcases {
+		tc.v.setValues()
+		if tc.v.Value != tc.expected {
+			t.Errorf("Expected %q got %q", tc.expected, tc.v.Value)
+		}
+	}
+}
+
+func TestTypeIntrospection(t *testing.T) {
+	withTestProcess("testvariables", t, func(p *Process, fixture protest.Fixture) {
+		pc, _, _ := p.goSymTable.LineToPC(fixture.Source, 57)
+
+		_, err := p.SetBreakpoint(pc)
+		assertNoError(err, t, "SetBreakpoint() returned an error")
+
+		err = p.Continue()
+		assertNoError(err, t, "Continue() returned an error")
+
+		whatis := map[string]string{
+			"a1":      "string",
+			"a4":      "[2]int",
+			"a5":      "[]int",
+			"a5[1:3]": "[]int",
+			"a6":      "main.FooBar",
+			"a7":      "*main.FooBar",
+			"a13":     "[]*main.FooBar",
+			"a2 + 1":  "int",
+			"1.5":     "float64",
+		}
+		for expr, expected := range whatis {
+			typ, err := p.CurrentThread.WhatIs(expr)
+			assertNoError(err, t, fmt.Sprintf("WhatIs(%s)", expr))
+			if typ != expected {
+				t.Fatalf("WhatIs(%s): expected %s got %s", expr, expected, typ)
+			}
+		}
+
+		info, err := p.DescribeType("main.FooBar")
+		assertNoError(err, t, "DescribeType()")
+		expected := []FieldInfo{{"Baz", "int", 0, 8}, {"Bur", "string", 8, 16}}
+		if info.Size != 24 || info.Underlying != "struct" || !reflect.DeepEqual(info.Fields, expected) {
+			t.Fatalf("Wrong layout of main.FooBar: %#v", info)
+		}
+
+		types, err := p.Types()
+		assertNoError(err, t, "Types()")
+		found := map[string]bool{}
+		for _, typ := range types {
+			found[typ] = true
+		}
+		if !found["main.FooBar"] || !found["main.Nest"] {
+			t.Fatalf("Missing fixture types in %d types", len(types))
+		}
+	})
+}
+
 func TestVariableFunctionScoping(t *testing.T) {
 	withTestProcess("testvariables", t, func(p *Process, fixture protest.Fixture) {
 		pc, _, _ := p.goSymTable.LineToPC(fixture.Source, 57)
@@ -166,7 +493,7 @@ func TestLocalVariables(t *testing.T) {
 				{"b1", "true", "bool", nil},
 				{"b2", "false", "bool", nil},
 				{"ba", "[]int len: 200, cap: 200, [0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,...+136 more]", "struct []int", nil},
-				{"f", "main.barfoo", "func()", nil},
+				{"f", "main.barfoo at {{fixture}}:21", "func()", nil},
 				{"f32", "1.2", "float32", nil},
 				{"i32", "[2]int32 [1,2]", "[2]int32", nil},
 				{"i8", "1", "int8", nil},
@@ -203,8 +530,456 @@ func TestLocalVariables(t *testing.T) {
 			}
 
 			for i, variable := range vars {
-				assertVariable(t, variable, tc.output[i])
+				assertVariable(t, variable, tc.output[i].atFixture(fixture))
+			}
+		}
+	})
+}
+
+func TestMapEvaluation(t *testing.T) {
+	testcases := []varTest{
+		{"m3", "map[string]int nil", "map[string]int", nil},
+		{"len(m1)", "3", "int", nil},
+		{"len(m2)", "100", "int", nil},
+		{"len(m3)", "0", "int", nil},
+		{"len(s)", "3", "int", nil},
+		{"len(str)", "5", "int", nil},
+		{`m1["two"]`, "2", "int", nil},
+		{"m2[7]", "49", "struct string", nil},
+		{`m1["four"]`, "", "", fmt.Errorf(`key "four" not found in m1`)},
+		{`m4[long + "2"]`, "2", "int", nil},
+		{"m5[p1]", "b", "struct string", nil},
+		{`m6["1"]`, "2", "int", nil},
+		{"m6[1]", "1", "int", nil},
+		{"s[0]", "1", "int", nil},
+	}
+
+	withTestProcess("testmaps", t, func(p *Process, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue() returned an error")
+
+		for _, tc := range testcases {
+			variable, err := p.EvalVariable(tc.name)
+			if tc.err == nil {
+				assertNoError(err, t, "EvalVariable() returned an error")
+				assertVariable(t, variable, tc)
+			} else if err == nil || tc.err.Error() != err.Error() {
+				t.Fatalf("Unexpected error. Expected %s got %v", tc.err.Error(), err)
 			}
 		}
+
+		m1, err := p.EvalVariable("m1")
+		assertNoError(err, t, "EvalVariable(m1)")
+		for _, entry := range []string{"one: 1", "two: 2", "three: 3"} {
+			if !strings.Contains(m1.Value, entry) {
+				t.Fatalf("%s missing from %s", entry, m1.Value)
+			}
+		}
+
+		p.MaxMapValues = 10
+		m2, err := p.EvalVariable("m2")
+		assertNoError(err, t, "EvalVariable(m2)")
+		if !strings.HasPrefix(m2.Value, "map[int]string len: 100, [") || !strings.HasSuffix(m2.Value, ", ...+90 more]") {
+			t.Fatalf("Wrong value for m2: %s", m2.Value)
+		}
 	})
 }
+
+func TestInterfaceEvaluation(t *testing.T) {
+	testcases := []varTest{
+		{"nilerr", "error nil", "error", nil},
+		{"e", "interface {}(int) 42", "interface {}", nil},
+		{"s", "main.Stringer(main.point) {X: 1, Y: 2}", "main.Stringer", nil},
+		{"err.(*os.PathError).Path", "/nonexistent/file", "struct string", nil},
+		{"err.(*os.PathError).Op", "open", "struct string", nil},
+		{"s.(main.point).Y", "2", "int", nil},
+		{"e.(string)", "", "", fmt.Errorf("interface conversion: interface {} is int, not string")},
+		{"nilerr.(*os.PathError)", "", "", fmt.Errorf("interface conversion: error is nil, not *os.PathError")},
+	}
+
+	withTestProcess("testinterfaces", t, func(p *Process, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue() returned an error")
+
+		for _, tc := range testcases {
+			variable, err := p.EvalVariable(tc.name)
+			if tc.err == nil {
+				assertNoError(err, t, "EvalVariable() returned an error")
+				assertVariable(t, variable, tc)
+			} else if err == nil || tc.err.Error() != err.Error() {
+				t.Fatalf("Unexpected error. Expected %s got %v", tc.err.Error(), err)
+			}
+		}
+
+		errv, err := p.EvalVariable("err")
+		assertNoError(err, t, "EvalVariable(err)")
+		if !strings.HasPrefix(errv.Value, "error(*os.PathError) ") {
+			t.Fatalf("Wrong value for err: %s", errv.Value)
+		}
+	})
+}
+
+func TestChannelEvaluation(t *testing.T) {
+	testcases := []varTest{
+		{"buffered", "chan int len: 4, cap: 4, [2,3,4,5]", "chan int", nil},
+		{"closed", "chan string len: 1, cap: 2, [last], closed", "chan string", nil},
+		{"nilch", "chan int nil", "chan int", nil},
+		{"len(buffered)", "4", "int", nil},
+		{"len(nilch)", "0", "int", nil},
+	}
+
+	withTestProcess("testchannels", t, func(p *Process, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue() returned an error")
+
+		for _, tc := range testcases {
+			variable, err := p.EvalVariable(tc.name)
+			assertNoError(err, t, "EvalVariable() returned an error")
+			assertVariable(t, variable, tc)
+		}
+
+		ch, err := p.CurrentThread.ChannelInfo("unbuffered")
+		assertNoError(err, t, "ChannelInfo(unbuffered)")
+		if ch.Len != 0 || ch.Cap != 0 || len(ch.SendWaiters) != 2 || len(ch.RecvWaiters) != 0 {
+			t.Fatalf("Wrong state for unbuffered: %+v", ch)
+		}
+		ch, err = p.CurrentThread.ChannelInfo("recv")
+		assertNoError(err, t, "ChannelInfo(recv)")
+		if len(ch.RecvWaiters) != 1 || len(ch.SendWaiters) != 0 {
+			t.Fatalf("Wrong state for recv: %+v", ch)
+		}
+	})
+}
+
+func TestKindsEvaluation(t *testing.T) {
+	testcases := []varTest{
+		{"c64", "(1+2i)", "complex64", nil},
+		{"c128", "(-1.5+0.25i)", "complex128", nil},
+		{"r", "97", "int32", nil},
+		{"rs", "[]int32 len: 5, cap: 5, [104,233,108,108,111]", "struct []int32", nil},
+		{"bs", "[]uint8 len: 5, cap: 5, \"hello\"", "struct []uint8", nil},
+		{"bin", "[]uint8 len: 3, cap: 3, 0xff00fe", "struct []uint8", nil},
+		{"esc", "tab\\there\\nnul\\x00bad\\xff", "struct string", nil},
+		{"up", "4096", "uintptr", nil},
+		{"nilp", "unsafe.Pointer(nil)", "unsafe.Pointer", nil},
+		{"anon", "{A: 1, B: {C: nested}}", "struct { A int; B struct { C string } }", nil},
+		{"anon.B.C", "nested", "struct string", nil},
+		{"d", "main.Derived {Base: main.Base {ID: 7, Name: base}, Inner: *main.Inner {Depth: 3}, Extra: extra}", "main.Derived", nil},
+		{"d.ID", "7", "int", nil},
+		{"d.Name", "base", "struct string", nil},
+		{"d.Base.ID", "7", "int", nil},
+		{"d.Depth", "3", "int", nil},
+		{"d.Missing", "", "", fmt.Errorf("d has no member Missing")},
+		{"*nilp", "", "", fmt.Errorf("invalid indirect of nilp (type unsafe.Pointer)")},
+	}
+
+	withTestProcess("testvariables2", t, func(p *Process, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue() returned an error")
+
+		for _, tc := range testcases {
+			variable, err := p.EvalVariable(tc.name)
+			if tc.err == nil {
+				assertNoError(err, t, "EvalVariable() returned an error")
+				assertVariable(t, variable, tc)
+			} else if err == nil || tc.err.Error() != err.Error() {
+				t.Fatalf("Unexpected error. Expected %s got %v", tc.err.Error(), err)
+			}
+		}
+
+		ptr, err := p.EvalVariable("ptr")
+		assertNoError(err, t, "EvalVariable(ptr)")
+		if ptr.Kind != reflect.UnsafePointer || !strings.HasPrefix(ptr.Value, "unsafe.Pointer(0x") {
+			t.Fatalf("Wrong value for ptr: %s (kind %s)", ptr.Value, ptr.Kind)
+		}
+	})
+}
+
+func TestFormatBasicValues(t *testing.T) {
+	int32Type := &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4, Name: "int32"}}}
+	runeType := &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4, Name: "rune"}}}
+	byteElems := func(b ...byte) []*Variable {
+		elems := make([]*Variable, 0, len(b))
+		for _, c := range b {
+			elems = append(elems, &Variable{Value: fmt.Sprint(c)})
+		}
+		return elems
+	}
+
+	testcases := []struct {
+		got, expected string
+	}{
+		{formatString("plain", 5), "plain"},
+		{formatString("a\tb\n", 4), "a\\tb\\n"},
+		{formatString("\x00\xff", 2), "\\x00\\xff"},
+		{formatString(`say "hi" \n`, 11), `say \"hi\" \\n`},
+		{formatString("héllo", 10), "héllo...+4 more"},
+		{formatString("h\xc3", 6), "h...+5 more"},
+		{formatComplex(1, -2, 64), "(1-2i)"},
+		{formatComplex(0.5, 0, 32), "(0.5+0i)"},
+		{formatInt(97, runeType), "97 'a'"},
+		{formatInt(10, runeType), "10"},
+		{formatInt(97, int32Type), "97"},
+		{formatInt(97, nil), "97"},
+		{formatBytes(byteElems('h', 'i', '\n')), "\"hi\\n\""},
+		{formatBytes(byteElems(0xff, 0xfe)), "0xfffe"},
+		{formatUnsafePointer(0), "unsafe.Pointer(nil)"},
+		{formatUnsafePointer(0x10), "unsafe.Pointer(0x10)"},
+	}
+	for _, tc := range testcases {
+		if tc.got != tc.expected {
+			t.Errorf("Expected %q got %q", tc.expected, tc.got)
+		}
+	}
+}
+
+func TestClosureEvaluation(t *testing.T) {
+	testcases := []varTest{
+		{"f", "main.counter.func1 at {{fixture}}:19 {n: 11, label: tick}", "func() int", nil},
+		{"g", "main.main.func1 at {{fixture}}:30 {x: 3}", "func() int", nil},
+		{"get", "main.T.Get at {{fixture}}:10 {receiver: main.T {N: 5}}", "func() int", nil},
+		{"inc", "main.(*T).Inc at {{fixture}}:12 {receiver: *main.T {N: 5}}", "func() int", nil},
+		{"nilf", "nil", "func()", nil},
+	}
+
+	withTestProcess("testclosures", t, func(p *Process, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue() returned an error")
+
+		for _, tc := range testcases {
+			variable, err := p.EvalVariable(tc.name)
+			assertNoError(err, t, "EvalVariable() returned an error")
+			assertVariable(t, variable, tc.atFixture(fixture))
+		}
+
+		f, err := p.EvalVariable("f")
+		assertNoError(err, t, "EvalVariable(f)")
+		if f.Kind != reflect.Func || len(f.Children) != 2 || f.Children[0].Name != "n" || f.Children[0].Addr == 0 {
+			t.Fatalf("Wrong captured variables for f: %#v", f.Children)
+		}
+	})
+}
+
+func TestShadowedVariables(t *testing.T) {
+	withTestProcess("testshadow", t, func(p *Process, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue() returned an error")
+
+		vars, err := p.CurrentThread.LocalVariables()
+		assertNoError(err, t, "LocalVariables() returned an error")
+		found := make(map[string]bool)
+		for _, v := range vars {
+			found[fmt.Sprintf("%s=%s shadowed:%v", v.Name, v.Value, v.Shadowed)] = true
+		}
+		expected := []string{"a=1 shadowed:true", "a=11 shadowed:false", "b=outer shadowed:false", "c=0 shadowed:false", "i=0 shadowed:false"}
+		if len(vars) != len(expected) {
+			t.Fatalf("Invalid variable count. Expected %d got %d: %v", len(expected), len(vars), found)
+		}
+		for _, e := range expected {
+			if !found[e] {
+				t.Fatalf("Variable %s not found in %v", e, found)
+			}
+		}
+
+		variable, err := p.EvalVariable("a")
+		assertNoError(err, t, "EvalVariable(a)")
+		assertVariable(t, variable, varTest{"a", "11", "int", nil})
+
+		assertNoError(p.Continue(), t, "Continue() returned an error")
+
+		vars, err = p.CurrentThread.LocalVariables()
+		assertNoError(err, t, "LocalVariables() returned an error")
+		sort.Sort(varArray(vars))
+		names := make([]string, len(vars))
+		for i, v := range vars {
+			if v.Shadowed {
+				t.Fatalf("Variable %s shadowed outside of the loop", v.Name)
+			}
+			names[i] = v.Name
+		}
+		if strings.Join(names, ",") != "a,b,d" {
+			t.Fatalf("Wrong variables in scope after the loop: %v", names)
+		}
+
+		variable, err = p.EvalVariable("a")
+		assertNoError(err, t, "EvalVariable(a)")
+		assertVariable(t, variable, varTest{"a", "1", "int", nil})
+	})
+}
+
+func TestFormatNumber(t *testing.T) {
+	var (
+		int8Type  = &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 1, Name: "int8"}}}
+		int32Type = &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4, Name: "int32"}}}
+		uint8Type = &dwarf.UintType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 1, Name: "uint8"}}}
+		bytesType = &dwarf.StructType{StructName: "[]uint8", Kind: "struct", Field: []*dwarf.StructField{{Name: "array", Type: &dwarf.PtrType{Type: uint8Type}}}}
+	)
+
+	testcases := []struct {
+		got, expected string
+	}{
+		{formatNumber(-1, true, int8Type, FormatHex), "0xff"},
+		{formatNumber(-1, true, int8Type, FormatDecimal), "-1"},
+		{formatNumber(97, true, int32Type, FormatBinary), "0b1100001"},
+		{formatNumber(97, true, int32Type, FormatChar), "97 'a'"},
+		{formatNumber(97, true, int32Type, FormatDefault), "97"},
+		{formatNumber(65, false, uint8Type, FormatChar), "65 'A'"},
+		{formatNumber(8, false, uint8Type, FormatOctal), "010"},
+		{formatNumber(255, false, uint8Type, FormatString), "255"},
+		{formatNumber(-1, false, nil, FormatDefault), "18446744073709551615"},
+	}
+	for _, tc := range testcases {
+		if tc.got != tc.expected {
+			t.Errorf("Expected %q got %q", tc.expected, tc.got)
+		}
+	}
+
+	// Byte slices are printed as strings with the s format, their
+	// elements are formatted as they are loaded with the others.
+	byteSlice := func(f Format, elems ...string) *Variable {
+		v := &Variable{Kind: reflect.Slice, Len: int64(len(elems)), Cap: int64(len(elems)), typ: bytesType, numFormat: f}
+		for _, elem := range elems {
+			v.Children = append(v.Children, &Variable{Value: elem, Kind: reflect.Uint8, typ: uint8Type, numFormat: f})
+		}
+		v.setValues()
+		return v
+	}
+	if v := byteSlice(FormatString, "104", "105"); v.Value != `[]uint8 len: 2, cap: 2, "hi"` {
+		t.Errorf("Wrong byte slice with the s format: %s", v.Value)
+	}
+	if v := byteSlice(FormatHex, "0x68", "0x69"); v.Value != "[]uint8 len: 2, cap: 2, [0x68,0x69]" {
+		t.Errorf("Wrong byte slice with the x format: %s", v.Value)
+	}
+
+	if _, err := ParseFormat("q"); err == nil {
+		t.Fatal("expected an error parsing an unknown format")
+	}
+}
+
+func TestDumpExpression(t *testing.T) {
+	withTestProcess("testdump", t, func(p *Process, fixture protest.Fixture) {
+		assertNoError(p.Continue(), t, "Continue() returned an error")
+
+		dump := func(expr string) map[string]interface{} {
+			var buf bytes.Buffer
+			assertNoError(p.CurrentThread.DumpExpression(expr, &buf), t, fmt.Sprintf("DumpExpression(%s)", expr))
+			var doc map[string]interface{}
+			assertNoError(json.Unmarshal(buf.Bytes(), &doc), t, fmt.Sprintf("Unmarshal(%s)", buf.String()))
+			return doc
+		}
+		ref := func(v interface{}) string {
+			r, _ := v.(map[string]interface{})["$ref"].(string)
+			return r
+		}
+
+		// a and b point to each other.
+		doc := dump("a")
+		objects := doc["objects"].(map[string]interface{})
+		if len(objects) != 2 {
+			t.Fatalf("a: expected 2 objects, got %v", objects)
+		}
+		na := objects[ref(doc["value"])].(map[string]interface{})
+		nb := objects[ref(na["Next"])].(map[string]interface{})
+		if na["Name"] != "a" || nb["Name"] != "b" || ref(nb["Next"]) != ref(doc["value"]) {
+			t.Fatalf("a: unexpected dump %v", doc)
+		}
+		if tags, ok := na["Tags"].(map[string]interface{}); !ok || tags["x"] != float64(1) || nb["Tags"] != nil {
+			t.Fatalf("a: unexpected tags %v %v", na["Tags"], nb["Tags"])
+		}
+
+		// Both elements reference the same object.
+		doc = dump("pair")
+		elems := doc["value"].([]interface{})
+		if len(doc["objects"].(map[string]interface{})) != 1 || ref(elems[0]) != ref(elems[1]) {
+			t.Fatalf("pair: unexpected dump %v", doc)
+		}
+
+		doc = dump("byID")
+		pairs := doc["value"].([]interface{})
+		if len(pairs) != 1 {
+			t.Fatalf("byID: unexpected dump %v", doc)
+		}
+		if kv := pairs[0].(map[string]interface{}); kv["key"] != float64(1) || kv["value"] != "one" {
+			t.Fatalf("byID: unexpected pair %v", kv)
+		}
+	})
+}
+
+func TestDumpGarbageLength(t *testing.T) {
+	withTestProcess("testdump", t, func(p *Process, fixture protest.Fixture) {
+		pc, _, _ := p.goSymTable.LineToPC(fixture.Source, 27)
+		_, err := p.SetBreakpoint(pc)
+		assertNoError(err, t, "SetBreakpoint() returned an error")
+		// Past the call to runtime.Breakpoint.
+		assertNoError(p.Continue(), t, "Continue() returned an error")
+		assertNoError(p.Continue(), t, "Continue() returned an error")
+
+		var buf bytes.Buffer
+		err = p.CurrentThread.DumpExpression("garbage", &buf)
+		if err == nil || !strings.Contains(err.Error(), "exceed the readable memory") {
+			t.Fatalf("expected the length of garbage to be rejected, got %v", err)
+		}
+	})
+}
+
+func TestPrettyPrinters(t *testing.T) {
+	withTestProcess("testprinters", t, func(p *Process, fixture protest.Fixture) {
+		pc, _, _ := p.goSymTable.LineToPC(fixture.Source, 32)
+		_, err := p.SetBreakpoint(pc)
+		assertNoError(err, t, "SetBreakpoint() returned an error")
+		// Past the call to runtime.Breakpoint.
+		assertNoError(p.Continue(), t, "Continue() returned an error")
+		assertNoError(p.Continue(), t, "Continue() returned an error")
+
+		testcases := []struct {
+			expr, value string
+		}{
+			{"t", "2009-11-10 23:00:00 +0000 UTC"},
+			{"tz", "2009-11-10 23:00:00 +0200 Mars"},
+			{"*n", "-123456789012345678901234567890"},
+			{"ip", "192.168.1.1"},
+			{"*mu", "locked"},
+			{"sb", `"hello"`},
+			{"*buf", `"data"`},
+		}
+		for _, tc := range testcases {
+			v, err := p.EvalVariable(tc.expr)
+			assertNoError(err, t, fmt.Sprintf("EvalVariable(%s)", tc.expr))
+			if v.Value != tc.value {
+				t.Fatalf("%s: expected %q got %q", tc.expr, tc.value, v.Value)
+			}
+		}
+
+		// The root context is printed differently before Go 1.21.
+		v, err := p.EvalVariable("ctx")
+		assertNoError(err, t, "EvalVariable(ctx)")
+		if !strings.HasPrefix(v.Value, "context.Context(*context.valueCtx) *context.WithValue(context.WithCancel(") || !strings.HasSuffix(v.Value, `), main.key("user"), "gopher")`) {
+			t.Fatalf("ctx: unexpected value %q", v.Value)
+		}
+
+		cfg := p.DefaultLoadConfig()
+		cfg.Raw = true
+		v, err = p.CurrentThread.EvalExpression("t", cfg)
+		assertNoError(err, t, "EvalExpression(t) in raw mode")
+		if strings.HasPrefix(v.Value, "2009") || len(v.Children) == 0 {
+			t.Fatalf("t: expected the fields of the time in raw mode, got %s", v.Value)
+		}
+	})
+}
+
+func TestRegisterPrettyPrinter(t *testing.T) {
+	const name = "main.testPrinterType"
+	RegisterPrettyPrinter(name, func(v *Variable, mem MemoryReader) (string, error) {
+		return fmt.Sprintf("%d fields", len(v.Children)), nil
+	})
+	defer RegisterPrettyPrinter(name, nil)
+
+	l := &loader{}
+	v := &Variable{Type: "struct " + name, Kind: reflect.Struct, Children: []*Variable{{Name: "a"}, {Name: "b"}}}
+	l.setPrinter(v)
+	v.setValues()
+	if v.Value != "2 fields" || v.format(true) != "2 fields" {
+		t.Fatalf("unexpected value %q", v.Value)
+	}
+
+	l.cfg.Raw = true
+	v = &Variable{Type: "struct " + name, Kind: reflect.Struct}
+	l.setPrinter(v)
+	if v.printer != nil {
+		t.Fatal("printer set in raw mode")
+	}
+}
@@ -7,12 +7,26 @@ import (
 
 // convertBreakpoint converts an internal breakpoint to an API Breakpoint.
 func ConvertBreakpoint(bp *proc.Breakpoint) *Breakpoint {
+	var filter string
+	if bp.CatchFilter != nil {
+		filter = bp.CatchFilter.String()
+	}
+	var inlined []uint64
+	for _, c := range bp.Copies {
+		inlined = append(inlined, c.Addr)
+	}
 	return &Breakpoint{
 		ID:           bp.ID,
 		FunctionName: bp.FunctionName,
 		File:         bp.File,
 		Line:         bp.Line,
 		Addr:         bp.Addr,
+		Variables:    bp.Variables,
+		Stacktrace:   bp.Stacktrace,
+		Tracepoint:   bp.Tracepoint,
+		Catch:        bp.Catch.String(),
+		CatchFilter:  filter,
+		InlinedAddrs: inlined,
 	}
 }
 
@@ -44,11 +58,66 @@ func ConvertThread(th *proc.Thread) *Thread {
 
 // convertVar converts an internal variable to an API Variable.
 func ConvertVar(v *proc.Variable) Variable {
-	return Variable{
-		Name:  v.Name,
-		Value: v.Value,
-		Type:  v.Type,
+	r := Variable{
+		Name:     v.Name,
+		Value:    v.Value,
+		Type:     v.Type,
+		Addr:     v.Addr,
+		Kind:     v.Kind,
+		Len:      v.Len,
+		Cap:      v.Cap,
+		Unloaded: v.Unloaded,
+		Shadowed: v.Shadowed,
 	}
+	if v.Children != nil {
+		r.Children = make([]Variable, 0, len(v.Children))
+		for _, child := range v.Children {
+			r.Children = append(r.Children, ConvertVar(child))
+		}
+	}
+	return r
+}
+
+// LoadConfigToProc converts a LoadConfig to a proc.LoadConfig.
+func LoadConfigToProc(cfg *LoadConfig) proc.LoadConfig {
+	return proc.LoadConfig{
+		MaxVariableRecurse: cfg.MaxVariableRecurse,
+		MaxStringLen:       cfg.MaxStringLen,
+		MaxArrayValues:     cfg.MaxArrayValues,
+		MaxMapValues:       cfg.MaxMapValues,
+		Raw:                cfg.Raw,
+	}
+}
+
+// ConvertChannel converts a proc.Channel to an api.Channel.
+func ConvertChannel(ch *proc.Channel) *Channel {
+	r := &Channel{
+		Type:        ch.Type,
+		Addr:        ch.Addr,
+		Len:         ch.Len,
+		Cap:         ch.Cap,
+		Closed:      ch.Closed,
+		Elems:       ch.Elems,
+		RecvWaiters: ch.RecvWaiters,
+		SendWaiters: ch.SendWaiters,
+	}
+	if r.Elems == nil {
+		r.Elems = []string{}
+	}
+	return r
+}
+
+// ConvertTypeInfo converts a proc.TypeInfo to an api.TypeInfo.
+func ConvertTypeInfo(info *proc.TypeInfo) *TypeInfo {
+	r := &TypeInfo{
+		Name:       info.Name,
+		Size:       info.Size,
+		Underlying: info.Underlying,
+	}
+	for _, f := range info.Fields {
+		r.Fields = append(r.Fields, Field{Name: f.Name, Type: f.Type, Offset: f.Offset, Size: f.Size})
+	}
+	return r
 }
 
 func ConvertFunction(fn *gosym.Func) *Function {
@@ -67,11 +136,21 @@ func ConvertFunction(fn *gosym.Func) *Function {
 // convertGoroutine converts an internal Goroutine to an API Goroutine.
 func ConvertGoroutine(g *proc.G) *Goroutine {
 	return &Goroutine{
-		ID:       g.Id,
-		PC:       g.PC,
-		File:     g.File,
-		Line:     g.Line,
-		Function: ConvertFunction(g.Func),
+		ID:             g.Id,
+		PC:             g.PC,
+		File:           g.File,
+		Line:           g.Line,
+		Function:       ConvertFunction(g.Func),
+		Status:         g.StatusString(),
+		WaitReason:     g.WaitReason,
+		WaitSince:      g.WaitSince,
+		WaitTime:       g.WaitTime,
+		ThreadID:       g.ThreadID,
+		LockedToThread: g.LockedToThread,
+		StackLo:        g.StackLo,
+		StackHi:        g.StackHi,
+		Labels:         g.Labels,
+		GoPC:           g.GoPC,
 	}
 }
 
@@ -83,3 +162,46 @@ func ConvertLocation(loc proc.Location) Location {
 		Function: ConvertFunction(loc.Fn),
 	}
 }
+
+// ConvertRuntimeInfo converts a proc.RuntimeInfo to an api.RuntimeInfo.
+func ConvertRuntimeInfo(info *proc.RuntimeInfo) *RuntimeInfo {
+	r := &RuntimeInfo{
+		GOMAXPROCS:    info.GOMAXPROCS,
+		Ps:            make([]P, 0, len(info.Ps)),
+		Ms:            make([]M, 0, len(info.Ms)),
+		GlobalRunqLen: info.GlobalRunqLen,
+		IdlePs:        info.IdlePs,
+		IdleMs:        info.IdleMs,
+		SpinningMs:    info.SpinningMs,
+		GCPhase:       info.GCPhase,
+		GCCycle:       info.GCCycle,
+		MemStats:      make([]MemStat, 0, len(info.MemStats)),
+	}
+	for _, p := range info.Ps {
+		r.Ps = append(r.Ps, P{ID: p.ID, Status: p.Status, M: p.M, RunqLen: p.RunqLen})
+	}
+	for _, m := range info.Ms {
+		r.Ms = append(r.Ms, M{ID: m.ID, ThreadID: m.ThreadID, CurG: m.CurG, P: m.P, Spinning: m.Spinning, Blocked: m.Blocked})
+	}
+	for _, stat := range info.MemStats {
+		r.MemStats = append(r.MemStats, MemStat{Name: stat.Name, Value: stat.Value})
+	}
+	return r
+}
+
+func ConvertBlockedG(bg *proc.BlockedG) *BlockedGoroutine {
+	r := &BlockedGoroutine{
+		Goroutine:  *ConvertGoroutine(bg.G),
+		ObjectKind: bg.ObjectKind,
+		ObjectAddr: bg.ObjectAddr,
+		WaitsOn:    bg.WaitsOn,
+	}
+	if bg.Location != nil {
+		loc := ConvertLocation(*bg.Location)
+		r.Location = &loc
+	}
+	if bg.Err != nil {
+		r.Error = bg.Err.Error()
+	}
+	return r
+}
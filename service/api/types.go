@@ -1,5 +1,10 @@
 package api
 
+import (
+	"reflect"
+	"time"
+)
+
 // DebuggerState represents the current context of the debugger.
 type DebuggerState struct {
 	// Breakpoint is the current breakpoint at which the debugged process is
@@ -9,6 +14,15 @@ type DebuggerState struct {
 	CurrentThread *Thread `json:"currentThread,omitempty"`
 	// Exited indicates whether the debugged process has exited.
 	Exited bool `json:"exited"`
+	// BreakpointInfo contains the information collected at Breakpoint, and
+	// may be empty if Breakpoint does not request any.
+	BreakpointInfo *BreakpointInfo `json:"breakPointInfo,omitempty"`
+	// Tracepoints contains the information collected at every tracepoint
+	// hit while executing the last command, in the order they were hit.
+	Tracepoints []*BreakpointInfo `json:"tracepoints,omitempty"`
+	// Displays are the values of the display expressions where the last
+	// command stopped.
+	Displays []Display `json:"displays,omitempty"`
 }
 
 // Breakpoint addresses a location at which process execution may be
@@ -25,6 +39,56 @@ type Breakpoint struct {
 	// FunctionName is the name of the function at the current breakpoint, and
 	// may not always be available.
 	FunctionName string `json:"functionName,omitempty"`
+	// Variables are the expressions evaluated every time the breakpoint is hit.
+	Variables []string `json:"variables,omitempty"`
+	// Stacktrace is the number of stack frames retrieved every time the
+	// breakpoint is hit.
+	Stacktrace int `json:"stacktrace,omitempty"`
+	// Tracepoint indicates that execution resumes automatically once the
+	// information requested by Variables and Stacktrace has been collected.
+	Tracepoint bool `json:"tracepoint,omitempty"`
+	// Catch is the goroutine event caught by the breakpoint, either
+	// GoroutineCreate or GoroutineExit, and is empty for regular breakpoints.
+	Catch string `json:"catch,omitempty"`
+	// CatchFilter is a regular expression, when set only goroutines
+	// started by a matching function are caught.
+	CatchFilter string `json:"catchFilter,omitempty"`
+	// InlinedAddrs are the addresses of the breakpoints set on the inlined
+	// copies of the function, which are part of this breakpoint.
+	InlinedAddrs []uint64 `json:"inlinedAddrs,omitempty"`
+}
+
+// Display is an expression evaluated every time the process stops.
+type Display struct {
+	// ID is a unique identifier for the display.
+	ID int `json:"id"`
+	// Expr is the expression evaluated.
+	Expr string `json:"expr"`
+	// Format is the format integers are printed with, as for print/x.
+	Format string `json:"format,omitempty"`
+	// Value is the value of Expr, nil if it couldn't be evaluated.
+	Value *Variable `json:"value,omitempty"`
+	// Err is why Expr couldn't be evaluated.
+	Err string `json:"err,omitempty"`
+	// Changed is set if the value differs from the one at the previous
+	// stop it was evaluated at.
+	Changed bool `json:"changed"`
+}
+
+// BreakpointInfo contains the information collected when a breakpoint is
+// hit.
+type BreakpointInfo struct {
+	// BreakpointID is the ID of the breakpoint that was hit.
+	BreakpointID int `json:"breakpointID"`
+	// ThreadID is the thread that hit the breakpoint.
+	ThreadID int `json:"threadID"`
+	// Stacktrace is the stack of ThreadID at the breakpoint.
+	Stacktrace []Location `json:"stacktrace,omitempty"`
+	// Variables are the values of the breakpoint's expressions.
+	Variables []Variable `json:"variables,omitempty"`
+	// Goroutine is the goroutine being created or exiting, if the
+	// breakpoint is a catchpoint.
+	Goroutine *Goroutine `json:"goroutine,omitempty"`
 }
 
 // Thread is a thread within the debugged process.
@@ -48,6 +112,23 @@ type Location struct {
 	Function *Function `json:"function,omitempty"`
 }
 
+// Stackframe is a frame of a call stack.
+type Stackframe struct {
+	// Location is the call site of the frame, or the current location for
+	// the innermost frame.
+	Location
+	// CFA is the canonical frame address, the value of the stack pointer
+	// before the call to the frame's function.
+	CFA int64 `json:"cfa"`
+	// Inlined is true if the frame is a call the compiler inlined into
+	// the function of the next frame.
+	Inlined bool `json:"inlined,omitempty"`
+	// Arguments and Locals are the variables of the frame, only retrieved
+	// when requested.
+	Arguments []Variable `json:"arguments,omitempty"`
+	Locals    []Variable `json:"locals,omitempty"`
+}
+
 // Function represents thread-scoped function information.
 type Function struct {
 	// Name is the function name.
@@ -66,6 +147,40 @@ type Variable struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
 	Type  string `json:"type"`
+	// Addr is the address of the variable, zero if it was computed.
+	Addr uint64 `json:"addr"`
+	// Kind is the kind of the value of the variable.
+	Kind reflect.Kind `json:"kind"`
+	// Len is the length of strings, arrays, slices, maps and channels.
+	Len int64 `json:"len"`
+	// Cap is the capacity of slices and channels.
+	Cap int64 `json:"cap"`
+	// Children are the fields of structs, the elements of arrays, slices
+	// and channels, the pointee of pointers, the dynamic value of
+	// interfaces and the alternating keys and values of maps.
+	Children []Variable `json:"children,omitempty"`
+	// Unloaded is set if the children of the variable weren't loaded.
+	// They can be loaded with the address and type of the variable.
+	Unloaded bool `json:"unloaded,omitempty"`
+	// Shadowed is set for local variables hidden by a variable with the
+	// same name declared in an inner block.
+	Shadowed bool `json:"shadowed,omitempty"`
+}
+
+// LoadConfig limits how much of a variable is loaded.
+type LoadConfig struct {
+	// MaxVariableRecurse is how many levels of nested structs are loaded.
+	MaxVariableRecurse int `json:"maxVariableRecurse"`
+	// MaxStringLen is the maximum number of bytes loaded from strings.
+	MaxStringLen int `json:"maxStringLen"`
+	// MaxArrayValues is the maximum number of elements loaded from
+	// arrays, slices and channels.
+	MaxArrayValues int `json:"maxArrayValues"`
+	// MaxMapValues is the maximum number of entries loaded from maps.
+	MaxMapValues int `json:"maxMapValues"`
+	// Raw bypasses the pretty-printers of well-known types such as
+	// time.Time, to load the children of a value printed raw.
+	Raw bool `json:"raw,omitempty"`
 }
 
 // Goroutine represents the information relevant to Delve from the runtime's
@@ -81,6 +196,218 @@ type Goroutine struct {
 	Line int `json:"line"`
 	// Function is function information at the program counter. May be nil.
 	Function *Function `json:"function,omitempty"`
+	// Status is the status of the goroutine: idle, runnable, running,
+	// syscall, waiting, dead or copystack.
+	Status string `json:"status"`
+	// WaitReason is the reason the goroutine is parked, if it is waiting.
+	WaitReason string `json:"waitReason,omitempty"`
+	// WaitSince is the approximate runtime nanotime at which the goroutine
+	// was parked. It is only recorded by the garbage collector and may be
+	// zero.
+	WaitSince int64 `json:"waitSince,omitempty"`
+	// WaitTime is the approximate time the goroutine has been waiting for,
+	// zero if unknown.
+	WaitTime time.Duration `json:"waitTime,omitempty"`
+	// ThreadID is the ID of the thread the goroutine is running on, zero
+	// if none.
+	ThreadID int `json:"threadID,omitempty"`
+	// LockedToThread is true if the goroutine called runtime.LockOSThread.
+	LockedToThread bool `json:"lockedToThread,omitempty"`
+	// StackLo and StackHi are the bounds of the goroutine's stack.
+	StackLo uint64 `json:"stackLo"`
+	StackHi uint64 `json:"stackHi"`
+	// Labels are the profiler labels of the goroutine.
+	Labels map[string]string `json:"labels,omitempty"`
+	// GoPC is the PC of the go statement that created the goroutine, the
+	// location of which is File and Line.
+	GoPC uint64 `json:"goPC"`
+}
+
+// GoroutineStack is a goroutine along with its stack.
+type GoroutineStack struct {
+	Goroutine
+	// Stack is the stack of the goroutine, innermost frame first.
+	Stack []Location `json:"stack"`
+	// Err is set if the stack could not be read entirely.
+	Err string `json:"err,omitempty"`
+}
+
+// ListGoroutinesOptions selects, groups and pages the goroutines
+// returned by ListGoroutines. The zero value returns every goroutine.
+type ListGoroutinesOptions struct {
+	// WaitReason selects the goroutines parked for this reason.
+	WaitReason string `json:"waitReason,omitempty"`
+	// Status selects the goroutines with this status: idle, runnable,
+	// running, syscall, waiting, dead or copystack.
+	Status string `json:"status,omitempty"`
+	// Label selects the goroutines with the profiler label "key=value",
+	// or with the label key set to any value if it has no '='.
+	Label string `json:"label,omitempty"`
+	// ThreadID selects the goroutine running on this thread.
+	ThreadID int `json:"threadID,omitempty"`
+	// LockedToThread selects the goroutines locked to their thread.
+	LockedToThread bool `json:"lockedToThread,omitempty"`
+	// Function is a regular expression selecting the goroutines whose
+	// current function matches.
+	Function string `json:"function,omitempty"`
+	// CurrentLocation, UserLocation and StartLocation are regular
+	// expressions matched against "file:line function" of respectively
+	// the current location of the goroutine, its innermost frame outside
+	// of the runtime and the go statement that created it.
+	CurrentLocation string `json:"currentLocation,omitempty"`
+	UserLocation    string `json:"userLocation,omitempty"`
+	StartLocation   string `json:"startLocation,omitempty"`
+	// Group collapses goroutines with identical stacks, comparing at most
+	// GroupDepth frames.
+	Group      bool `json:"group,omitempty"`
+	GroupDepth int  `json:"groupDepth,omitempty"`
+	// Start is the index of the first goroutine, or group, to return and
+	// Count the maximum number returned. A Count of zero returns all of them.
+	Start int `json:"start,omitempty"`
+	Count int `json:"count,omitempty"`
+}
+
+// GoroutineGroup is a set of goroutines with identical stacks.
+type GoroutineGroup struct {
+	// Goroutines are the IDs of the goroutines in the group.
+	Goroutines []int `json:"goroutines"`
+	// Stack is the stack shared by the goroutines.
+	Stack []Location `json:"stack"`
+}
+
+// GoroutineList is a page of the goroutines selected by a
+// ListGoroutinesOptions.
+type GoroutineList struct {
+	// Goroutines is set when the goroutines are not grouped.
+	Goroutines []*Goroutine `json:"goroutines,omitempty"`
+	// Groups is set when the goroutines are grouped, largest group first.
+	Groups []*GoroutineGroup `json:"groups,omitempty"`
+	// Total is the number of goroutines, or groups, matching the filters.
+	Total int `json:"total"`
+}
+
+// BlockedGoroutine is a goroutine parked on a channel or synchronization
+// object.
+type BlockedGoroutine struct {
+	Goroutine
+	// ObjectKind is the kind of object the goroutine is blocked on: chan,
+	// sema or select. May be empty if it could not be determined.
+	ObjectKind string `json:"objectKind,omitempty"`
+	// ObjectAddr is the address of the object, zero if unknown.
+	ObjectAddr uint64 `json:"objectAddr,omitempty"`
+	// Location is the innermost frame of the goroutine outside the runtime.
+	Location *Location `json:"location,omitempty"`
+	// WaitsOn lists the blocked goroutines which may need to run for this
+	// one to be released.
+	WaitsOn []int `json:"waitsOn,omitempty"`
+	// Error is the reason the object could not be determined, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// BlockedGroup is a set of goroutines blocked for the same reason on the
+// same object.
+type BlockedGroup struct {
+	WaitReason string `json:"waitReason"`
+	ObjectKind string `json:"objectKind,omitempty"`
+	ObjectAddr uint64 `json:"objectAddr,omitempty"`
+	// Goroutines are the IDs of the goroutines in the group.
+	Goroutines []int `json:"goroutines"`
+}
+
+// DeadlockReport describes the blocked goroutines of the process and the
+// wait-for relations between them.
+type DeadlockReport struct {
+	Blocked []*BlockedGoroutine `json:"blocked"`
+	Groups  []*BlockedGroup     `json:"groups"`
+	// Cycles are sets of blocked goroutines waiting on each other.
+	Cycles [][]int `json:"cycles,omitempty"`
+	// AllBlocked is true when no goroutine is running or runnable.
+	AllBlocked bool `json:"allBlocked"`
+	// Dot is the wait-for graph in Graphviz DOT format.
+	Dot string `json:"dot"`
+}
+
+// RuntimeInfo describes the state of the scheduler, the garbage collector
+// and the memory allocator of the runtime.
+type RuntimeInfo struct {
+	GOMAXPROCS int `json:"gomaxprocs"`
+	Ps         []P `json:"ps"`
+	Ms         []M `json:"ms"`
+	// GlobalRunqLen is the number of goroutines in the global run queue.
+	GlobalRunqLen int `json:"globalRunqLen"`
+	IdlePs        int `json:"idlePs"`
+	IdleMs        int `json:"idleMs"`
+	SpinningMs    int `json:"spinningMs"`
+	// GCPhase is one of off, mark or mark termination.
+	GCPhase string `json:"gcPhase"`
+	// GCCycle is the number of the current or last GC cycle.
+	GCCycle uint64 `json:"gcCycle"`
+	// MemStats are integer members of runtime.memstats and
+	// runtime.gcController, named like memstats.numgc.
+	MemStats []MemStat `json:"memStats"`
+}
+
+// P describes a processor of the scheduler.
+type P struct {
+	ID int `json:"id"`
+	// Status is one of idle, running, syscall, gcstop or dead.
+	Status string `json:"status"`
+	// M is the ID of the M holding the P, -1 if none.
+	M int `json:"m"`
+	// RunqLen is the number of goroutines in the local run queue.
+	RunqLen int `json:"runqLen"`
+}
+
+// M describes an OS thread of the scheduler.
+type M struct {
+	ID       int `json:"id"`
+	ThreadID int `json:"threadID"`
+	// CurG is the ID of the goroutine running on the M, zero if none.
+	CurG int `json:"curg"`
+	// P is the ID of the P held by the M, -1 if none.
+	P        int  `json:"p"`
+	Spinning bool `json:"spinning"`
+	Blocked  bool `json:"blocked"`
+}
+
+// MemStat is a statistic of the memory allocator.
+type MemStat struct {
+	Name  string `json:"name"`
+	Value uint64 `json:"value"`
+}
+
+// Channel describes the state of a channel.
+type Channel struct {
+	Type string `json:"type"`
+	// Addr is the address of the runtime hchan, zero for nil channels.
+	Addr   uint64 `json:"addr"`
+	Len    int64  `json:"len"`
+	Cap    int64  `json:"cap"`
+	Closed bool   `json:"closed"`
+	// Elems are the buffered elements, the next to be received first.
+	Elems []string `json:"elems"`
+	// RecvWaiters and SendWaiters are the IDs of the goroutines parked
+	// receiving from and sending to the channel.
+	RecvWaiters []int `json:"recvWaiters"`
+	SendWaiters []int `json:"sendWaiters"`
+}
+
+// TypeInfo describes the memory layout of a type.
+type TypeInfo struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	// Underlying is the Go type the type is defined as, "struct" for
+	// structs, whose fields are listed in Fields.
+	Underlying string  `json:"underlying"`
+	Fields     []Field `json:"fields,omitempty"`
+}
+
+// Field describes a field of a struct.
+type Field struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
 }
 
 // DebuggerCommand is a command which changes the debugger's execution state.
@@ -104,3 +431,10 @@ const (
 	// Halt suspends the process.
 	Halt = "halt"
 )
+
+const (
+	// GoroutineCreate catches the creation of goroutines.
+	GoroutineCreate = "goroutine-create"
+	// GoroutineExit catches the exit of goroutines.
+	GoroutineExit = "goroutine-exit"
+)
@@ -42,6 +42,17 @@ type Client interface {
 	ListPackageVariables(filter string) ([]api.Variable, error)
 	// EvalVariable returns a variable in the context of the current thread.
 	EvalVariable(symbol string) (*api.Variable, error)
+	// EvalVariableCfg returns a variable in the context of the current thread, loaded as configured by cfg.
+	EvalVariableCfg(symbol string, cfg api.LoadConfig) (*api.Variable, error)
+	// EvalVariableFormat returns a variable in the context of the current thread, its integers printed with format:
+	// x (hexadecimal), o (octal), b (binary), d (decimal), c (character), or s to print byte slices as strings.
+	EvalVariableFormat(symbol, format string) (*api.Variable, error)
+	// EvalVariableRaw is like EvalVariableFormat, bypassing the pretty-printers of well-known types such as time.Time.
+	EvalVariableRaw(symbol, format string) (*api.Variable, error)
+	// LoadVariable loads the value of type typ at addr, as described by the Addr and Type of an unloaded variable.
+	LoadVariable(addr uint64, typ string, cfg api.LoadConfig) (*api.Variable, error)
+	// DumpVariable returns the whole value of expr as a JSON document, pointers written as references to the values they point to.
+	DumpVariable(expr string) ([]byte, error)
 	// ListPackageVariablesFor lists all package variables in the context of a thread.
 	ListPackageVariablesFor(threadID int, filter string) ([]api.Variable, error)
 	// EvalVariableFor returns a variable in the context of the specified thread.
@@ -58,9 +69,38 @@ type Client interface {
 	// ListRegisters lists registers and their values.
 	ListRegisters() (string, error)
 
-	// ListGoroutines lists all goroutines.
-	ListGoroutines() ([]*api.Goroutine, error)
+	// ListGoroutines lists the goroutines selected by opts, all of them
+	// if opts is nil.
+	ListGoroutines(opts *api.ListGoroutinesOptions) (*api.GoroutineList, error)
 
-	// Returns stacktrace
-	Stacktrace(goroutineId, depth int) ([]*api.Location, error)
+	// Stacktrace returns the frames of the stack of a goroutine, or of the
+	// current thread if goroutineId is negative. If full is true the
+	// arguments and local variables of each frame are included.
+	Stacktrace(goroutineId, depth int, full bool) ([]api.Stackframe, error)
+	// GoroutinesStacks returns the stacks, at most depth frames deep, of
+	// the goroutines selected by opts, all of them if opts is nil.
+	GoroutinesStacks(opts *api.ListGoroutinesOptions, depth int) ([]api.GoroutineStack, error)
+
+	// Channel returns the state of a channel variable in the context of
+	// the current thread.
+	Channel(symbol string) (*api.Channel, error)
+
+	// WhatIs returns the Go type of expr in the context of the current thread.
+	WhatIs(expr string) (string, error)
+	// ListTypes lists the names of all types in the process matching filter.
+	ListTypes(filter string) ([]string, error)
+	// DescribeType returns the memory layout of the type with the given name.
+	DescribeType(name string) (*api.TypeInfo, error)
+
+	// AddDisplay adds expr to the expressions evaluated every time the process stops, its integers printed with format.
+	AddDisplay(expr, format string) (*api.Display, error)
+	// RemoveDisplay removes a display expression by ID.
+	RemoveDisplay(id int) error
+	// ListDisplays returns the display expressions evaluated in the context of the current thread.
+	ListDisplays() ([]api.Display, error)
+
+	// Deadlock reports the blocked goroutines and what they wait on.
+	Deadlock() (*api.DeadlockReport, error)
+	// RuntimeInfo returns the state of the scheduler, the garbage collector and the memory allocator of the runtime.
+	RuntimeInfo() (*api.RuntimeInfo, error)
 }
@@ -16,4 +16,7 @@ type Config struct {
 	// AttachPid is the PID of an existing process to which the debugger should
 	// attach.
 	AttachPid int
+	// MaxMapValues is the maximum number of entries read when evaluating a
+	// map, zero for the default.
+	MaxMapValues int
 }
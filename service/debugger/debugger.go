@@ -1,9 +1,14 @@
 package debugger
 
 import (
+	"bytes"
 	"fmt"
+	"go/parser"
 	"log"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/derekparker/delve/proc"
 	"github.com/derekparker/delve/service/api"
@@ -20,6 +25,26 @@ import (
 type Debugger struct {
 	config  *Config
 	process *proc.Process
+
+	// Expressions evaluated every time the process stops.
+	displays         []*display
+	displayIDCounter int
+	// Number of times the process stopped after being resumed.
+	stops int
+	// Information collected at the breakpoint the process last stopped at.
+	breakpointInfo *api.BreakpointInfo
+}
+
+// display is a display expression along with the values it had the last
+// two stops it was evaluated at.
+type display struct {
+	id           int
+	expr, format string
+	evaluated    bool
+	stop         int    // Stop value was evaluated at.
+	value        string // Value, or error, at stop.
+	hasPrev      bool
+	prev         string // Value, or error, at the stop evaluated before.
 }
 
 // Config provides the configuration to start a Debugger.
@@ -33,6 +58,9 @@ type Config struct {
 	// AttachPid is the PID of an existing process to which the debugger should
 	// attach.
 	AttachPid int
+	// MaxMapValues is the maximum number of entries read when evaluating a
+	// map, zero for the default.
+	MaxMapValues int
 }
 
 // New creates a new Debugger.
@@ -57,6 +85,9 @@ func New(config *Config) (*Debugger, error) {
 		}
 		d.process = p
 	}
+	if d.config.MaxMapValues > 0 {
+		d.process.MaxMapValues = d.config.MaxMapValues
+	}
 	return d, nil
 }
 
@@ -86,27 +117,141 @@ func (d *Debugger) State() (*api.DebuggerState, error) {
 		Exited:        d.process.Exited(),
 	}
 
+	if info := d.breakpointInfo; info != nil && th != nil && info.ThreadID == th.Id {
+		state.BreakpointInfo = info
+	}
+
 	return state, nil
 }
 
+// CurrentThread returns the thread the process is stopped on, or nil.
+func (d *Debugger) CurrentThread() *api.Thread {
+	th := d.process.CurrentThread
+	if th == nil {
+		return nil
+	}
+	return api.ConvertThread(th)
+}
+
+// collectBreakpointInformation evaluates the expressions and retrieves the
+// stack requested by bp, in the context of the thread that hit it.
+// Returns nil if bp doesn't request any information and isn't a catchpoint.
+func (d *Debugger) collectBreakpointInformation(th *proc.Thread, bp *proc.Breakpoint) *api.BreakpointInfo {
+	if len(bp.Variables) == 0 && bp.Stacktrace <= 0 && th.CaughtGoroutine == nil {
+		return nil
+	}
+
+	info := &api.BreakpointInfo{
+		BreakpointID: bp.ID,
+		ThreadID:     th.Id,
+	}
+
+	if th.CaughtGoroutine != nil {
+		info.Goroutine = api.ConvertGoroutine(th.CaughtGoroutine)
+	}
+
+	if bp.Stacktrace > 0 {
+		frames, err := th.Stackframes(bp.Stacktrace)
+		if err != nil {
+			log.Printf("could not collect stacktrace for breakpoint %d: %s", bp.ID, err)
+		}
+		info.Stacktrace = stackLocations(frames)
+	}
+
+	for _, name := range bp.Variables {
+		v, err := th.EvalVariable(name)
+		if err != nil {
+			info.Variables = append(info.Variables, api.Variable{Name: name, Value: fmt.Sprintf("<error: %s>", err)})
+			continue
+		}
+		info.Variables = append(info.Variables, api.ConvertVar(v))
+	}
+
+	return info
+}
+
 func (d *Debugger) CreateBreakpoint(requestedBp *api.Breakpoint) (*api.Breakpoint, error) {
 	var createdBp *api.Breakpoint
 	var loc string
+	var inlined []uint64
 	switch {
+	case len(requestedBp.Catch) > 0:
+		return d.createCatchpoint(requestedBp)
 	case len(requestedBp.File) > 0:
 		loc = fmt.Sprintf("%s:%d", requestedBp.File, requestedBp.Line)
 	case len(requestedBp.FunctionName) > 0:
 		loc = requestedBp.FunctionName
+		inlined = d.process.InlinedEntries(loc)
 	default:
 		return nil, fmt.Errorf("no file or function name specified")
 	}
 
 	bp, err := d.process.SetBreakpointByLocation(loc)
 	if err != nil {
-		return nil, err
+		// The function may only exist as inlined copies.
+		if len(inlined) == 0 {
+			return nil, err
+		}
+		if bp, err = d.process.SetBreakpoint(inlined[0]); err != nil {
+			return nil, err
+		}
+		inlined = inlined[1:]
+	}
+	bps := []*proc.Breakpoint{bp}
+	for _, addr := range inlined {
+		ibp, err := d.process.SetBreakpointCopy(bp, addr)
+		if err != nil {
+			if _, ok := err.(proc.BreakpointExistsError); ok {
+				continue
+			}
+			return nil, err
+		}
+		bps = append(bps, ibp)
+	}
+	for _, b := range bps {
+		if len(requestedBp.FunctionName) > 0 {
+			b.FunctionName = requestedBp.FunctionName
+		}
+		b.Variables = requestedBp.Variables
+		b.Stacktrace = requestedBp.Stacktrace
+		b.Tracepoint = requestedBp.Tracepoint
 	}
 	createdBp = api.ConvertBreakpoint(bp)
 	log.Printf("created breakpoint: %#v", createdBp)
+	if len(bps) > 1 {
+		log.Printf("also created breakpoints on %d inlined copies", len(bps)-1)
+	}
+	return createdBp, nil
+}
+
+func (d *Debugger) createCatchpoint(requestedBp *api.Breakpoint) (*api.Breakpoint, error) {
+	var kind proc.CatchKind
+	switch requestedBp.Catch {
+	case api.GoroutineCreate:
+		kind = proc.CatchGoroutineCreate
+	case api.GoroutineExit:
+		kind = proc.CatchGoroutineExit
+	default:
+		return nil, fmt.Errorf("unknown catchpoint %s, must be %s or %s", requestedBp.Catch, api.GoroutineCreate, api.GoroutineExit)
+	}
+
+	var filter *regexp.Regexp
+	if len(requestedBp.CatchFilter) > 0 {
+		var err error
+		if filter, err = regexp.Compile(requestedBp.CatchFilter); err != nil {
+			return nil, fmt.Errorf("invalid filter argument: %s", err.Error())
+		}
+	}
+
+	bp, err := d.process.SetCatchpoint(kind, filter)
+	if err != nil {
+		return nil, err
+	}
+	bp.Variables = requestedBp.Variables
+	bp.Stacktrace = requestedBp.Stacktrace
+	bp.Tracepoint = requestedBp.Tracepoint
+	createdBp := api.ConvertBreakpoint(bp)
+	log.Printf("created catchpoint: %#v", createdBp)
 	return createdBp, nil
 }
 
@@ -124,7 +269,7 @@ func (d *Debugger) ClearBreakpoint(requestedBp *api.Breakpoint) (*api.Breakpoint
 func (d *Debugger) Breakpoints() []*api.Breakpoint {
 	bps := []*api.Breakpoint{}
 	for _, bp := range d.process.Breakpoints {
-		if bp.Temp {
+		if bp.Temp || bp.CopyOf != nil {
 			continue
 		}
 		bps = append(bps, api.ConvertBreakpoint(bp))
@@ -160,11 +305,27 @@ func (d *Debugger) FindThread(id int) *api.Thread {
 
 // Command handles commands which control the debugger lifecycle
 func (d *Debugger) Command(command *api.DebuggerCommand) (*api.DebuggerState, error) {
-	var err error
+	var (
+		err         error
+		tracepoints []*api.BreakpointInfo
+	)
 	switch command.Name {
 	case api.Continue:
 		log.Print("continuing")
 		err = d.process.Continue()
+		// Collect the information requested by tracepoints and keep
+		// going until we stop somewhere else.
+		for err == nil {
+			bp := d.process.CurrentBreakpoint()
+			if bp == nil || !bp.Tracepoint {
+				break
+			}
+			if info := d.collectBreakpointInformation(d.process.CurrentThread, bp); info != nil {
+				tracepoints = append(tracepoints, info)
+			}
+			log.Printf("tracepoint %d hit, continuing", bp.ID)
+			err = d.process.Continue()
+		}
 	case api.Next:
 		log.Print("nexting")
 		err = d.process.Next()
@@ -183,7 +344,90 @@ func (d *Debugger) Command(command *api.DebuggerCommand) (*api.DebuggerState, er
 	if err != nil {
 		return nil, err
 	}
-	return d.State()
+	switch command.Name {
+	case api.Continue, api.Next, api.Step:
+		// Collect the information requested by the breakpoint once, as
+		// the process is when it stops there.
+		d.breakpointInfo = nil
+		if th, bp := d.process.CurrentThread, d.process.CurrentBreakpoint(); th != nil && bp != nil {
+			d.breakpointInfo = d.collectBreakpointInformation(th, bp)
+		}
+	}
+	state, err := d.State()
+	if err != nil {
+		return nil, err
+	}
+	state.Tracepoints = tracepoints
+	switch command.Name {
+	case api.Continue, api.Next, api.Step:
+		d.stops++
+		if !state.Exited {
+			state.Displays = d.Displays()
+		}
+	}
+	return state, nil
+}
+
+// AddDisplay adds expr to the expressions evaluated every time the
+// process stops, printing its integers with format, and returns it
+// evaluated in the current thread.
+func (d *Debugger) AddDisplay(expr, format string) (*api.Display, error) {
+	if _, err := proc.ParseFormat(format); err != nil {
+		return nil, err
+	}
+	if _, err := parser.ParseExpr(expr); err != nil {
+		return nil, err
+	}
+	d.displayIDCounter++
+	disp := &display{id: d.displayIDCounter, expr: expr, format: format}
+	d.displays = append(d.displays, disp)
+	r := d.evalDisplay(disp)
+	return &r, nil
+}
+
+// RemoveDisplay removes the display expression with the given ID.
+func (d *Debugger) RemoveDisplay(id int) error {
+	for i, disp := range d.displays {
+		if disp.id == id {
+			d.displays = append(d.displays[:i], d.displays[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no display with id %d", id)
+}
+
+// Displays returns the display expressions evaluated in the current thread.
+func (d *Debugger) Displays() []api.Display {
+	displays := make([]api.Display, 0, len(d.displays))
+	for _, disp := range d.displays {
+		displays = append(displays, d.evalDisplay(disp))
+	}
+	return displays
+}
+
+// evalDisplay evaluates disp in the current thread, noting whether its
+// value changed since the previous stop it was evaluated at.
+func (d *Debugger) evalDisplay(disp *display) api.Display {
+	r := api.Display{ID: disp.id, Expr: disp.expr, Format: disp.format}
+	value := ""
+	if th := d.process.CurrentThread; th == nil {
+		r.Err = "no current thread"
+	} else if v, err := d.EvalVariableInThread(th.Id, disp.expr, nil, disp.format, false); err != nil {
+		r.Err = err.Error()
+	} else {
+		r.Value = v
+		value = v.Value
+	}
+	if r.Err != "" {
+		value = "error: " + r.Err
+	}
+
+	if disp.evaluated && disp.stop != d.stops {
+		disp.prev, disp.hasPrev = disp.value, true
+	}
+	disp.evaluated, disp.stop, disp.value = true, d.stops, value
+	r.Changed = disp.hasPrev && disp.prev != disp.value
+	return r
 }
 
 func (d *Debugger) Sources(filter string) ([]string, error) {
@@ -216,6 +460,44 @@ func (d *Debugger) Functions(filter string) ([]string, error) {
 	return funcs, nil
 }
 
+// Types returns the names of the types of the process matching filter.
+func (d *Debugger) Types(filter string) ([]string, error) {
+	regex, err := regexp.Compile(filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter argument: %s", err.Error())
+	}
+
+	all, err := d.process.Types()
+	if err != nil {
+		return nil, err
+	}
+	types := []string{}
+	for _, t := range all {
+		if regex.Match([]byte(t)) {
+			types = append(types, t)
+		}
+	}
+	return types, nil
+}
+
+// DescribeType returns the memory layout of the type with the given name.
+func (d *Debugger) DescribeType(name string) (*api.TypeInfo, error) {
+	info, err := d.process.DescribeType(name)
+	if err != nil {
+		return nil, err
+	}
+	return api.ConvertTypeInfo(info), nil
+}
+
+// WhatIsInThread returns the Go type of expr in the context of the thread.
+func (d *Debugger) WhatIsInThread(threadID int, expr string) (string, error) {
+	thread, found := d.process.Threads[threadID]
+	if !found {
+		return "", fmt.Errorf("couldn't find thread %d", threadID)
+	}
+	return thread.WhatIs(expr)
+}
+
 func (d *Debugger) PackageVariables(threadID int, filter string) ([]api.Variable, error) {
 	regex, err := regexp.Compile(filter)
 	if err != nil {
@@ -283,12 +565,25 @@ func (d *Debugger) FunctionArguments(threadID int) ([]api.Variable, error) {
 	return vars, err
 }
 
-func (d *Debugger) EvalVariableInThread(threadID int, symbol string) (*api.Variable, error) {
+// EvalVariableInThread evaluates symbol in the context of the thread,
+// loading its value as configured by cfg and printing its integers with
+// format, one of the letters of the /f modifiers of gdb's print command.
+// The pretty-printers of well-known types are bypassed if raw is set.
+func (d *Debugger) EvalVariableInThread(threadID int, symbol string, cfg *api.LoadConfig, format string, raw bool) (*api.Variable, error) {
+	f, err := proc.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
 	thread, found := d.process.Threads[threadID]
 	if !found {
 		return nil, fmt.Errorf("couldn't find thread %d", threadID)
 	}
-	v, err := thread.EvalVariable(symbol)
+	lcfg, err := d.loadConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	lcfg.Raw, lcfg.Format = lcfg.Raw || raw, f
+	v, err := thread.EvalExpression(symbol, lcfg)
 	if err != nil {
 		return nil, err
 	}
@@ -296,60 +591,465 @@ func (d *Debugger) EvalVariableInThread(threadID int, symbol string) (*api.Varia
 	return &converted, err
 }
 
-func (d *Debugger) Goroutines() ([]*api.Goroutine, error) {
-	goroutines := []*api.Goroutine{}
-	gs, err := d.process.GoroutinesInfo()
+// LoadVariable loads the value of type typ at addr in the context of the
+// thread, for variables whose children were left unloaded.
+func (d *Debugger) LoadVariable(threadID int, addr uint64, typ string, cfg *api.LoadConfig) (*api.Variable, error) {
+	thread, found := d.process.Threads[threadID]
+	if !found {
+		return nil, fmt.Errorf("couldn't find thread %d", threadID)
+	}
+	lcfg, err := d.loadConfig(cfg)
 	if err != nil {
 		return nil, err
 	}
-	for _, g := range gs {
-		goroutines = append(goroutines, api.ConvertGoroutine(g))
+	v, err := thread.LoadVariable(addr, typ, lcfg)
+	if err != nil {
+		return nil, err
 	}
-	return goroutines, err
+	converted := api.ConvertVar(v)
+	return &converted, nil
 }
 
-func (d *Debugger) Stacktrace(goroutineId, depth int) ([]api.Location, error) {
-	var rawlocs []proc.Location
-	var rawloc *proc.Location
-	var err error
+// DumpVariable evaluates expr in the context of the thread and returns
+// the whole graph of its value as a JSON document, see
+// proc.EvalScope.DumpExpression.
+func (d *Debugger) DumpVariable(threadID int, expr string) ([]byte, error) {
+	thread, found := d.process.Threads[threadID]
+	if !found {
+		return nil, fmt.Errorf("couldn't find thread %d", threadID)
+	}
+	var buf bytes.Buffer
+	if err := thread.DumpExpression(expr, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
-	if goroutineId < 0 {
-		rawlocs, err = d.process.CurrentThread.Stacktrace(depth)
+// loadConfig returns the configuration cfg describes, the default one if
+// cfg is nil.
+func (d *Debugger) loadConfig(cfg *api.LoadConfig) (proc.LoadConfig, error) {
+	if cfg == nil {
+		return d.process.DefaultLoadConfig(), nil
+	}
+	if cfg.MaxVariableRecurse < 0 || cfg.MaxStringLen < 0 || cfg.MaxArrayValues < 0 || cfg.MaxMapValues < 0 {
+		return proc.LoadConfig{}, fmt.Errorf("invalid load configuration %+v: limits can't be negative", *cfg)
+	}
+	return api.LoadConfigToProc(cfg), nil
+}
+
+// ChannelInThread returns the state of the channel variable symbol in the
+// context of the thread.
+func (d *Debugger) ChannelInThread(threadID int, symbol string) (*api.Channel, error) {
+	thread, found := d.process.Threads[threadID]
+	if !found {
+		return nil, fmt.Errorf("couldn't find thread %d", threadID)
+	}
+	ch, err := thread.ChannelInfo(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return api.ConvertChannel(ch), nil
+}
+
+// Default number of frames compared when grouping goroutines.
+const defaultGroupDepth = 10
+
+// Goroutines returns the goroutines selected by opts.
+func (d *Debugger) Goroutines(opts *api.ListGoroutinesOptions) (*api.GoroutineList, error) {
+	if opts == nil {
+		opts = &api.ListGoroutinesOptions{}
+	}
+	selected, err := d.selectGoroutines(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &api.GoroutineList{}
+	if !opts.Group {
+		list.Total = len(selected)
+		start, end := page(len(selected), opts.Start, opts.Count)
+		list.Goroutines = []*api.Goroutine{}
+		for _, g := range selected[start:end] {
+			list.Goroutines = append(list.Goroutines, api.ConvertGoroutine(g))
+		}
+		return list, nil
+	}
+
+	depth := opts.GroupDepth
+	if depth <= 0 {
+		depth = defaultGroupDepth
+	}
+	var (
+		groups  []*api.GoroutineGroup
+		byStack = map[string]*api.GoroutineGroup{}
+	)
+	for _, g := range selected {
+		frames, err := d.process.GoroutineStackframes(g, depth-1)
 		if err != nil {
 			return nil, err
 		}
-		rawloc, err = d.process.CurrentThread.Location()
+		stack := stackLocations(frames)
+		key := stackKey(stack)
+		group, ok := byStack[key]
+		if !ok {
+			group = &api.GoroutineGroup{Stack: stack}
+			byStack[key] = group
+			groups = append(groups, group)
+		}
+		group.Goroutines = append(group.Goroutines, g.Id)
+	}
+	sort.Stable(bySize(groups))
+
+	list.Total = len(groups)
+	start, end := page(len(groups), opts.Start, opts.Count)
+	list.Groups = groups[start:end]
+	return list, nil
+}
+
+// GoroutinesStacks returns the stacks of the goroutines selected by opts,
+// at most depth frames deep. Grouping options are ignored.
+func (d *Debugger) GoroutinesStacks(opts *api.ListGoroutinesOptions, depth int) ([]api.GoroutineStack, error) {
+	if opts == nil {
+		opts = &api.ListGoroutinesOptions{}
+	}
+	selected, err := d.selectGoroutines(opts)
+	if err != nil {
+		return nil, err
+	}
+	start, end := page(len(selected), opts.Start, opts.Count)
+
+	stacks := make([]api.GoroutineStack, 0, end-start)
+	for _, g := range selected[start:end] {
+		stack := api.GoroutineStack{Goroutine: *api.ConvertGoroutine(g)}
+		frames, err := d.process.GoroutineStackframes(g, depth-1)
 		if err != nil {
-			return nil, err
+			// Report what we have, a goroutine with an unreadable stack
+			// must not prevent the others from being dumped.
+			stack.Stack = []api.Location{api.ConvertLocation(*d.process.GoroutineLocation(g))}
+			stack.Err = err.Error()
+		} else {
+			stack.Stack = stackLocations(frames)
 		}
+		stacks = append(stacks, stack)
+	}
+	return stacks, nil
+}
+
+// selectGoroutines returns the goroutines matching the filters of opts.
+func (d *Debugger) selectGoroutines(opts *api.ListGoroutinesOptions) ([]*proc.G, error) {
+	filter, err := newGoroutineFilter(opts)
+	if err != nil {
+		return nil, err
+	}
+	gs, err := d.process.GoroutinesInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []*proc.G
+	for _, g := range gs {
+		if filter.match(d.process, g) {
+			selected = append(selected, g)
+		}
+	}
+	return selected, nil
+}
+
+// goroutineFilter selects goroutines according to a ListGoroutinesOptions.
+type goroutineFilter struct {
+	waitReason, status string
+	threadID           int
+	locked             bool
+
+	hasLabel, matchValue bool
+	labelKey, labelValue string
+
+	function   *regexp.Regexp
+	currentLoc *regexp.Regexp
+	userLoc    *regexp.Regexp
+	startLoc   *regexp.Regexp
+}
+
+func newGoroutineFilter(opts *api.ListGoroutinesOptions) (*goroutineFilter, error) {
+	f := &goroutineFilter{
+		waitReason: opts.WaitReason,
+		status:     opts.Status,
+		threadID:   opts.ThreadID,
+		locked:     opts.LockedToThread,
+	}
+	if opts.Label != "" {
+		f.hasLabel = true
+		if i := strings.Index(opts.Label, "="); i >= 0 {
+			f.labelKey, f.labelValue, f.matchValue = opts.Label[:i], opts.Label[i+1:], true
+		} else {
+			f.labelKey = opts.Label
+		}
+	}
+	for _, re := range []struct {
+		dst  **regexp.Regexp
+		expr string
+	}{
+		{&f.function, opts.Function},
+		{&f.currentLoc, opts.CurrentLocation},
+		{&f.userLoc, opts.UserLocation},
+		{&f.startLoc, opts.StartLocation},
+	} {
+		if re.expr == "" {
+			continue
+		}
+		var err error
+		if *re.dst, err = regexp.Compile(re.expr); err != nil {
+			return nil, fmt.Errorf("invalid filter regexp %q: %v", re.expr, err)
+		}
+	}
+	return f, nil
+}
+
+func (f *goroutineFilter) match(p *proc.Process, g *proc.G) bool {
+	if f.waitReason != "" && g.WaitReason != f.waitReason {
+		return false
+	}
+	if f.status != "" && g.StatusString() != f.status {
+		return false
+	}
+	if f.threadID != 0 && g.ThreadID != f.threadID {
+		return false
+	}
+	if f.locked && !g.LockedToThread {
+		return false
+	}
+	if f.hasLabel {
+		v, ok := g.Labels[f.labelKey]
+		if !ok || (f.matchValue && v != f.labelValue) {
+			return false
+		}
+	}
+	if f.startLoc != nil && !f.startLoc.MatchString(formatLocation(&proc.Location{PC: g.GoPC, File: g.File, Line: g.Line, Fn: g.Func})) {
+		return false
+	}
+	if f.function != nil || f.currentLoc != nil {
+		loc := p.GoroutineLocation(g)
+		if f.function != nil && (loc.Fn == nil || !f.function.MatchString(loc.Fn.Name)) {
+			return false
+		}
+		if f.currentLoc != nil && !f.currentLoc.MatchString(formatLocation(loc)) {
+			return false
+		}
+	}
+	if f.userLoc != nil && !f.userLoc.MatchString(formatLocation(p.GoroutineUserLocation(g))) {
+		return false
+	}
+	return true
+}
+
+// formatLocation formats loc as "file:line function", the string
+// location filters are matched against.
+func formatLocation(loc *proc.Location) string {
+	name := ""
+	if loc.Fn != nil {
+		name = loc.Fn.Name
+	}
+	return fmt.Sprintf("%s:%d %s", loc.File, loc.Line, name)
+}
+
+func stackKey(stack []api.Location) string {
+	var buf bytes.Buffer
+	for i := range stack {
+		fmt.Fprintf(&buf, "%#x ", stack[i].PC)
+	}
+	return buf.String()
+}
+
+// page returns the bounds of the page of a list of n elements starting at
+// start and containing at most count elements, all of them if count is zero.
+func page(n, start, count int) (int, int) {
+	if start < 0 {
+		start = 0
+	}
+	if start > n {
+		start = n
+	}
+	end := n
+	if count > 0 && start+count < n {
+		end = start + count
+	}
+	return start, end
+}
+
+type bySize []*api.GoroutineGroup
+
+func (a bySize) Len() int           { return len(a) }
+func (a bySize) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a bySize) Less(i, j int) bool { return len(a[i].Goroutines) > len(a[j].Goroutines) }
+
+// Stacktrace returns the innermost frame of the stack of the goroutine,
+// or of the current thread if goroutineId is negative, followed by at most
+// depth of its callers. If full is true the arguments and local variables
+// of each frame are included.
+func (d *Debugger) Stacktrace(goroutineId, depth int, full bool) ([]api.Stackframe, error) {
+	var (
+		frames []proc.Stackframe
+		err    error
+	)
+
+	if goroutineId < 0 {
+		frames, err = d.process.CurrentThread.Stackframes(depth)
 	} else {
-		gs, err := d.process.GoroutinesInfo()
+		var gs []*proc.G
+		gs, err = d.process.GoroutinesInfo()
 		if err != nil {
 			return nil, err
 		}
+		var found bool
 		for _, g := range gs {
 			if g.Id == goroutineId {
-				rawlocs, err = d.process.GoroutineStacktrace(g, depth)
-				if err != nil {
-					return nil, err
-				}
-				rawloc = d.process.GoroutineLocation(g)
+				frames, err = d.process.GoroutineStackframes(g, depth)
+				found = true
 				break
 			}
 		}
-
-		if rawlocs == nil {
+		if !found {
 			return nil, fmt.Errorf("Unknown goroutine id %d\n", goroutineId)
 		}
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	return d.convertStackframes(frames, full), nil
+}
+
+func (d *Debugger) convertStackframes(rawframes []proc.Stackframe, full bool) []api.Stackframe {
+	frames := make([]api.Stackframe, 0, len(rawframes))
+	for i := range rawframes {
+		frame := api.Stackframe{Location: api.ConvertLocation(rawframes[i].Call), CFA: rawframes[i].CFA, Inlined: rawframes[i].Inlined}
+		// The variables of inlined calls aren't read yet, the ones of the
+		// physical frame belong to its outermost function.
+		if full && !rawframes[i].Inlined {
+			scope := rawframes[i].Scope(d.process.CurrentThread)
+			if args, err := scope.FunctionArguments(); err == nil {
+				frame.Arguments = convertVars(args)
+			}
+			if locals, err := scope.LocalVariables(); err == nil {
+				frame.Locals = convertVars(locals)
+			}
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+func convertVars(pv []*proc.Variable) []api.Variable {
+	vars := make([]api.Variable, 0, len(pv))
+	for _, v := range pv {
+		vars = append(vars, api.ConvertVar(v))
+	}
+	return vars
+}
+
+// stackLocations returns the call site of each frame.
+func stackLocations(frames []proc.Stackframe) []api.Location {
+	locations := make([]api.Location, 0, len(frames))
+	for i := range frames {
+		locations = append(locations, api.ConvertLocation(frames[i].Call))
+	}
+	return locations
+}
 
-	locations := make([]api.Location, 0, len(rawlocs)+1)
+// RuntimeInfo returns the state of the scheduler, the garbage collector and
+// the memory allocator of the runtime of the process.
+func (d *Debugger) RuntimeInfo() (*api.RuntimeInfo, error) {
+	info, err := d.process.RuntimeInfo()
+	if err != nil {
+		return nil, err
+	}
+	return api.ConvertRuntimeInfo(info), nil
+}
+
+// Deadlock returns the goroutines blocked on channels and synchronization
+// objects, grouped by wait reason and object, and the cycles in their
+// wait-for graph.
+func (d *Debugger) Deadlock() (*api.DeadlockReport, error) {
+	graph, err := d.process.WaitGraph()
+	if err != nil {
+		return nil, err
+	}
+	report := &api.DeadlockReport{
+		Blocked:    []*api.BlockedGoroutine{},
+		Groups:     []*api.BlockedGroup{},
+		Cycles:     graph.Cycles,
+		AllBlocked: graph.Runnable == 0,
+	}
+	for _, bg := range graph.Blocked {
+		abg := api.ConvertBlockedG(bg)
+		report.Blocked = append(report.Blocked, abg)
 
-	locations = append(locations, api.ConvertLocation(*rawloc))
-	for i := range rawlocs {
-		rawlocs[i].Line--
-		locations = append(locations, api.ConvertLocation(rawlocs[i]))
+		var group *api.BlockedGroup
+		for _, gr := range report.Groups {
+			if gr.WaitReason == abg.WaitReason && gr.ObjectKind == abg.ObjectKind && gr.ObjectAddr == abg.ObjectAddr {
+				group = gr
+				break
+			}
+		}
+		if group == nil {
+			group = &api.BlockedGroup{WaitReason: abg.WaitReason, ObjectKind: abg.ObjectKind, ObjectAddr: abg.ObjectAddr}
+			report.Groups = append(report.Groups, group)
+		}
+		group.Goroutines = append(group.Goroutines, abg.ID)
 	}
+	report.Dot = waitGraphDot(report)
+	return report, nil
+}
 
-	return locations, nil
+// waitGraphDot renders the wait-for graph of report in Graphviz DOT format.
+// Goroutines point to the object they are blocked on, objects point to the
+// goroutines referencing them. Goroutines part of a cycle are drawn in red.
+func waitGraphDot(report *api.DeadlockReport) string {
+	incycle := map[int]bool{}
+	for _, cycle := range report.Cycles {
+		for _, id := range cycle {
+			incycle[id] = true
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "digraph waitfor {\n")
+	for _, bg := range report.Blocked {
+		label := fmt.Sprintf("goroutine %d\\n%s", bg.ID, bg.WaitReason)
+		if bg.Location != nil {
+			label += fmt.Sprintf("\\n%s:%d", filepath.Base(bg.Location.File), bg.Location.Line)
+		}
+		attrs := ""
+		if incycle[bg.ID] {
+			attrs = ",color=red"
+		}
+		fmt.Fprintf(&buf, "\tg%d [label=\"%s\"%s];\n", bg.ID, label, attrs)
+	}
+	for _, group := range report.Groups {
+		if group.ObjectAddr == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\tobj%#x [shape=box,label=\"%s %#x\"];\n", group.ObjectAddr, group.ObjectKind, group.ObjectAddr)
+	}
+	for _, bg := range report.Blocked {
+		if bg.ObjectAddr == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\tg%d -> obj%#x;\n", bg.ID, bg.ObjectAddr)
+	}
+	for _, group := range report.Groups {
+		if group.ObjectAddr == 0 {
+			continue
+		}
+		for _, bg := range report.Blocked {
+			if bg.ObjectAddr == group.ObjectAddr {
+				for _, id := range bg.WaitsOn {
+					fmt.Fprintf(&buf, "\tobj%#x -> g%d [style=dashed];\n", group.ObjectAddr, id)
+				}
+				break
+			}
+		}
+	}
+	fmt.Fprintf(&buf, "}\n")
+	return buf.String()
 }
@@ -117,6 +117,36 @@ func (c *RPCClient) EvalVariable(symbol string) (*api.Variable, error) {
 	return v, err
 }
 
+func (c *RPCClient) EvalVariableCfg(symbol string, cfg api.LoadConfig) (*api.Variable, error) {
+	v := new(api.Variable)
+	err := c.call("EvalSymbolCfg", &EvalSymbolArgs{Symbol: symbol, Cfg: &cfg}, v)
+	return v, err
+}
+
+func (c *RPCClient) EvalVariableFormat(symbol, format string) (*api.Variable, error) {
+	v := new(api.Variable)
+	err := c.call("EvalSymbolCfg", &EvalSymbolArgs{Symbol: symbol, Format: format}, v)
+	return v, err
+}
+
+func (c *RPCClient) EvalVariableRaw(symbol, format string) (*api.Variable, error) {
+	v := new(api.Variable)
+	err := c.call("EvalSymbolCfg", &EvalSymbolArgs{Symbol: symbol, Format: format, Raw: true}, v)
+	return v, err
+}
+
+func (c *RPCClient) LoadVariable(addr uint64, typ string, cfg api.LoadConfig) (*api.Variable, error) {
+	v := new(api.Variable)
+	err := c.call("LoadVariable", &LoadVariableArgs{Addr: addr, Type: typ, Cfg: &cfg}, v)
+	return v, err
+}
+
+func (c *RPCClient) DumpVariable(expr string) ([]byte, error) {
+	var data []byte
+	err := c.call("DumpVariable", expr, &data)
+	return data, err
+}
+
 func (c *RPCClient) EvalVariableFor(threadID int, symbol string) (*api.Variable, error) {
 	v := new(api.Variable)
 	err := c.call("EvalThreadSymbol", threadID, v)
@@ -165,16 +195,74 @@ func (c *RPCClient) ListFunctionArgs() ([]api.Variable, error) {
 	return vars, err
 }
 
-func (c *RPCClient) ListGoroutines() ([]*api.Goroutine, error) {
-	var goroutines []*api.Goroutine
-	err := c.call("ListGoroutines", nil, &goroutines)
-	return goroutines, err
+func (c *RPCClient) ListGoroutines(opts *api.ListGoroutinesOptions) (*api.GoroutineList, error) {
+	list := new(api.GoroutineList)
+	err := c.call("ListGoroutines", opts, list)
+	return list, err
+}
+
+func (c *RPCClient) Stacktrace(goroutineId, depth int, full bool) ([]api.Stackframe, error) {
+	var frames []api.Stackframe
+	err := c.call("StacktraceGoroutine", &StacktraceGoroutineArgs{Id: goroutineId, Depth: depth, Full: full}, &frames)
+	return frames, err
+}
+
+func (c *RPCClient) GoroutinesStacks(opts *api.ListGoroutinesOptions, depth int) ([]api.GoroutineStack, error) {
+	var stacks []api.GoroutineStack
+	err := c.call("GoroutinesStacks", &GoroutinesStacksArgs{Options: opts, Depth: depth}, &stacks)
+	return stacks, err
+}
+
+func (c *RPCClient) Channel(symbol string) (*api.Channel, error) {
+	ch := new(api.Channel)
+	err := c.call("Channel", symbol, ch)
+	return ch, err
+}
+
+func (c *RPCClient) WhatIs(expr string) (string, error) {
+	var typ string
+	err := c.call("WhatIs", expr, &typ)
+	return typ, err
+}
+
+func (c *RPCClient) ListTypes(filter string) ([]string, error) {
+	var types []string
+	err := c.call("ListTypes", filter, &types)
+	return types, err
+}
+
+func (c *RPCClient) DescribeType(name string) (*api.TypeInfo, error) {
+	info := new(api.TypeInfo)
+	err := c.call("DescribeType", name, info)
+	return info, err
+}
+
+func (c *RPCClient) AddDisplay(expr, format string) (*api.Display, error) {
+	disp := new(api.Display)
+	err := c.call("AddDisplay", &AddDisplayArgs{Expr: expr, Format: format}, disp)
+	return disp, err
+}
+
+func (c *RPCClient) RemoveDisplay(id int) error {
+	return c.call("RemoveDisplay", id, nil)
+}
+
+func (c *RPCClient) ListDisplays() ([]api.Display, error) {
+	var displays []api.Display
+	err := c.call("ListDisplays", nil, &displays)
+	return displays, err
+}
+
+func (c *RPCClient) Deadlock() (*api.DeadlockReport, error) {
+	report := new(api.DeadlockReport)
+	err := c.call("Deadlock", nil, report)
+	return report, err
 }
 
-func (c *RPCClient) Stacktrace(goroutineId, depth int) ([]*api.Location, error) {
-	var locations []*api.Location
-	err := c.call("StacktraceGoroutine", &StacktraceGoroutineArgs{Id: 1, Depth: depth}, &locations)
-	return locations, err
+func (c *RPCClient) RuntimeInfo() (*api.RuntimeInfo, error) {
+	info := new(api.RuntimeInfo)
+	err := c.call("RuntimeInfo", nil, info)
+	return info, err
 }
 
 func (c *RPCClient) url(path string) string {
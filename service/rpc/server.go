@@ -48,8 +48,9 @@ func (s *RPCServer) Run() error {
 	var err error
 	// Create and start the debugger
 	if s.debugger, err = debugger.New(&debugger.Config{
-		ProcessArgs: s.config.ProcessArgs,
-		AttachPid:   s.config.AttachPid,
+		ProcessArgs:  s.config.ProcessArgs,
+		AttachPid:    s.config.AttachPid,
+		MaxMapValues: s.config.MaxMapValues,
 	}); err != nil {
 		return err
 	}
@@ -100,14 +101,20 @@ func (s *RPCServer) GetBreakpoint(id int, breakpoint *api.Breakpoint) error {
 
 type StacktraceGoroutineArgs struct {
 	Id, Depth int
+	Full      bool
 }
 
-func (s *RPCServer) StacktraceGoroutine(args *StacktraceGoroutineArgs, locations *[]api.Location) error {
-	locs, err := s.debugger.Stacktrace(args.Id, args.Depth)
+type GoroutinesStacksArgs struct {
+	Options *api.ListGoroutinesOptions
+	Depth   int
+}
+
+func (s *RPCServer) StacktraceGoroutine(args *StacktraceGoroutineArgs, frames *[]api.Stackframe) error {
+	f, err := s.debugger.Stacktrace(args.Id, args.Depth, args.Full)
 	if err != nil {
 		return err
 	}
-	*locations = locs
+	*frames = f
 	return nil
 }
 
@@ -153,12 +160,7 @@ func (s *RPCServer) GetThread(id int, thread *api.Thread) error {
 }
 
 func (s *RPCServer) ListPackageVars(filter string, variables *[]api.Variable) error {
-	state, err := s.debugger.State()
-	if err != nil {
-		return err
-	}
-
-	current := state.CurrentThread
+	current := s.debugger.CurrentThread()
 	if current == nil {
 		return fmt.Errorf("no current thread")
 	}
@@ -190,12 +192,12 @@ func (s *RPCServer) ListThreadPackageVars(args *ThreadListArgs, variables *[]api
 }
 
 func (s *RPCServer) ListRegisters(arg interface{}, registers *string) error {
-	state, err := s.debugger.State()
-	if err != nil {
-		return err
+	current := s.debugger.CurrentThread()
+	if current == nil {
+		return errors.New("no current thread")
 	}
 
-	regs, err := s.debugger.Registers(state.CurrentThread.ID)
+	regs, err := s.debugger.Registers(current.ID)
 	if err != nil {
 		return err
 	}
@@ -204,12 +206,12 @@ func (s *RPCServer) ListRegisters(arg interface{}, registers *string) error {
 }
 
 func (s *RPCServer) ListLocalVars(arg interface{}, variables *[]api.Variable) error {
-	state, err := s.debugger.State()
-	if err != nil {
-		return err
+	current := s.debugger.CurrentThread()
+	if current == nil {
+		return errors.New("no current thread")
 	}
 
-	vars, err := s.debugger.LocalVariables(state.CurrentThread.ID)
+	vars, err := s.debugger.LocalVariables(current.ID)
 	if err != nil {
 		return err
 	}
@@ -218,12 +220,12 @@ func (s *RPCServer) ListLocalVars(arg interface{}, variables *[]api.Variable) er
 }
 
 func (s *RPCServer) ListFunctionArgs(arg interface{}, variables *[]api.Variable) error {
-	state, err := s.debugger.State()
-	if err != nil {
-		return err
+	current := s.debugger.CurrentThread()
+	if current == nil {
+		return errors.New("no current thread")
 	}
 
-	vars, err := s.debugger.FunctionArguments(state.CurrentThread.ID)
+	vars, err := s.debugger.FunctionArguments(current.ID)
 	if err != nil {
 		return err
 	}
@@ -232,17 +234,37 @@ func (s *RPCServer) ListFunctionArgs(arg interface{}, variables *[]api.Variable)
 }
 
 func (s *RPCServer) EvalSymbol(symbol string, variable *api.Variable) error {
-	state, err := s.debugger.State()
+	current := s.debugger.CurrentThread()
+	if current == nil {
+		return errors.New("no current thread")
+	}
+
+	v, err := s.debugger.EvalVariableInThread(current.ID, symbol, nil, "", false)
 	if err != nil {
 		return err
 	}
+	*variable = *v
+	return nil
+}
 
-	current := state.CurrentThread
+type EvalSymbolArgs struct {
+	Symbol string
+	Cfg    *api.LoadConfig
+	Format string
+	Raw    bool
+}
+
+// EvalSymbolCfg evaluates a symbol in the current thread, loading its
+// value as configured by args.Cfg, or with the defaults if it's nil, and
+// printing its integers with args.Format. Pretty-printers are bypassed if
+// args.Raw is set.
+func (s *RPCServer) EvalSymbolCfg(args *EvalSymbolArgs, variable *api.Variable) error {
+	current := s.debugger.CurrentThread()
 	if current == nil {
 		return errors.New("no current thread")
 	}
 
-	v, err := s.debugger.EvalVariableInThread(current.ID, symbol)
+	v, err := s.debugger.EvalVariableInThread(current.ID, args.Symbol, args.Cfg, args.Format, args.Raw)
 	if err != nil {
 		return err
 	}
@@ -250,13 +272,51 @@ func (s *RPCServer) EvalSymbol(symbol string, variable *api.Variable) error {
 	return nil
 }
 
+type LoadVariableArgs struct {
+	Addr uint64
+	Type string
+	Cfg  *api.LoadConfig
+}
+
+// LoadVariable loads the value of type args.Type at args.Addr in the
+// current thread, to expand the children of a variable left unloaded.
+func (s *RPCServer) LoadVariable(args *LoadVariableArgs, variable *api.Variable) error {
+	current := s.debugger.CurrentThread()
+	if current == nil {
+		return errors.New("no current thread")
+	}
+
+	v, err := s.debugger.LoadVariable(current.ID, args.Addr, args.Type, args.Cfg)
+	if err != nil {
+		return err
+	}
+	*variable = *v
+	return nil
+}
+
+// DumpVariable writes the whole graph of the value of expr in the current
+// thread as a JSON document to data.
+func (s *RPCServer) DumpVariable(expr string, data *[]byte) error {
+	current := s.debugger.CurrentThread()
+	if current == nil {
+		return errors.New("no current thread")
+	}
+
+	b, err := s.debugger.DumpVariable(current.ID, expr)
+	if err != nil {
+		return err
+	}
+	*data = b
+	return nil
+}
+
 type ThreadSymbolArgs struct {
 	Id     int
 	Symbol string
 }
 
 func (s *RPCServer) EvalThreadSymbol(args *ThreadSymbolArgs, variable *api.Variable) error {
-	v, err := s.debugger.EvalVariableInThread(args.Id, args.Symbol)
+	v, err := s.debugger.EvalVariableInThread(args.Id, args.Symbol, nil, "", false)
 	if err != nil {
 		return err
 	}
@@ -282,11 +342,107 @@ func (s *RPCServer) ListFunctions(filter string, funcs *[]string) error {
 	return nil
 }
 
-func (s *RPCServer) ListGoroutines(arg interface{}, goroutines *[]*api.Goroutine) error {
-	gs, err := s.debugger.Goroutines()
+func (s *RPCServer) ListGoroutines(opts *api.ListGoroutinesOptions, list *api.GoroutineList) error {
+	l, err := s.debugger.Goroutines(opts)
+	if err != nil {
+		return err
+	}
+	*list = *l
+	return nil
+}
+
+func (s *RPCServer) Channel(symbol string, ch *api.Channel) error {
+	current := s.debugger.CurrentThread()
+	if current == nil {
+		return errors.New("no current thread")
+	}
+
+	c, err := s.debugger.ChannelInThread(current.ID, symbol)
+	if err != nil {
+		return err
+	}
+	*ch = *c
+	return nil
+}
+
+func (s *RPCServer) WhatIs(expr string, typ *string) error {
+	current := s.debugger.CurrentThread()
+	if current == nil {
+		return errors.New("no current thread")
+	}
+
+	t, err := s.debugger.WhatIsInThread(current.ID, expr)
+	if err != nil {
+		return err
+	}
+	*typ = t
+	return nil
+}
+
+func (s *RPCServer) ListTypes(filter string, types *[]string) error {
+	ts, err := s.debugger.Types(filter)
+	if err != nil {
+		return err
+	}
+	*types = ts
+	return nil
+}
+
+func (s *RPCServer) DescribeType(name string, info *api.TypeInfo) error {
+	i, err := s.debugger.DescribeType(name)
+	if err != nil {
+		return err
+	}
+	*info = *i
+	return nil
+}
+
+type AddDisplayArgs struct {
+	Expr   string
+	Format string
+}
+
+func (s *RPCServer) AddDisplay(args *AddDisplayArgs, disp *api.Display) error {
+	d, err := s.debugger.AddDisplay(args.Expr, args.Format)
+	if err != nil {
+		return err
+	}
+	*disp = *d
+	return nil
+}
+
+func (s *RPCServer) RemoveDisplay(id int, ret *int) error {
+	return s.debugger.RemoveDisplay(id)
+}
+
+func (s *RPCServer) ListDisplays(arg interface{}, displays *[]api.Display) error {
+	*displays = s.debugger.Displays()
+	return nil
+}
+
+func (s *RPCServer) Deadlock(arg interface{}, report *api.DeadlockReport) error {
+	r, err := s.debugger.Deadlock()
+	if err != nil {
+		return err
+	}
+	*report = *r
+	return nil
+}
+
+func (s *RPCServer) RuntimeInfo(arg interface{}, info *api.RuntimeInfo) error {
+	i, err := s.debugger.RuntimeInfo()
+	if err != nil {
+		return err
+	}
+	*info = *i
+	return nil
+}
+
+func (s *RPCServer) GoroutinesStacks(args *GoroutinesStacksArgs, stacks *[]api.GoroutineStack) error {
+	gs, err := s.debugger.GoroutinesStacks(args.Options, args.Depth)
 	if err != nil {
 		return err
 	}
-	*goroutines = gs
+	*stacks = gs
 	return nil
 }
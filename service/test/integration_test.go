@@ -373,3 +373,163 @@ func TestClientServer_infoArgs(t *testing.T) {
 		}
 	})
 }
+
+func TestClientServer_breakpointInformation(t *testing.T) {
+	withTestClient("testvariables", t, func(c service.Client) {
+		_, err := c.CreateBreakpoint(&api.Breakpoint{FunctionName: "main.foobar", Variables: []string{"baz", "bar.Baz"}, Stacktrace: 2})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		state, err := c.Continue()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v, state: %#v", err, state)
+		}
+
+		info := state.BreakpointInfo
+		if info == nil {
+			t.Fatal("Expected breakpoint information")
+		}
+		if len(info.Stacktrace) != 3 {
+			t.Fatalf("Expected 3 stack frames, got %d %#v", len(info.Stacktrace), info.Stacktrace)
+		}
+		if len(info.Variables) != 2 {
+			t.Fatalf("Expected 2 variables, got %d %#v", len(info.Variables), info.Variables)
+		}
+		if info.Variables[0].Value != "bazburzum" || info.Variables[1].Value != "10" {
+			t.Fatalf("Wrong variable values %#v", info.Variables)
+		}
+
+		// The information collected at the stop is returned as is.
+		state, err = c.GetState()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if state.BreakpointInfo == nil || len(state.BreakpointInfo.Variables) != 2 || state.BreakpointInfo.Variables[0].Value != "bazburzum" {
+			t.Fatalf("Wrong breakpoint information in state %#v", state.BreakpointInfo)
+		}
+	})
+}
+
+func TestClientServer_listGoroutines(t *testing.T) {
+	withTestClient("goroutinestackprog", t, func(c service.Client) {
+		_, err := c.CreateBreakpoint(&api.Breakpoint{FunctionName: "main.stacktraceme"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		state, err := c.Continue()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v, state: %#v", err, state)
+		}
+
+		list, err := c.ListGoroutines(&api.ListGoroutinesOptions{UserLocation: `main\.agoroutine$`, Count: 4})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if list.Total != 10 {
+			t.Fatalf("Expected 10 goroutines, got %d", list.Total)
+		}
+		if len(list.Goroutines) != 4 {
+			t.Fatalf("Expected a page of 4 goroutines, got %d", len(list.Goroutines))
+		}
+
+		list, err = c.ListGoroutines(&api.ListGoroutinesOptions{UserLocation: `main\.agoroutine$`, Group: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if list.Total != 1 || len(list.Groups) != 1 || len(list.Groups[0].Goroutines) != 10 {
+			t.Fatalf("Expected a single group of 10 goroutines, got %#v", list.Groups)
+		}
+	})
+}
+
+func TestClientServer_fullStacktrace(t *testing.T) {
+	withTestClient("testvariables", t, func(c service.Client) {
+		_, err := c.CreateBreakpoint(&api.Breakpoint{FunctionName: "main.foobar"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		state, err := c.Continue()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v, state: %#v", err, state)
+		}
+
+		frames, err := c.Stacktrace(-1, 2, true)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(frames) != 3 {
+			t.Fatalf("Expected 3 frames, got %d", len(frames))
+		}
+		var found bool
+		for _, v := range frames[0].Arguments {
+			if v.Name == "baz" && v.Value == "bazburzum" {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("Argument baz not found in %#v", frames[0].Arguments)
+		}
+		if frames[0].CFA == 0 || frames[1].CFA <= frames[0].CFA {
+			t.Fatalf("Wrong frame addresses %#x %#x", frames[0].CFA, frames[1].CFA)
+		}
+	})
+}
+
+func TestClientServer_display(t *testing.T) {
+	withTestClient("testnextprog", t, func(c service.Client) {
+		fp, err := filepath.Abs("_fixtures/testnextprog.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(fp); err != nil {
+			fp, err = filepath.Abs("../../_fixtures/testnextprog.go")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err = c.CreateBreakpoint(&api.Breakpoint{File: fp, Line: 24})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		state, err := c.Continue()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v, state: %#v", err, state)
+		}
+		disp, err := c.AddDisplay("i", "x")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if disp.Value == nil || disp.Value.Value != "0x0" {
+			t.Fatalf("Unexpected display: %#v", disp)
+		}
+		if _, err := c.AddDisplay("i +", ""); err == nil {
+			t.Fatal("Expected error for invalid expression")
+		}
+
+		state, err = c.Continue()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v, state: %#v", err, state)
+		}
+		if len(state.Displays) != 1 {
+			t.Fatalf("Expected 1 display, got %#v", state.Displays)
+		}
+		if d := state.Displays[0]; d.Value == nil || d.Value.Value != "0x1" || !d.Changed {
+			t.Fatalf("Unexpected display: %#v", d)
+		}
+
+		if err := c.RemoveDisplay(disp.ID); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := c.RemoveDisplay(disp.ID); err == nil {
+			t.Fatal("Expected error removing a display twice")
+		}
+		displays, err := c.ListDisplays()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(displays) != 0 {
+			t.Fatalf("Expected no displays, got %#v", displays)
+		}
+	})
+}
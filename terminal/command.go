@@ -4,13 +4,17 @@ package terminal
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"reflect"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/derekparker/delve/service"
 	"github.com/derekparker/delve/service/api"
@@ -22,6 +26,8 @@ type command struct {
 	aliases []string
 	helpMsg string
 	cmdFn   cmdfunc
+	// Whether the command takes a format, as in print/x.
+	formats bool
 }
 
 // Returns true if the command string matches one of the aliases for this command
@@ -38,28 +44,46 @@ type Commands struct {
 	cmds    []command
 	lastCmd cmdfunc
 	client  service.Client
+
+	// Terminal commands to execute when a breakpoint is hit, by breakpoint ID.
+	bpcmds map[int][]string
+	// Reads an additional line of input, used by commands spanning multiple lines.
+	readLine func(prompt string) (string, error)
+	// Values printed so far, referred to as $1, $2... in expressions.
+	history []api.Variable
 }
 
 // Returns a Commands struct with default commands defined.
 func DebugCommands(client service.Client) *Commands {
-	c := &Commands{client: client}
+	c := &Commands{client: client, bpcmds: make(map[int][]string)}
 
 	c.cmds = []command{
 		{aliases: []string{"help"}, cmdFn: c.help, helpMsg: "Prints the help message."},
 		{aliases: []string{"break", "b"}, cmdFn: breakpoint, helpMsg: "Set break point at the entry point of a function, or at a specific file/line. Example: break foo.go:13"},
-		{aliases: []string{"continue", "c"}, cmdFn: cont, helpMsg: "Run until breakpoint or program termination."},
-		{aliases: []string{"step", "si"}, cmdFn: step, helpMsg: "Single step through program."},
-		{aliases: []string{"next", "n"}, cmdFn: next, helpMsg: "Step over to next source line."},
+		{aliases: []string{"continue", "c"}, cmdFn: c.cont, helpMsg: "Run until breakpoint or program termination."},
+		{aliases: []string{"step", "si"}, cmdFn: c.step, helpMsg: "Single step through program."},
+		{aliases: []string{"next", "n"}, cmdFn: c.next, helpMsg: "Step over to next source line."},
 		{aliases: []string{"threads"}, cmdFn: threads, helpMsg: "Print out info for every traced thread."},
 		{aliases: []string{"thread", "t"}, cmdFn: thread, helpMsg: "Switch to the specified thread."},
 		{aliases: []string{"clear"}, cmdFn: clear, helpMsg: "Deletes breakpoint."},
 		{aliases: []string{"clearall"}, cmdFn: clearAll, helpMsg: "Deletes all breakpoints."},
-		{aliases: []string{"goroutines"}, cmdFn: goroutines, helpMsg: "Print out info for every goroutine."},
-		{aliases: []string{"breakpoints", "bp"}, cmdFn: breakpoints, helpMsg: "Print out info for active breakpoints."},
-		{aliases: []string{"print", "p"}, cmdFn: printVar, helpMsg: "Evaluate a variable."},
+		{aliases: []string{"goroutines"}, cmdFn: goroutines, helpMsg: "goroutines [-t [<depth>] [-format text|json|pprof] [-o <file>]] [-g] [-depth <n>] [-w <wait reason>] [-status <status>] [-l <key>[=<value>]] [-thread <id>] [-locked] [-f <func regexp>] [-r|-u|-s <location regexp>] [-start <n>] [-n <count>]. Print out info for goroutines, filtered by wait reason, status, profiler label, thread, current function, current (-r), user (-u) or start (-s) location. Quote values containing spaces, as in -w \"chan receive\". -g groups goroutines with identical stacks. -t prints their stacks in the SIGQUIT traceback format, as JSON, or writes them as a pprof goroutine profile."},
+		{aliases: []string{"breakpoints", "bp"}, cmdFn: c.breakpoints, helpMsg: "Print out info for active breakpoints."},
+		{aliases: []string{"catch"}, cmdFn: catch, helpMsg: "catch goroutine-create|goroutine-exit [<regexp>]. Stop when a goroutine started by a function matching regexp is created or exits."},
+		{aliases: []string{"commands"}, cmdFn: c.commands, helpMsg: "commands <breakpoint id>. Reads a list of commands, terminated by 'end', to execute every time the breakpoint is hit."},
+		{aliases: []string{"print", "p"}, cmdFn: c.printVar, formats: true, helpMsg: "print[/x|o|b|d|c|s] [-raw] <expr>. Evaluate a Go expression, printing integers in hexadecimal, octal, binary, decimal, as characters, or byte slices as strings. Well-known types such as time.Time are pretty-printed unless -raw is given. Values are numbered and can be referred to as $<n> in later expressions."},
+		{aliases: []string{"display"}, cmdFn: display, formats: true, helpMsg: "display[/x|o|b|d|c|s] [<expr>]. Evaluate and print an expression every time the process stops, highlighting values that changed. Without arguments prints all the display expressions."},
+		{aliases: []string{"undisplay"}, cmdFn: undisplay, helpMsg: "undisplay <id>... Stop printing the display expressions with the given IDs."},
+		{aliases: []string{"dump-var"}, cmdFn: dumpVar, helpMsg: "dump-var <expr> <file>. Write the whole value of an expression to a file as JSON, without truncating it. Pointers are written as references to the values they point to."},
+		{aliases: []string{"whatis"}, cmdFn: whatis, helpMsg: "whatis <expr>. Print the Go type of an expression."},
+		{aliases: []string{"types"}, cmdFn: types, helpMsg: "types [<regexp>]. List the types of the program, optionally filtered by a regular expression."},
+		{aliases: []string{"ptype"}, cmdFn: ptype, helpMsg: "ptype <type name>. Print the definition of a type, with the offset and size of the fields of structs."},
 		{aliases: []string{"info"}, cmdFn: info, helpMsg: "Subcommands: args, funcs, locals, sources, vars, or regs."},
 		{aliases: []string{"exit"}, cmdFn: nullCommand, helpMsg: "Exit the debugger."},
-		{aliases: []string{"stack"}, cmdFn: stackCommand, helpMsg: "stack [<depth> [<goroutine id>]]. Prints stack."},
+		{aliases: []string{"stack"}, cmdFn: stackCommand, helpMsg: "stack [<depth> [<goroutine id>]] [-full]. Prints stack, with -full the arguments and local variables of each frame."},
+		{aliases: []string{"chan"}, cmdFn: channel, helpMsg: "chan <expr>. Print the length, capacity and buffered elements of a channel and the goroutines waiting on it."},
+		{aliases: []string{"runtime"}, cmdFn: runtimeInfo, helpMsg: "Print the state of the scheduler: GOMAXPROCS, the Ps and Ms with their run queues, threads and goroutines, the global run queue, the GC phase and cycle, and the statistics of the memory allocator."},
+		{aliases: []string{"deadlock", "blocked"}, cmdFn: deadlock, helpMsg: "deadlock [<dot file>]. Print blocked goroutines grouped by what they wait on, and the cycles among them. Optionally write the wait-for graph to a Graphviz file."},
 	}
 
 	return c
@@ -90,14 +114,11 @@ func (c *Commands) Find(cmdstr string) cmdfunc {
 		return nullCommand
 	}
 
-	for _, v := range c.cmds {
-		if v.match(cmdstr) {
-			c.lastCmd = v.cmdFn
-			return v.cmdFn
-		}
+	cmdFn, ok := c.lookup(cmdstr)
+	if ok {
+		c.lastCmd = cmdFn
 	}
-
-	return noCmdAvailable
+	return cmdFn
 }
 
 func CommandFunc(fn func() error) cmdfunc {
@@ -180,48 +201,326 @@ func thread(client service.Client, args ...string) error {
 }
 
 func goroutines(client service.Client, args ...string) error {
-	gs, err := client.ListGoroutines()
+	stacks, args, err := parseStacksArgs(args)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("[%d goroutines]\n", len(gs))
-	for _, g := range gs {
+	opts, err := parseGoroutinesArgs(args)
+	if err != nil {
+		return err
+	}
+	if stacks != nil {
+		return goroutinesStacks(client, opts, stacks)
+	}
+	list, err := client.ListGoroutines(opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.Group {
+		fmt.Printf("[%d groups]\n", list.Total)
+		for _, group := range list.Groups {
+			fmt.Printf("%d goroutines: %s\n", len(group.Goroutines), joinIds(group.Goroutines))
+			for i, loc := range group.Stack {
+				name := "(nil)"
+				if loc.Function != nil {
+					name = loc.Function.Name
+				}
+				fmt.Printf("\t%d. %s\n\t\t%s:%d (%#v)\n", i, name, loc.File, loc.Line, loc.PC)
+			}
+		}
+		return nil
+	}
+
+	fmt.Printf("[%d goroutines]\n", list.Total)
+	for _, g := range list.Goroutines {
 		var fname string
 		if g.Function != nil {
 			fname = g.Function.Name
 		}
-		fmt.Printf("Goroutine %d - %s:%d %s (%#v)\n", g.ID, g.File, g.Line, fname, g.PC)
+		fmt.Printf("Goroutine %d - %s:%d %s (%#v) %s\n", g.ID, g.File, g.Line, fname, g.PC, goroutineState(g))
+	}
+	return nil
+}
+
+// Default depth of the stacks printed by goroutines -t.
+const defaultStacksDepth = 50
+
+// stacksArgs are the arguments of goroutines -t.
+type stacksArgs struct {
+	depth  int
+	format string // One of stacksText, stacksJSON or stacksPprof.
+	output string // File the stacks are written to, standard output if empty.
+}
+
+// parseStacksArgs extracts the arguments of goroutines -t from args.
+// Returns nil stacksArgs if -t was not specified.
+func parseStacksArgs(args []string) (*stacksArgs, []string, error) {
+	var (
+		stacks *stacksArgs
+		rest   []string
+		format = stacksText
+		output string
+	)
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-t":
+			stacks = &stacksArgs{depth: defaultStacksDepth}
+			if i+1 < len(args) {
+				if depth, err := strconv.Atoi(args[i+1]); err == nil {
+					stacks.depth = depth
+					i++
+				}
+			}
+		case "-format", "-o":
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("Wrong argument: %s", args[i])
+			}
+			if args[i] == "-o" {
+				output = unquoteArg(args[i+1])
+			} else {
+				format = args[i+1]
+			}
+			i++
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	if stacks == nil {
+		if format != stacksText || output != "" {
+			return nil, nil, fmt.Errorf("-format and -o require -t")
+		}
+		return nil, rest, nil
+	}
+	switch format {
+	case stacksText, stacksJSON:
+	case stacksPprof:
+		if output == "" {
+			return nil, nil, fmt.Errorf("the pprof format requires an output file")
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown format %q", format)
+	}
+	stacks.format, stacks.output = format, output
+	return stacks, rest, nil
+}
+
+func goroutinesStacks(client service.Client, opts *api.ListGoroutinesOptions, args *stacksArgs) error {
+	stacks, err := client.GoroutinesStacks(opts, args.depth)
+	if err != nil {
+		return err
+	}
+	if args.output == "" {
+		return writeStacks(os.Stdout, args.format, stacks)
+	}
+	f, err := os.Create(args.output)
+	if err != nil {
+		return err
+	}
+	if err := writeStacks(f, args.format, stacks); err != nil {
+		f.Close()
+		return err
 	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("%d goroutine stacks written to %s\n", len(stacks), args.output)
 	return nil
 }
 
-func cont(client service.Client, args ...string) error {
+// goroutineState describes the scheduling state of g.
+func goroutineState(g *api.Goroutine) string {
+	var buf bytes.Buffer
+	buf.WriteString("[" + g.Status)
+	if g.WaitReason != "" {
+		fmt.Fprintf(&buf, " %q", g.WaitReason)
+	}
+	if g.WaitTime >= time.Second {
+		fmt.Fprintf(&buf, " for %s", g.WaitTime-g.WaitTime%time.Second)
+	}
+	if g.ThreadID != 0 {
+		fmt.Fprintf(&buf, " thread %d", g.ThreadID)
+	}
+	if g.LockedToThread {
+		buf.WriteString(" locked")
+	}
+	buf.WriteString("]")
+	if len(g.Labels) > 0 {
+		keys := make([]string, 0, len(g.Labels))
+		for k := range g.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i := range keys {
+			keys[i] += "=" + g.Labels[keys[i]]
+		}
+		fmt.Fprintf(&buf, " {%s}", strings.Join(keys, ", "))
+	}
+	if g.StackHi != 0 {
+		fmt.Fprintf(&buf, " stack [%#x, %#x)", g.StackLo, g.StackHi)
+	}
+	return buf.String()
+}
+
+// parseGoroutinesArgs parses the arguments of the goroutines command.
+func parseGoroutinesArgs(args []string) (*api.ListGoroutinesOptions, error) {
+	opts := &api.ListGoroutinesOptions{}
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-g":
+			opts.Group = true
+			continue
+		case "-locked":
+			opts.LockedToThread = true
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("Wrong argument: %s", args[i])
+		}
+		arg := unquoteArg(args[i+1])
+		var err error
+		switch args[i] {
+		case "-w":
+			opts.WaitReason = arg
+		case "-status":
+			opts.Status = arg
+		case "-l":
+			opts.Label = arg
+		case "-thread":
+			opts.ThreadID, err = strconv.Atoi(arg)
+		case "-f":
+			opts.Function = arg
+		case "-r":
+			opts.CurrentLocation = arg
+		case "-u":
+			opts.UserLocation = arg
+		case "-s":
+			opts.StartLocation = arg
+		case "-depth":
+			opts.GroupDepth, err = strconv.Atoi(arg)
+		case "-start":
+			opts.Start, err = strconv.Atoi(arg)
+		case "-n":
+			opts.Count, err = strconv.Atoi(arg)
+		default:
+			return nil, fmt.Errorf("Wrong argument: %s", args[i])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Wrong argument: expected integer")
+		}
+		i++
+	}
+	return opts, nil
+}
+
+func (c *Commands) cont(client service.Client, args ...string) error {
 	state, err := client.Continue()
 	if err != nil {
 		return err
 	}
 	printcontext(state)
-	return nil
+	printDisplays(state.Displays)
+	return c.runBreakpointCommands(client, state)
 }
 
-func step(client service.Client, args ...string) error {
+func (c *Commands) step(client service.Client, args ...string) error {
 	state, err := client.Step()
 	if err != nil {
 		return err
 	}
 	printcontext(state)
-	return nil
+	printDisplays(state.Displays)
+	return c.runBreakpointCommands(client, state)
 }
 
-func next(client service.Client, args ...string) error {
+func (c *Commands) next(client service.Client, args ...string) error {
 	state, err := client.Next()
 	if err != nil {
 		return err
 	}
 	printcontext(state)
+	printDisplays(state.Displays)
+	return c.runBreakpointCommands(client, state)
+}
+
+func (c *Commands) commands(client service.Client, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("you must specify a breakpoint")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+	if _, err := client.GetBreakpoint(id); err != nil {
+		return err
+	}
+	if c.readLine == nil {
+		return fmt.Errorf("no input available to read commands from")
+	}
+
+	fmt.Printf("Type commands for breakpoint %d, one per line.\nEnd with a line saying just \"end\".\n", id)
+	var cmds []string
+	for {
+		line, err := c.readLine(">")
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if line == "end" {
+			break
+		}
+		if line != "" {
+			cmds = append(cmds, line)
+		}
+	}
+
+	if len(cmds) == 0 {
+		delete(c.bpcmds, id)
+		return nil
+	}
+	c.bpcmds[id] = cmds
 	return nil
 }
 
+// runBreakpointCommands executes the commands attached to the breakpoint
+// the process is stopped at, if any.
+func (c *Commands) runBreakpointCommands(client service.Client, state *api.DebuggerState) error {
+	if state.Breakpoint == nil {
+		return nil
+	}
+	for _, cmdstr := range c.bpcmds[state.Breakpoint.ID] {
+		cmdstr, args := parseCommand(cmdstr)
+		cmdFn, _ := c.lookup(cmdstr)
+		if err := cmdFn(client, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup returns the command function for cmdstr, without recording it
+// as the last executed command. A format, as in print/x, is passed as the
+// first argument. Returns false if there is no such command.
+func (c *Commands) lookup(cmdstr string) (cmdfunc, bool) {
+	name, format := cmdstr, ""
+	if i := strings.Index(cmdstr, "/"); i > 0 {
+		name, format = cmdstr[:i], cmdstr[i:]
+	}
+
+	for _, v := range c.cmds {
+		if v.match(cmdstr) {
+			return v.cmdFn, true
+		}
+		if format != "" && v.formats && v.match(name) {
+			cmdFn := v.cmdFn
+			return func(client service.Client, args ...string) error {
+				return cmdFn(client, append([]string{format}, args...)...)
+			}, true
+		}
+	}
+	return noCmdAvailable, false
+}
+
 func clear(client service.Client, args ...string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("not enough arguments")
@@ -261,14 +560,34 @@ func (a ById) Len() int           { return len(a) }
 func (a ById) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ById) Less(i, j int) bool { return a[i].ID < a[j].ID }
 
-func breakpoints(client service.Client, args ...string) error {
+func (c *Commands) breakpoints(client service.Client, args ...string) error {
 	breakPoints, err := client.ListBreakpoints()
 	if err != nil {
 		return err
 	}
 	sort.Sort(ById(breakPoints))
 	for _, bp := range breakPoints {
-		fmt.Printf("Breakpoint %d at %#v %s:%d\n", bp.ID, bp.Addr, bp.File, bp.Line)
+		kind := "Breakpoint"
+		if bp.Tracepoint {
+			kind = "Tracepoint"
+		}
+		if bp.Catch != "" {
+			fmt.Printf("Catchpoint %d %s %s\n", bp.ID, bp.Catch, bp.CatchFilter)
+		} else {
+			fmt.Printf("%s %d at %#v %s:%d\n", kind, bp.ID, bp.Addr, bp.File, bp.Line)
+		}
+		for _, addr := range bp.InlinedAddrs {
+			fmt.Printf("\tinlined copy at %#v\n", addr)
+		}
+		for _, v := range bp.Variables {
+			fmt.Printf("\tprint %s\n", v)
+		}
+		if bp.Stacktrace > 0 {
+			fmt.Printf("\tstack %d\n", bp.Stacktrace)
+		}
+		for _, cmd := range c.bpcmds[bp.ID] {
+			fmt.Printf("\t%s\n", cmd)
+		}
 	}
 
 	return nil
@@ -301,28 +620,362 @@ func breakpoint(client service.Client, args ...string) error {
 	}
 
 	fmt.Printf("Breakpoint %d set at %#v for %s %s:%d\n", bp.ID, bp.Addr, bp.FunctionName, bp.File, bp.Line)
+	if len(bp.InlinedAddrs) > 0 {
+		fmt.Printf("Also set on %d inlined copies\n", len(bp.InlinedAddrs))
+	}
 	return nil
 }
 
-func printVar(client service.Client, args ...string) error {
+func catch(client service.Client, args ...string) error {
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf("argument must be %s or %s, optionally followed by a regexp", api.GoroutineCreate, api.GoroutineExit)
+	}
+	requestedBp := &api.Breakpoint{Catch: args[0]}
+	if len(args) == 2 {
+		requestedBp.CatchFilter = args[1]
+	}
+
+	bp, err := client.CreateBreakpoint(requestedBp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Catchpoint %d set for %s %s\n", bp.ID, bp.Catch, bp.CatchFilter)
+	return nil
+}
+
+func (c *Commands) printVar(client service.Client, args ...string) error {
+	var (
+		format string
+		raw    bool
+	)
+	for len(args) > 0 {
+		if strings.HasPrefix(args[0], "/") {
+			format, args = args[0][1:], args[1:]
+		} else if args[0] == "-raw" {
+			raw, args = true, args[1:]
+		} else {
+			break
+		}
+	}
 	if len(args) == 0 {
 		return fmt.Errorf("not enough arguments")
 	}
+	expr := strings.Join(args, " ")
 
-	val, err := client.EvalVariable(args[0])
+	var val *api.Variable
+	if n, err := strconv.Atoi(strings.TrimPrefix(expr, "$")); err == nil && strings.HasPrefix(expr, "$") && format == "" && !raw {
+		// Print an earlier value again.
+		if n < 1 || n > len(c.history) {
+			return fmt.Errorf("history has no value $%d", n)
+		}
+		val = &c.history[n-1]
+	} else {
+		resolved, err := resolveHistory(expr, c.history)
+		if err != nil {
+			return err
+		}
+		if raw {
+			val, err = client.EvalVariableRaw(resolved, format)
+		} else {
+			val, err = client.EvalVariableFormat(resolved, format)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	c.history = append(c.history, *val)
+	fmt.Printf("$%d = %s\n", len(c.history), val.Value)
+	return nil
+}
+
+// resolveHistory replaces the references to earlier values in expr, $1 for
+// the first one, by Go expressions with their value, so that they can be
+// evaluated by the debugger. References inside string and character
+// literals are left alone.
+func resolveHistory(expr string, history []api.Variable) (string, error) {
+	var (
+		buf   bytes.Buffer
+		quote byte
+	)
+	for i := 0; i < len(expr); i++ {
+		ch := expr[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && quote != '`' && i+1 < len(expr) {
+				buf.WriteByte(ch)
+				i++
+				ch = expr[i]
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'' || ch == '`':
+			quote = ch
+		case ch == '$':
+			j := i + 1
+			for j < len(expr) && expr[j] >= '0' && expr[j] <= '9' {
+				j++
+			}
+			n, err := strconv.Atoi(expr[i+1 : j])
+			if err != nil {
+				return "", fmt.Errorf("invalid history reference in %s", expr)
+			}
+			if n < 1 || n > len(history) {
+				return "", fmt.Errorf("history has no value $%d", n)
+			}
+			lit, err := historyLiteral(history[n-1])
+			if err != nil {
+				return "", fmt.Errorf("can not use $%d in an expression: %s", n, err)
+			}
+			buf.WriteString(lit)
+			i = j - 1
+			continue
+		}
+		buf.WriteByte(ch)
+	}
+	return buf.String(), nil
+}
+
+// intBits are the sizes of the integer types conversions are supported to.
+var intBits = map[string]uint{
+	"int": 64, "int8": 8, "int16": 16, "int32": 32, "int64": 64,
+	"uint": 64, "uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64, "uintptr": 64,
+	"byte": 8, "rune": 32,
+}
+
+// historyLiteral returns a Go expression with the value of v, printed
+// earlier with any format, if it's a boolean, a number or a string.
+func historyLiteral(v api.Variable) (string, error) {
+	switch v.Kind {
+	case reflect.Bool:
+		return v.Value, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		fields := strings.Fields(v.Value)
+		if len(fields) == 0 {
+			return "", fmt.Errorf("invalid value %q", v.Value)
+		}
+		u, err := strconv.ParseUint(fields[0], 0, 64)
+		if err != nil {
+			n, err := strconv.ParseInt(fields[0], 0, 64)
+			if err != nil {
+				return "", fmt.Errorf("invalid value %q", v.Value)
+			}
+			u = uint64(n)
+		}
+		bits, basic := intBits[v.Type]
+		lit := strconv.FormatUint(u, 10)
+		if v.Kind <= reflect.Int64 {
+			// Hexadecimal, octal and binary formats print the bit
+			// pattern of negative numbers.
+			n := int64(u)
+			if basic && bits < 64 {
+				n = int64(u<<(64-bits)) >> (64 - bits)
+			}
+			lit = strconv.FormatInt(n, 10)
+		}
+		if basic {
+			return fmt.Sprintf("%s(%s)", v.Type, lit), nil
+		}
+		return lit, nil
+	case reflect.Float32, reflect.Float64:
+		if v.Type == "float32" || v.Type == "float64" {
+			return fmt.Sprintf("%s(%s)", v.Type, v.Value), nil
+		}
+		return v.Value, nil
+	case reflect.String:
+		s, err := strconv.Unquote(`"` + v.Value + `"`)
+		if err != nil || int64(len(s)) != v.Len {
+			return "", fmt.Errorf("string was not loaded entirely")
+		}
+		return strconv.Quote(s), nil
+	}
+	return "", fmt.Errorf("type %s is not a boolean, number or string", v.Type)
+}
+
+func display(client service.Client, args ...string) error {
+	var format string
+	if len(args) > 0 && strings.HasPrefix(args[0], "/") {
+		format, args = args[0][1:], args[1:]
+	}
+	if len(args) == 0 {
+		if format != "" {
+			return fmt.Errorf("not enough arguments")
+		}
+		displays, err := client.ListDisplays()
+		if err != nil {
+			return err
+		}
+		printDisplays(displays)
+		return nil
+	}
+
+	disp, err := client.AddDisplay(strings.Join(args, " "), format)
 	if err != nil {
 		return err
 	}
+	printDisplays([]api.Display{*disp})
+	return nil
+}
+
+func undisplay(client service.Client, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("not enough arguments")
+	}
+	for _, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid display id %s", arg)
+		}
+		if err := client.RemoveDisplay(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printDisplays(displays []api.Display) {
+	highlight := isTerminal(os.Stdout)
+	for _, d := range displays {
+		fmt.Println(formatDisplay(d, highlight))
+	}
+}
+
+// isTerminal returns whether f is a terminal rather than a file or a pipe.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// formatDisplay renders the value of a display expression. A value that
+// changed since the previous stop is highlighted, or marked with a * if
+// highlight is false.
+func formatDisplay(d api.Display, highlight bool) string {
+	expr := d.Expr
+	if d.Format != "" {
+		expr = "/" + d.Format + " " + expr
+	}
+	value := fmt.Sprintf("<error: %s>", d.Err)
+	if d.Value != nil {
+		value = d.Value.Value
+	}
+	if d.Changed {
+		if highlight {
+			value = "\033[1;33m" + value + "\033[0m"
+		} else {
+			value += " *"
+		}
+	}
+	return fmt.Sprintf("%d: %s = %s", d.ID, expr, value)
+}
 
-	fmt.Println(val.Value)
+func dumpVar(client service.Client, args ...string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("not enough arguments")
+	}
+	expr, file := strings.Join(args[:len(args)-1], " "), args[len(args)-1]
+	data, err := client.DumpVariable(expr)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Value of %s written to %s\n", expr, file)
 	return nil
 }
 
+func whatis(client service.Client, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("not enough arguments")
+	}
+
+	typ, err := client.WhatIs(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(typ)
+	return nil
+}
+
+func types(client service.Client, args ...string) error {
+	filter := ""
+	if len(args) > 0 {
+		filter = args[0]
+	}
+
+	types, err := client.ListTypes(filter)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range types {
+		fmt.Println(t)
+	}
+	return nil
+}
+
+func ptype(client service.Client, args ...string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("wrong number of arguments. expected ptype <type name>")
+	}
+
+	info, err := client.DescribeType(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(formatTypeInfo(info))
+	return nil
+}
+
+// formatTypeInfo renders the definition of a type, with the offset and
+// size of the fields of structs and the padding between them.
+func formatTypeInfo(info *api.TypeInfo) string {
+	var buf bytes.Buffer
+	if info.Underlying != "struct" {
+		fmt.Fprintf(&buf, "type %s %s // size %d\n", info.Name, info.Underlying, info.Size)
+		return buf.String()
+	}
+
+	var nameWidth, typeWidth int
+	for _, f := range info.Fields {
+		if len(f.Name) > nameWidth {
+			nameWidth = len(f.Name)
+		}
+		if len(f.Type) > typeWidth {
+			typeWidth = len(f.Type)
+		}
+	}
+
+	fmt.Fprintf(&buf, "type %s struct { // size %d\n", info.Name, info.Size)
+	var end int64
+	padding := func(next int64) {
+		if next > end {
+			fmt.Fprintf(&buf, "\t// %d bytes of padding\n", next-end)
+		}
+	}
+	for _, f := range info.Fields {
+		padding(f.Offset)
+		fmt.Fprintf(&buf, "\t%-*s %-*s // offset %d, size %d\n", nameWidth, f.Name, typeWidth, f.Type, f.Offset, f.Size)
+		end = f.Offset + f.Size
+	}
+	padding(info.Size)
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
 func filterVariables(vars []api.Variable, filter *regexp.Regexp) []string {
 	data := make([]string, 0, len(vars))
 	for _, v := range vars {
 		if filter == nil || filter.Match([]byte(v.Name)) {
-			data = append(data, fmt.Sprintf("%s = %s", v.Name, v.Value))
+			name := v.Name
+			if v.Shadowed {
+				name = "(" + name + ")"
+			}
+			data = append(data, fmt.Sprintf("%s = %s", name, v.Value))
 		}
 	}
 	return data
@@ -413,11 +1066,19 @@ func info(client service.Client, args ...string) error {
 }
 
 func stackCommand(client service.Client, args ...string) error {
-	var err error
+	var (
+		err  error
+		full bool
+	)
 
 	goroutineid := -1
 	depth := 10
 
+	if len(args) > 0 && args[len(args)-1] == "-full" {
+		full = true
+		args = args[:len(args)-1]
+	}
+
 	switch len(args) {
 	case 0:
 		// nothing to do
@@ -437,7 +1098,7 @@ func stackCommand(client service.Client, args ...string) error {
 		return fmt.Errorf("Wrong number of arguments to stack")
 	}
 
-	stack, err := client.Stacktrace(goroutineid, depth)
+	stack, err := client.Stacktrace(goroutineid, depth, full)
 	if err != nil {
 		return err
 	}
@@ -446,12 +1107,220 @@ func stackCommand(client service.Client, args ...string) error {
 		if stack[i].Function != nil {
 			name = stack[i].Function.Name
 		}
+		if stack[i].Inlined {
+			name += " (inlined)"
+		}
 		fmt.Printf("%d. %s\n\t%s:%d (%#v)\n", i, name, stack[i].File, stack[i].Line, stack[i].PC)
+		if !full {
+			continue
+		}
+		fmt.Printf("\tframe: %#x\n", stack[i].CFA)
+		for _, v := range stack[i].Arguments {
+			fmt.Printf("\t\t%s = %s\n", v.Name, v.Value)
+		}
+		for _, v := range stack[i].Locals {
+			fmt.Printf("\t\t%s = %s\n", v.Name, v.Value)
+		}
+	}
+	return nil
+}
+
+func channel(client service.Client, args ...string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Wrong number of arguments to chan")
+	}
+	ch, err := client.Channel(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Print(formatChannel(ch))
+	return nil
+}
+
+func formatChannel(ch *api.Channel) string {
+	if ch.Addr == 0 {
+		return fmt.Sprintf("%s nil\n", ch.Type)
+	}
+
+	var buf bytes.Buffer
+	state := "open"
+	if ch.Closed {
+		state = "closed"
+	}
+	fmt.Fprintf(&buf, "%s at %#x, %s\n", ch.Type, ch.Addr, state)
+	fmt.Fprintf(&buf, "len: %d, cap: %d\n", ch.Len, ch.Cap)
+	for i, elem := range ch.Elems {
+		fmt.Fprintf(&buf, "\t[%d] %s\n", i, elem)
+	}
+	printWaiters := func(what string, ids []int) {
+		if len(ids) == 0 {
+			fmt.Fprintf(&buf, "No goroutines waiting to %s\n", what)
+			return
+		}
+		fmt.Fprintf(&buf, "Goroutines waiting to %s: %s\n", what, joinIds(ids))
+	}
+	printWaiters("receive", ch.RecvWaiters)
+	printWaiters("send", ch.SendWaiters)
+	return buf.String()
+}
+
+func runtimeInfo(client service.Client, args ...string) error {
+	info, err := client.RuntimeInfo()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("GOMAXPROCS: %d\n", info.GOMAXPROCS)
+	fmt.Printf("GC: phase %s, cycle %d\n", info.GCPhase, info.GCCycle)
+	fmt.Printf("Global run queue: %d goroutines\n", info.GlobalRunqLen)
+	fmt.Printf("Idle Ps: %d, idle Ms: %d, spinning Ms: %d\n", info.IdlePs, info.IdleMs, info.SpinningMs)
+	fmt.Println("Ps:")
+	for _, p := range info.Ps {
+		fmt.Printf("\t%s\n", formatP(p))
+	}
+	fmt.Println("Ms:")
+	for _, m := range info.Ms {
+		fmt.Printf("\t%s\n", formatM(m))
+	}
+	fmt.Println("Memory statistics:")
+	for _, stat := range info.MemStats {
+		fmt.Printf("\t%s = %d\n", stat.Name, stat.Value)
 	}
 	return nil
 }
 
+func formatP(p api.P) string {
+	s := fmt.Sprintf("P%d %s", p.ID, p.Status)
+	if p.M >= 0 {
+		s += fmt.Sprintf(" on M%d", p.M)
+	}
+	return s + fmt.Sprintf(", %d runnable goroutines", p.RunqLen)
+}
+
+func formatM(m api.M) string {
+	s := fmt.Sprintf("M%d thread %d", m.ID, m.ThreadID)
+	if m.CurG != 0 {
+		s += fmt.Sprintf(", goroutine %d", m.CurG)
+	}
+	if m.P >= 0 {
+		s += fmt.Sprintf(", P%d", m.P)
+	}
+	if m.Spinning {
+		s += ", spinning"
+	}
+	if m.Blocked {
+		s += ", blocked"
+	}
+	return s
+}
+
+func deadlock(client service.Client, args ...string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Wrong number of arguments to deadlock")
+	}
+	report, err := client.Deadlock()
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(formatDeadlock(report))
+
+	if len(args) == 1 {
+		if err := ioutil.WriteFile(args[0], []byte(report.Dot), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Wait-for graph written to %s\n", args[0])
+	}
+	return nil
+}
+
+// formatDeadlock renders the blocked goroutines of report grouped by the
+// object they wait on, followed by the cycles found among them.
+func formatDeadlock(report *api.DeadlockReport) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "[%d blocked goroutines]\n", len(report.Blocked))
+	byId := make(map[int]*api.BlockedGoroutine, len(report.Blocked))
+	for _, bg := range report.Blocked {
+		byId[bg.ID] = bg
+	}
+	for _, group := range report.Groups {
+		kind := group.ObjectKind
+		if kind == "" {
+			kind = "unknown object"
+		}
+		if group.ObjectAddr != 0 {
+			fmt.Fprintf(&buf, "%s on %s %#x:\n", group.WaitReason, kind, group.ObjectAddr)
+		} else {
+			fmt.Fprintf(&buf, "%s on %s:\n", group.WaitReason, kind)
+		}
+		for _, id := range group.Goroutines {
+			bg := byId[id]
+			loc := bg.Location
+			if loc == nil {
+				loc = &api.Location{PC: bg.PC, File: bg.File, Line: bg.Line, Function: bg.Function}
+			}
+			name := "(nil)"
+			if loc.Function != nil {
+				name = loc.Function.Name
+			}
+			fmt.Fprintf(&buf, "\tGoroutine %d - %s:%d %s", bg.ID, loc.File, loc.Line, name)
+			if len(bg.WaitsOn) > 0 {
+				fmt.Fprintf(&buf, " (waits on %s)", joinIds(bg.WaitsOn))
+			}
+			if bg.Error != "" {
+				fmt.Fprintf(&buf, " (error: %s)", bg.Error)
+			}
+			buf.WriteByte('\n')
+		}
+	}
+	for _, cycle := range report.Cycles {
+		fmt.Fprintf(&buf, "Deadlock: goroutines %s are waiting on each other\n", joinIds(cycle))
+	}
+	if report.AllBlocked {
+		buf.WriteString("All goroutines are blocked\n")
+	}
+	return buf.String()
+}
+
+func joinIds(ids []int) string {
+	strs := make([]string, len(ids))
+	for i := range ids {
+		strs[i] = strconv.Itoa(ids[i])
+	}
+	return strings.Join(strs, ", ")
+}
+
+func printBreakpointInfo(info *api.BreakpointInfo) {
+	fmt.Printf("> breakpoint %d on thread %d\n", info.BreakpointID, info.ThreadID)
+	if g := info.Goroutine; g != nil {
+		var fname string
+		if g.Function != nil {
+			fname = g.Function.Name
+		}
+		fmt.Printf("\tGoroutine %d - %s:%d %s\n", g.ID, g.File, g.Line, fname)
+	}
+	for _, v := range info.Variables {
+		fmt.Printf("\t%s: %s\n", v.Name, v.Value)
+	}
+	if len(info.Stacktrace) > 0 {
+		fmt.Printf("\tStack:\n")
+		for i, loc := range info.Stacktrace {
+			name := "(nil)"
+			if loc.Function != nil {
+				name = loc.Function.Name
+			}
+			fmt.Printf("\t\t%d. %s %s:%d (%#v)\n", i, name, loc.File, loc.Line, loc.PC)
+		}
+	}
+}
+
 func printcontext(state *api.DebuggerState) error {
+	for _, info := range state.Tracepoints {
+		printBreakpointInfo(info)
+	}
+	if state.BreakpointInfo != nil {
+		printBreakpointInfo(state.BreakpointInfo)
+	}
+
 	if state.CurrentThread == nil {
 		fmt.Println("No current thread available")
 		return nil
@@ -2,9 +2,12 @@ package terminal
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/derekparker/delve/service"
+	"github.com/derekparker/delve/service/api"
 )
 
 func TestCommandDefault(t *testing.T) {
@@ -67,3 +70,322 @@ func TestCommandThread(t *testing.T) {
 		t.Fatal("wrong command output: ", err.Error())
 	}
 }
+
+func TestCommandBreakpointCommands(t *testing.T) {
+	var (
+		cmds = DebugCommands(nil)
+		cmd  = cmds.Find("commands")
+	)
+
+	err := cmd(nil)
+	if err == nil {
+		t.Fatal("commands terminal command did not default")
+	}
+
+	if err.Error() != "you must specify a breakpoint" {
+		t.Fatal("wrong command output: ", err.Error())
+	}
+}
+
+func TestCommandCatch(t *testing.T) {
+	var (
+		cmds = DebugCommands(nil)
+		cmd  = cmds.Find("catch")
+	)
+
+	err := cmd(nil)
+	if err == nil {
+		t.Fatal("catch terminal command did not default")
+	}
+}
+
+func TestParseGoroutinesArgs(t *testing.T) {
+	cmd, args := parseCommand(`goroutines -g -w "chan receive" -u main\.  -n 10`)
+	if cmd != "goroutines" {
+		t.Fatalf("wrong command %q", cmd)
+	}
+	opts, err := parseGoroutinesArgs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !opts.Group || opts.WaitReason != "chan receive" || opts.UserLocation != `main\.` || opts.Count != 10 {
+		t.Fatalf("wrong options: %#v", opts)
+	}
+
+	if _, err := parseGoroutinesArgs([]string{"-n"}); err == nil {
+		t.Fatal("missing value did not fail")
+	}
+	if _, err := parseGoroutinesArgs([]string{"-start", "x"}); err == nil {
+		t.Fatal("non integer value did not fail")
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	testcases := []struct {
+		cmdstr string
+		args   []string
+	}{
+		{"", nil},
+		{"continue", nil},
+		{"print a + 4", []string{"a", "+", "4"}},
+		{`print s == "a \" b" && r == ' '`, []string{"s", "==", `"a \" b"`, "&&", "r", "==", "' '"}},
+		{"goroutines -l `k=a b`", []string{"-l", "`k=a b`"}},
+	}
+	for _, tc := range testcases {
+		_, args := parseCommand(tc.cmdstr)
+		if len(args) != len(tc.args) || (len(args) > 0 && !reflect.DeepEqual(args, tc.args)) {
+			t.Fatalf("%s: expected %q got %q", tc.cmdstr, tc.args, args)
+		}
+	}
+}
+
+func TestGoroutineState(t *testing.T) {
+	g := &api.Goroutine{Status: "waiting", WaitReason: "chan receive", Labels: map[string]string{"b": "2", "a": "1"}}
+	if s := goroutineState(g); s != `[waiting "chan receive"] {a=1, b=2}` {
+		t.Fatalf("wrong state: %s", s)
+	}
+	g = &api.Goroutine{Status: "running", ThreadID: 42, LockedToThread: true}
+	if s := goroutineState(g); s != `[running thread 42 locked]` {
+		t.Fatalf("wrong state: %s", s)
+	}
+	g = &api.Goroutine{Status: "waiting", WaitReason: "select", WaitTime: 90*time.Second + 3*time.Millisecond, StackLo: 0xc000040000, StackHi: 0xc000040800}
+	if s := goroutineState(g); s != `[waiting "select" for 1m30s] stack [0xc000040000, 0xc000040800)` {
+		t.Fatalf("wrong state: %s", s)
+	}
+}
+
+func TestParseStacksArgs(t *testing.T) {
+	stacks, rest, err := parseStacksArgs([]string{"-t", "20", "-u", "main", "-format", "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stacks == nil || stacks.depth != 20 || stacks.format != stacksJSON || stacks.output != "" {
+		t.Fatalf("wrong stacks arguments: %#v", stacks)
+	}
+	if len(rest) != 2 || rest[0] != "-u" || rest[1] != "main" {
+		t.Fatalf("wrong remaining arguments: %v", rest)
+	}
+
+	stacks, _, err = parseStacksArgs([]string{"-t"})
+	if err != nil || stacks.depth != defaultStacksDepth || stacks.format != stacksText {
+		t.Fatalf("wrong defaults: %#v %v", stacks, err)
+	}
+	if _, _, err := parseStacksArgs([]string{"-t", "-format", "pprof"}); err == nil {
+		t.Fatal("pprof without output file did not fail")
+	}
+	if _, _, err := parseStacksArgs([]string{"-o", "out.txt"}); err == nil {
+		t.Fatal("-o without -t did not fail")
+	}
+}
+
+func TestFormatDeadlock(t *testing.T) {
+	worker := &api.Function{Name: "main.worker"}
+	report := &api.DeadlockReport{
+		Blocked: []*api.BlockedGoroutine{
+			{Goroutine: api.Goroutine{ID: 5}, ObjectKind: "chan", ObjectAddr: 0xc000010000,
+				Location: &api.Location{File: "/tmp/deadlockprog.go", Line: 6, Function: worker}, WaitsOn: []int{6}},
+			{Goroutine: api.Goroutine{ID: 6}, ObjectKind: "chan", ObjectAddr: 0xc000010060,
+				Location: &api.Location{File: "/tmp/deadlockprog.go", Line: 6, Function: worker}, WaitsOn: []int{5}},
+			{Goroutine: api.Goroutine{ID: 7, File: "/usr/lib/go/src/runtime/proc.go", Line: 363}, Error: "could not find formal parameter"},
+		},
+		Groups: []*api.BlockedGroup{
+			{WaitReason: "chan receive", ObjectKind: "chan", ObjectAddr: 0xc000010000, Goroutines: []int{5}},
+			{WaitReason: "chan receive", ObjectKind: "chan", ObjectAddr: 0xc000010060, Goroutines: []int{6}},
+			{WaitReason: "sleep", Goroutines: []int{7}},
+		},
+		Cycles:     [][]int{{5, 6}},
+		AllBlocked: true,
+	}
+	expected := `[3 blocked goroutines]
+chan receive on chan 0xc000010000:
+	Goroutine 5 - /tmp/deadlockprog.go:6 main.worker (waits on 6)
+chan receive on chan 0xc000010060:
+	Goroutine 6 - /tmp/deadlockprog.go:6 main.worker (waits on 5)
+sleep on unknown object:
+	Goroutine 7 - /usr/lib/go/src/runtime/proc.go:363 (nil) (error: could not find formal parameter)
+Deadlock: goroutines 5, 6 are waiting on each other
+All goroutines are blocked
+`
+	if s := formatDeadlock(report); s != expected {
+		t.Fatalf("wrong deadlock report:\n%s", s)
+	}
+}
+
+func TestFormatChannel(t *testing.T) {
+	ch := &api.Channel{
+		Type:        "chan int",
+		Addr:        0xc000010000,
+		Len:         2,
+		Cap:         3,
+		Elems:       []string{"1", "2"},
+		SendWaiters: []int{5, 6},
+	}
+	expected := `chan int at 0xc000010000, open
+len: 2, cap: 3
+	[0] 1
+	[1] 2
+No goroutines waiting to receive
+Goroutines waiting to send: 5, 6
+`
+	if s := formatChannel(ch); s != expected {
+		t.Fatalf("wrong channel:\n%s", s)
+	}
+	if s := formatChannel(&api.Channel{Type: "chan int"}); s != "chan int nil\n" {
+		t.Fatalf("wrong nil channel: %q", s)
+	}
+}
+
+func TestFormatTypeInfo(t *testing.T) {
+	info := &api.TypeInfo{
+		Name:       "main.T",
+		Size:       24,
+		Underlying: "struct",
+		Fields: []api.Field{
+			{Name: "A", Type: "int8", Offset: 0, Size: 1},
+			{Name: "Ptr", Type: "*main.T", Offset: 8, Size: 8},
+			{Name: "B", Type: "int32", Offset: 16, Size: 4},
+		},
+	}
+	expected := `type main.T struct { // size 24
+	A   int8    // offset 0, size 1
+	// 7 bytes of padding
+	Ptr *main.T // offset 8, size 8
+	B   int32   // offset 16, size 4
+	// 4 bytes of padding
+}
+`
+	if s := formatTypeInfo(info); s != expected {
+		t.Fatalf("wrong struct definition:\n%s", s)
+	}
+
+	info = &api.TypeInfo{Name: "main.Celsius", Size: 8, Underlying: "float64"}
+	if s := formatTypeInfo(info); s != "type main.Celsius float64 // size 8\n" {
+		t.Fatalf("wrong definition: %s", s)
+	}
+}
+
+func TestCommandFormat(t *testing.T) {
+	cmds := DebugCommands(nil)
+	var got []string
+	capture := func(client service.Client, args ...string) error {
+		got = args
+		return nil
+	}
+	for i := range cmds.cmds {
+		if cmds.cmds[i].match("print") {
+			cmds.cmds[i].cmdFn = capture
+		}
+	}
+	cmds.Register("foo", capture, "foo command")
+
+	if err := cmds.Find("p/x")(nil, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "/x" || got[1] != "a" {
+		t.Fatalf("wrong arguments: %q", got)
+	}
+	// Only commands taking a format are split.
+	if err := cmds.Find("foo/x")(nil, "a"); err == nil {
+		t.Fatal("foo/x found")
+	}
+
+	// Formats are split in breakpoint command lists too.
+	got = nil
+	cmds.lastCmd = nil
+	cmds.bpcmds = map[int][]string{1: {"p/x flags"}}
+	state := &api.DebuggerState{Breakpoint: &api.Breakpoint{ID: 1}}
+	if err := cmds.runBreakpointCommands(nil, state); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "/x" || got[1] != "flags" {
+		t.Fatalf("wrong arguments: %q", got)
+	}
+	if cmds.lastCmd != nil {
+		t.Fatal("breakpoint command recorded as the last command")
+	}
+}
+
+func TestResolveHistory(t *testing.T) {
+	history := []api.Variable{
+		{Value: "42", Type: "int", Kind: reflect.Int},
+		{Value: "0xff", Type: "int8", Kind: reflect.Int8},
+		{Value: "97 'a'", Type: "int32", Kind: reflect.Int32},
+		{Value: `say \"hi\"`, Type: "struct string", Kind: reflect.String, Len: 8},
+		{Value: "main.T {A: 1}", Type: "main.T", Kind: reflect.Struct},
+		{Value: "0b101", Type: "main.Flags", Kind: reflect.Uint8},
+		{Value: "abc...+10 more", Type: "struct string", Kind: reflect.String, Len: 13},
+		{Value: `C:\\temp\tdir`, Type: "struct string", Kind: reflect.String, Len: 11},
+	}
+	testcases := []struct {
+		expr, expected string
+	}{
+		{"$1 + x", "int(42) + x"},
+		{"$2", "int8(-1)"},
+		{"$3 == r", "int32(97) == r"},
+		{"$4", `"say \"hi\""`},
+		{"$8", `"C:\\temp\tdir"`},
+		{"$6|$1", "5|int(42)"},
+		{`s == "$1" && $1 > 0`, `s == "$1" && int(42) > 0`},
+	}
+	for _, tc := range testcases {
+		expr, err := resolveHistory(tc.expr, history)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.expr, err)
+		}
+		if expr != tc.expected {
+			t.Fatalf("%s: expected %s got %s", tc.expr, tc.expected, expr)
+		}
+	}
+
+	for _, expr := range []string{"$5", "$7", "$9", "$0", "$ + 1"} {
+		if _, err := resolveHistory(expr, history); err == nil {
+			t.Fatalf("%s: expected an error", expr)
+		}
+	}
+}
+
+func TestFormatDisplay(t *testing.T) {
+	testcases := []struct {
+		d         api.Display
+		highlight bool
+		expected  string
+	}{
+		{api.Display{ID: 1, Expr: "n", Value: &api.Variable{Value: "3"}}, true, "1: n = 3"},
+		{api.Display{ID: 2, Expr: "n", Format: "x", Value: &api.Variable{Value: "0x4"}, Changed: true}, true, "2: /x n = \033[1;33m0x4\033[0m"},
+		{api.Display{ID: 2, Expr: "n", Format: "x", Value: &api.Variable{Value: "0x4"}, Changed: true}, false, "2: /x n = 0x4 *"},
+		{api.Display{ID: 3, Expr: "m", Err: "could not find symbol value for m"}, false, "3: m = <error: could not find symbol value for m>"},
+	}
+	for _, tc := range testcases {
+		if s := formatDisplay(tc.d, tc.highlight); s != tc.expected {
+			t.Fatalf("expected %q got %q", tc.expected, s)
+		}
+	}
+}
+
+func TestFormatRuntimeInfo(t *testing.T) {
+	ps := []struct {
+		p        api.P
+		expected string
+	}{
+		{api.P{ID: 0, Status: "idle", M: -1}, "P0 idle, 0 runnable goroutines"},
+		{api.P{ID: 3, Status: "running", M: 2, RunqLen: 5}, "P3 running on M2, 5 runnable goroutines"},
+	}
+	for _, tc := range ps {
+		if s := formatP(tc.p); s != tc.expected {
+			t.Fatalf("expected %q got %q", tc.expected, s)
+		}
+	}
+	ms := []struct {
+		m        api.M
+		expected string
+	}{
+		{api.M{ID: 0, ThreadID: 1234, CurG: 1, P: 3}, "M0 thread 1234, goroutine 1, P3"},
+		{api.M{ID: 4, ThreadID: 1240, P: -1, Blocked: true}, "M4 thread 1240, blocked"},
+		{api.M{ID: 5, ThreadID: 1241, P: 1, Spinning: true}, "M5 thread 1241, P1, spinning"},
+	}
+	for _, tc := range ms {
+		if s := formatM(tc.m); s != tc.expected {
+			t.Fatalf("expected %q got %q", tc.expected, s)
+		}
+	}
+}
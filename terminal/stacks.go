@@ -0,0 +1,212 @@
+package terminal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/derekparker/delve/service/api"
+)
+
+// Formats goroutine stacks can be written in.
+const (
+	stacksText  = "text"  // Go's SIGQUIT traceback format.
+	stacksJSON  = "json"  // The api.GoroutineStack values.
+	stacksPprof = "pprof" // A pprof goroutine profile.
+)
+
+func writeStacks(w io.Writer, format string, stacks []api.GoroutineStack) error {
+	switch format {
+	case stacksText, "":
+		return writeTraceback(w, stacks)
+	case stacksJSON:
+		b, err := json.MarshalIndent(stacks, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err
+	case stacksPprof:
+		return writeGoroutineProfile(w, stacks)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// writeTraceback writes stacks the way the Go runtime prints them when
+// the process receives SIGQUIT.
+func writeTraceback(w io.Writer, stacks []api.GoroutineStack) error {
+	var buf bytes.Buffer
+	for _, s := range stacks {
+		state := s.Status
+		if s.WaitReason != "" {
+			state = s.WaitReason
+		}
+		if s.LockedToThread {
+			state += ", locked to thread"
+		}
+		fmt.Fprintf(&buf, "goroutine %d [%s]:\n", s.ID, state)
+		for _, loc := range s.Stack {
+			name := "?"
+			var offset uint64
+			if loc.Function != nil {
+				name = loc.Function.Name
+				offset = loc.PC - loc.Function.Value
+			}
+			fmt.Fprintf(&buf, "%s(...)\n\t%s:%d +%#x\n", name, loc.File, loc.Line, offset)
+		}
+		if s.Err != "" {
+			fmt.Fprintf(&buf, "...additional frames elided: %s\n", s.Err)
+		}
+		if s.Function != nil && s.GoPC != 0 {
+			fmt.Fprintf(&buf, "created by %s\n\t%s:%d +%#x\n", s.Function.Name, s.File, s.Line, s.GoPC-s.Function.Value)
+		}
+		buf.WriteByte('\n')
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// writeGoroutineProfile writes stacks as a gzipped pprof goroutine
+// profile, see github.com/google/pprof/proto/profile.proto. Each goroutine
+// is a sample of value 1 carrying its profiler labels.
+func writeGoroutineProfile(w io.Writer, stacks []api.GoroutineStack) error {
+	var (
+		p         protobuf
+		strs      = map[string]int64{"": 0}
+		strtab    = []string{""}
+		locations = map[uint64]uint64{}
+		functions = map[string]uint64{}
+		locbufs   protobuf
+		fnbufs    protobuf
+	)
+	str := func(s string) int64 {
+		if i, ok := strs[s]; ok {
+			return i
+		}
+		strs[s] = int64(len(strtab))
+		strtab = append(strtab, s)
+		return strs[s]
+	}
+	function := func(loc *api.Location) uint64 {
+		name := "?"
+		if loc.Function != nil {
+			name = loc.Function.Name
+		}
+		if id, ok := functions[name]; ok {
+			return id
+		}
+		id := uint64(len(functions) + 1)
+		functions[name] = id
+		var fn protobuf
+		fn.fieldUint(1, id)
+		fn.fieldInt(2, str(name))
+		fn.fieldInt(3, str(name))
+		fn.fieldInt(4, str(loc.File))
+		fnbufs.fieldMessage(5, &fn)
+		return id
+	}
+	location := func(loc *api.Location) uint64 {
+		if id, ok := locations[loc.PC]; ok {
+			return id
+		}
+		id := uint64(len(locations) + 1)
+		locations[loc.PC] = id
+		var line, l protobuf
+		line.fieldUint(1, function(loc))
+		line.fieldInt(2, int64(loc.Line))
+		l.fieldUint(1, id)
+		l.fieldUint(3, loc.PC)
+		l.fieldMessage(4, &line)
+		locbufs.fieldMessage(4, &l)
+		return id
+	}
+
+	var valueType protobuf
+	valueType.fieldInt(1, str("goroutine"))
+	valueType.fieldInt(2, str("count"))
+	p.fieldMessage(1, &valueType)
+
+	for _, s := range stacks {
+		var sample protobuf
+		ids := make([]uint64, 0, len(s.Stack))
+		for i := range s.Stack {
+			ids = append(ids, location(&s.Stack[i]))
+		}
+		sample.fieldPacked(1, ids)
+		sample.fieldPacked(2, []uint64{1})
+		keys := make([]string, 0, len(s.Labels))
+		for k := range s.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			var label protobuf
+			label.fieldInt(1, str(k))
+			label.fieldInt(2, str(s.Labels[k]))
+			sample.fieldMessage(3, &label)
+		}
+		p.fieldMessage(2, &sample)
+	}
+	p.Write(locbufs.Bytes())
+	p.Write(fnbufs.Bytes())
+	for _, s := range strtab {
+		p.fieldString(6, s)
+	}
+	p.fieldMessage(11, &valueType)
+	p.fieldInt(12, 1)
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(p.Bytes()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// protobuf is a minimal protocol buffer encoder.
+type protobuf struct {
+	bytes.Buffer
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.WriteByte(byte(x) | 0x80)
+		x >>= 7
+	}
+	b.WriteByte(byte(x))
+}
+
+func (b *protobuf) tag(field, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *protobuf) fieldUint(field int, x uint64) {
+	b.tag(field, 0)
+	b.varint(x)
+}
+
+func (b *protobuf) fieldInt(field int, x int64) {
+	b.fieldUint(field, uint64(x))
+}
+
+func (b *protobuf) fieldPacked(field int, xs []uint64) {
+	var packed protobuf
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	b.fieldMessage(field, &packed)
+}
+
+func (b *protobuf) fieldString(field int, s string) {
+	b.tag(field, 2)
+	b.varint(uint64(len(s)))
+	b.WriteString(s)
+}
+
+func (b *protobuf) fieldMessage(field int, m *protobuf) {
+	b.tag(field, 2)
+	b.varint(uint64(m.Len()))
+	b.Write(m.Bytes())
+}
@@ -0,0 +1,68 @@
+package terminal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+
+	"github.com/derekparker/delve/service/api"
+)
+
+var testStacks = []api.GoroutineStack{
+	{
+		Goroutine: api.Goroutine{
+			ID:         5,
+			Status:     "waiting",
+			WaitReason: "chan receive",
+			File:       "/src/main.go",
+			Line:       10,
+			GoPC:       0x1010,
+			Function:   &api.Function{Name: "main.main", Value: 0x1000},
+			Labels:     map[string]string{"handler": "index"},
+		},
+		Stack: []api.Location{
+			{PC: 0x2005, File: "/src/runtime/proc.go", Line: 131, Function: &api.Function{Name: "runtime.gopark", Value: 0x2000}},
+			{PC: 0x3020, File: "/src/main.go", Line: 5, Function: &api.Function{Name: "main.worker", Value: 0x3000}},
+		},
+	},
+}
+
+func TestWriteTraceback(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeTraceback(&buf, testStacks); err != nil {
+		t.Fatal(err)
+	}
+	expected := `goroutine 5 [chan receive]:
+runtime.gopark(...)
+	/src/runtime/proc.go:131 +0x5
+main.worker(...)
+	/src/main.go:5 +0x20
+created by main.main
+	/src/main.go:10 +0x10
+
+`
+	if buf.String() != expected {
+		t.Fatalf("wrong traceback:\n%s", buf.String())
+	}
+}
+
+func TestWriteGoroutineProfile(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeGoroutineProfile(&buf, testStacks); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"goroutine", "count", "main.worker", "runtime.gopark", "handler", "index"} {
+		if !bytes.Contains(b, []byte(s)) {
+			t.Fatalf("profile does not contain %q", s)
+		}
+	}
+}
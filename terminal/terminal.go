@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"os/user"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/peterh/liner"
@@ -53,6 +54,7 @@ func (t *Term) Run() (error, int) {
 	}()
 
 	cmds := DebugCommands(t.client)
+	cmds.readLine = t.line.Prompt
 	fullHistoryFile, err := getConfigFilePath(historyFile)
 	if err != nil {
 		fmt.Printf("Unable to load history file: %v.", err)
@@ -147,10 +149,62 @@ func handleExit(client service.Client, t *Term) (error, int) {
 }
 
 func parseCommand(cmdstr string) (string, []string) {
-	vals := strings.Split(cmdstr, " ")
+	vals := splitArgs(cmdstr)
+	if len(vals) == 0 {
+		return "", nil
+	}
 	return vals[0], vals[1:]
 }
 
+// splitArgs splits a command line on spaces, except within quoted strings,
+// which are kept whole with their quotes so that expressions passed as
+// several arguments can be joined back unchanged.
+func splitArgs(cmdstr string) []string {
+	var (
+		args  []string
+		arg   []byte
+		quote byte
+		inArg bool
+	)
+	for i := 0; i < len(cmdstr); i++ {
+		c := cmdstr[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' && i+1 < len(cmdstr) {
+				arg = append(arg, c)
+				i++
+				c = cmdstr[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, string(arg))
+				arg, inArg = arg[:0], false
+			}
+			continue
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		}
+		arg, inArg = append(arg, c), true
+	}
+	if inArg {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+// unquoteArg removes the quotes around an argument split by splitArgs.
+func unquoteArg(arg string) string {
+	if len(arg) < 2 || (arg[0] != '"' && arg[0] != '`') {
+		return arg
+	}
+	if s, err := strconv.Unquote(arg); err == nil {
+		return s
+	}
+	return arg
+}
+
 func createConfigPath() error {
 	path, err := getConfigFilePath("")
 	if err != nil {